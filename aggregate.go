@@ -0,0 +1,153 @@
+package netflow
+
+import (
+	"math"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+)
+
+// ASPair is a source and destination Autonomous System Number pair.
+type ASPair struct {
+	SrcAS uint16
+	DstAS uint16
+}
+
+// Totals are summed counters of aggregated flows.
+type Totals struct {
+	Flows   uint64
+	Packets uint64
+	Bytes   uint64
+}
+
+// AggregateByAS groups the records by their source and destination AS and
+// sums their counters. Records without AS information, such as NetFlow v1
+// records, are ignored.
+func AggregateByAS(records []FlowRecord) map[ASPair]Totals {
+	totals := make(map[ASPair]Totals)
+	for _, r := range records {
+		var (
+			pair                  ASPair
+			flows, packets, bytes uint32
+		)
+		switch r := r.(type) {
+		case *netflow5.FlowRecord:
+			pair, flows, packets, bytes = ASPair{r.SrcAS, r.DstAS}, 1, r.Packets, r.Bytes
+		case *netflow6.FlowRecord:
+			pair, flows, packets, bytes = ASPair{r.SrcAS, r.DstAS}, 1, r.Packets, r.Bytes
+		case *netflow7.FlowRecord:
+			pair, flows, packets, bytes = ASPair{r.SrcAS, r.DstAS}, 1, r.Packets, r.Bytes
+		case *netflow8.ASRecord:
+			pair, flows, packets, bytes = ASPair{r.SrcAS, r.DstAS}, r.Flows, r.Packets, r.Bytes
+		default:
+			continue
+		}
+		t := totals[pair]
+		t.Flows += uint64(flows)
+		t.Packets += uint64(packets)
+		t.Bytes += uint64(bytes)
+		totals[pair] = t
+	}
+	return totals
+}
+
+// Aggregator merges the records of the same flow, as identified by their
+// FlowKey, for example a long lived flow split over several records by the
+// active timeout of the exporter. It is not safe for concurrent use.
+type Aggregator struct {
+	keys  []FlowKey
+	flows map[FlowKey]FlowRecord
+}
+
+// NewAggregator sets up an empty Aggregator.
+func NewAggregator() *Aggregator {
+	return &Aggregator{flows: make(map[FlowKey]FlowRecord)}
+}
+
+// Add merges the record into the flow with the same FlowKey: the packet and
+// byte counts are summed (saturating at the maximum counter value), the First
+// and Last uptimes are widened and the TCP flags are OR-ed. The record itself
+// is not modified. Records without a FlowKey, such as the NetFlow v8
+// aggregation records, are not added and false is returned.
+func (a *Aggregator) Add(r FlowRecord) bool {
+	key, ok := KeyOf(r)
+	if !ok {
+		return false
+	}
+	flow, found := a.flows[key]
+	if !found {
+		a.keys = append(a.keys, key)
+		a.flows[key] = copyRecord(r)
+		return true
+	}
+
+	dst, src := mergeFields(flow), mergeFields(r)
+	*dst.packets = addSaturated(*dst.packets, *src.packets)
+	*dst.bytes = addSaturated(*dst.bytes, *src.bytes)
+	if *src.first < *dst.first {
+		*dst.first = *src.first
+	}
+	if *src.last > *dst.last {
+		*dst.last = *src.last
+	}
+	*dst.tcpFlags |= *src.tcpFlags
+	return true
+}
+
+// Flush returns the merged flows in the order they were first added, and
+// empties the Aggregator.
+func (a *Aggregator) Flush() []FlowRecord {
+	records := make([]FlowRecord, len(a.keys))
+	for i, key := range a.keys {
+		records[i] = a.flows[key]
+	}
+	a.keys = nil
+	a.flows = make(map[FlowKey]FlowRecord)
+	return records
+}
+
+// mergeable are the fields of a record merged by the Aggregator.
+type mergeable struct {
+	packets, bytes, first, last *uint32
+	tcpFlags                    *uint8
+}
+
+// mergeFields returns the merged fields of a record with a FlowKey.
+func mergeFields(r FlowRecord) mergeable {
+	switch r := r.(type) {
+	case *netflow1.FlowRecord:
+		return mergeable{&r.Packets, &r.Bytes, &r.First, &r.Last, &r.Flags}
+	case *netflow5.FlowRecord:
+		return mergeable{&r.Packets, &r.Bytes, &r.First, &r.Last, &r.TCPFlags}
+	case *netflow6.FlowRecord:
+		return mergeable{&r.Packets, &r.Bytes, &r.First, &r.Last, &r.TCPFlags}
+	case *netflow7.FlowRecord:
+		return mergeable{&r.Packets, &r.Bytes, &r.First, &r.Last, &r.TCPFlags}
+	}
+	panic("netflow: unexpected record type")
+}
+
+// copyRecord returns a copy of a record with a FlowKey.
+func copyRecord(r FlowRecord) FlowRecord {
+	switch r := r.(type) {
+	case *netflow1.FlowRecord:
+		return r.Clone()
+	case *netflow5.FlowRecord:
+		return r.Clone()
+	case *netflow6.FlowRecord:
+		return r.Clone()
+	case *netflow7.FlowRecord:
+		return r.Clone()
+	}
+	panic("netflow: unexpected record type")
+}
+
+func addSaturated(a, b uint32) uint32 {
+	if a > math.MaxUint32-b {
+		return math.MaxUint32
+	}
+	return a + b
+}
@@ -0,0 +1,82 @@
+package netflow
+
+import (
+	"net"
+	"testing"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+)
+
+func TestAggregateByAS(t *testing.T) {
+	records := []FlowRecord{
+		&netflow5.FlowRecord{SrcAS: 65000, DstAS: 15, Packets: 10, Bytes: 0xffffffff},
+		&netflow7.FlowRecord{SrcAS: 65000, DstAS: 15, Packets: 20, Bytes: 0xffffffff},
+		&netflow8.ASRecord{SrcAS: 65000, DstAS: 15, Flows: 5, Packets: 30, Bytes: 3000},
+		&netflow5.FlowRecord{SrcAS: 15, DstAS: 65000, Packets: 1, Bytes: 64},
+		&netflow1.FlowRecord{Packets: 100, Bytes: 1000},
+	}
+
+	totals := AggregateByAS(records)
+	if len(totals) != 2 {
+		t.Fatalf("expected 2 AS pairs, got %d: %v", len(totals), totals)
+	}
+	want := map[ASPair]Totals{
+		{65000, 15}: {Flows: 7, Packets: 60, Bytes: 2*0xffffffff + 3000},
+		{15, 65000}: {Flows: 1, Packets: 1, Bytes: 64},
+	}
+	for pair, w := range want {
+		if got := totals[pair]; got != w {
+			t.Errorf("%v: expected %+v, got %+v", pair, w, got)
+		}
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	var (
+		src = net.IPv4(192, 0, 2, 1).To4()
+		dst = net.IPv4(10, 0, 0, 1).To4()
+		a   = &netflow5.FlowRecord{SrcAddr: src, DstAddr: dst, SrcPort: 1024, DstPort: 80, Protocol: 6,
+			Packets: 10, Bytes: 1500, First: 1000, Last: 2000, TCPFlags: 0x02}
+		b = &netflow5.FlowRecord{SrcAddr: src, DstAddr: dst, SrcPort: 1024, DstPort: 80, Protocol: 6,
+			Packets: 5, Bytes: 500, First: 2000, Last: 3000, TCPFlags: 0x11}
+		other = &netflow5.FlowRecord{SrcAddr: src, DstAddr: dst, SrcPort: 1025, DstPort: 80, Protocol: 6, Packets: 1}
+	)
+
+	agg := NewAggregator()
+	for _, r := range []FlowRecord{a, other, b} {
+		if !agg.Add(r) {
+			t.Fatalf("expected %s to be added", r)
+		}
+	}
+	if agg.Add(&netflow8.ASRecord{}) {
+		t.Error("expected NetFlow v8 AS record not to be added")
+	}
+
+	records := agg.Flush()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 flows, got %d", len(records))
+	}
+	m := records[0].(*netflow5.FlowRecord)
+	if m.Packets != 15 || m.Bytes != 2000 {
+		t.Errorf("expected 15 packets and 2000 bytes, got %d and %d", m.Packets, m.Bytes)
+	}
+	if m.First != 1000 || m.Last != 3000 {
+		t.Errorf("expected window 1000-3000, got %d-%d", m.First, m.Last)
+	}
+	if m.TCPFlags != 0x13 {
+		t.Errorf("expected TCP flags 0x13, got %#02x", m.TCPFlags)
+	}
+	if a.Packets != 10 {
+		t.Error("expected the added record not to be modified")
+	}
+	if r := records[1].(*netflow5.FlowRecord); r.SrcPort != 1025 || r.Packets != 1 {
+		t.Errorf("expected the unmerged flow, got %s", r)
+	}
+
+	if records = agg.Flush(); len(records) != 0 {
+		t.Errorf("expected no flows after Flush, got %d", len(records))
+	}
+}
@@ -0,0 +1,56 @@
+package netflow
+
+import (
+	"strings"
+
+	"github.com/tehmaze/netflow/translate"
+)
+
+// ApplicationResolver resolves an application ID to the name of the
+// application, such as "http" or "youtube". The selectors of most engines are
+// specific to the exporter, and can be learned from its options records.
+type ApplicationResolver interface {
+	ApplicationName(id translate.ApplicationID) (string, bool)
+}
+
+// ApplicationNames is an ApplicationResolver backed by a map.
+type ApplicationNames map[translate.ApplicationID]string
+
+// ApplicationName returns the name of the application in the map.
+func (m ApplicationNames) ApplicationName(id translate.ApplicationID) (string, bool) {
+	name, ok := m[id]
+	return name, ok
+}
+
+// ApplicationID returns the applicationId (95) of the record. If the record
+// has no applicationId field, or the field can not be decoded, ok is false.
+func (r GenericRecord) ApplicationID() (id translate.ApplicationID, ok bool) {
+	v, ok := r.Get(95)
+	if !ok {
+		return id, false
+	}
+	if bs, ok := v.([]byte); ok {
+		v = translate.DecodeApplicationID(bs)
+	}
+	id, ok = v.(translate.ApplicationID)
+	return id, ok
+}
+
+// ApplicationName returns the name of the application of the record, from
+// the applicationName (96) field if the record has one, otherwise by
+// resolving the applicationId with res. The resolver may be nil.
+func (r GenericRecord) ApplicationName(res ApplicationResolver) (string, bool) {
+	if v, ok := r.Get(96); ok {
+		// Fixed length names are padded with NUL bytes.
+		if name, ok := v.(string); ok {
+			if name = strings.TrimRight(name, "\x00 "); name != "" {
+				return name, true
+			}
+		}
+	}
+	id, ok := r.ApplicationID()
+	if !ok || res == nil {
+		return "", false
+	}
+	return res.ApplicationName(id)
+}
@@ -0,0 +1,62 @@
+package netflow
+
+import (
+	"testing"
+
+	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
+)
+
+func TestGenericRecordApplicationID(t *testing.T) {
+	p, err := NewDecoder(session.New()).DecodeBytes([]byte{
+		0x00, 0x0a, 0x00, 0x34, // version 10, length 52
+		0x5e, 0x0b, 0xe1, 0x00, // Export Time
+		0x00, 0x00, 0x00, 0x01, // Sequence Number
+		0x00, 0x00, 0x00, 0x07, // Observation Domain ID
+		0x00, 0x02, 0x00, 0x10, // template set
+		0x01, 0x00, 0x00, 0x02, // template 256, 2 fields
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		0x00, 0x5f, 0x00, 0x04, // applicationId
+		0x01, 0x00, 0x00, 0x14, // data set for template 256
+		0xc0, 0x00, 0x02, 0x01, // 192.0.2.1
+		0x0d, 0x00, 0x00, 0x50, // NBAR, selector 80
+		0xc0, 0x00, 0x02, 0x02, // 192.0.2.2
+		0x0d, 0x00, 0x01, 0xc8, // NBAR, selector 456
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := GenericRecords(p.Message)
+	if len(rs) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(rs))
+	}
+	id, ok := rs[0].ApplicationID()
+	if want := (translate.ApplicationID{EngineID: translate.EnginePANAL7, Selector: 80}); !ok || id != want {
+		t.Errorf("expected application %v, got %v (%t)", want, id, ok)
+	}
+
+	names := ApplicationNames{id: "http"}
+	if name, ok := rs[0].ApplicationName(names); !ok || name != "http" {
+		t.Errorf("expected application http, got %q (%t)", name, ok)
+	}
+	if name, ok := rs[1].ApplicationName(names); ok {
+		t.Errorf("expected selector 456 to be unknown, got %q", name)
+	}
+	if _, ok := rs[0].ApplicationName(nil); ok {
+		t.Error("expected no name without a resolver")
+	}
+
+	named := GenericRecord{
+		{FieldID: 95, Value: []byte{0x0d, 0x00, 0x01, 0xc8}},
+		{FieldID: 96, Value: "youtube\x00\x00"},
+	}
+	if name, ok := named.ApplicationName(nil); !ok || name != "youtube" {
+		t.Errorf("expected the applicationName field youtube, got %q (%t)", name, ok)
+	}
+	if id, ok := named.ApplicationID(); !ok || id.Selector != 456 {
+		t.Errorf("expected raw selector 456, got %v (%t)", id, ok)
+	}
+	if _, ok := (GenericRecord{}).ApplicationID(); ok {
+		t.Error("expected no application ID")
+	}
+}
@@ -0,0 +1,91 @@
+package netflow
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/session"
+)
+
+// benchmarkDecode measures decoding the datagram repeatedly with a single
+// Decoder. The NetFlow v9 datagrams carry their template, so learning the
+// template is included in the measurement.
+func benchmarkDecode(b *testing.B, data []byte) {
+	d := NewDecoder(session.New())
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := d.DecodeBytes(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeV5(b *testing.B) {
+	b.Run("1", func(b *testing.B) { benchmarkDecode(b, testPacketV5(1)) })
+	b.Run("30", func(b *testing.B) { benchmarkDecode(b, testPacketV5(30)) })
+}
+
+func BenchmarkDecodeV7(b *testing.B) {
+	records := make([]*netflow7.FlowRecord, 28)
+	for i := range records {
+		records[i] = &netflow7.FlowRecord{
+			SrcAddr:  net.IPv4(192, 168, 1, byte(i+1)),
+			DstAddr:  net.IPv4(10, 0, 0, 1),
+			NextHop:  net.IPv4(10, 0, 0, 254),
+			Packets:  10,
+			Bytes:    1500,
+			SrcPort:  uint16(1024 + i),
+			DstPort:  80,
+			Protocol: 6,
+			RouterSC: net.IPv4(10, 0, 0, 254),
+		}
+	}
+	b.Run("1", func(b *testing.B) { benchmarkDecode(b, testPacketV7(b, 1, records[0])) })
+	b.Run("28", func(b *testing.B) { benchmarkDecode(b, testPacketV7(b, 1, records...)) })
+}
+
+// testPacketV9Records builds a NetFlow v9 datagram with a template and a data
+// flowset holding count records.
+func testPacketV9Records(tb testing.TB, count int) []byte {
+	tr := netflow9.NewTemplateBuilder(256).
+		AddField(8, 4).  // sourceIPv4Address
+		AddField(12, 4). // destinationIPv4Address
+		AddField(7, 2).  // sourceTransportPort
+		AddField(11, 2). // destinationTransportPort
+		AddField(4, 1).  // protocolIdentifier
+		AddField(2, 4).  // packetDeltaCount
+		AddField(1, 4).  // octetDeltaCount
+		AddField(22, 4). // flowStartSysUpTime
+		AddField(21, 4). // flowEndSysUpTime
+		Build()
+
+	dfs := netflow9.NewDataFlowSetBuilder(tr)
+	for i := 0; i < count; i++ {
+		if err := dfs.AddRecord(net.IPv4(192, 168, 1, byte(i+1)), net.IPv4(10, 0, 0, 1),
+			1024+i, 80, 6, 10, 1500, 90000, 100000); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	h := netflow9.PacketHeader{
+		Version:        netflow9.Version,
+		Count:          uint16(1 + dfs.Len()),
+		SysUpTime:      100000,
+		UnixSecs:       uint32(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()),
+		SequenceNumber: 1,
+		SourceID:       1,
+	}
+	b := h.AppendBytes(nil)
+	b = netflow9.AppendTemplateFlowSet(b, tr)
+	return dfs.AppendBytes(b)
+}
+
+func BenchmarkDecodeV9Packet(b *testing.B) {
+	b.Run("1", func(b *testing.B) { benchmarkDecode(b, testPacketV9Records(b, 1)) })
+	b.Run("30", func(b *testing.B) { benchmarkDecode(b, testPacketV9Records(b, 30)) })
+}
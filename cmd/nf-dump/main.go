@@ -21,6 +21,7 @@ import (
 	"github.com/tehmaze/netflow/netflow5"
 	"github.com/tehmaze/netflow/netflow6"
 	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
 	"github.com/tehmaze/netflow/netflow9"
 	"github.com/tehmaze/netflow/session"
 )
@@ -85,6 +86,9 @@ func main() {
 		case *netflow7.Packet:
 			netflow7.Dump(p)
 
+		case *netflow8.Packet:
+			netflow8.Dump(p)
+
 		case *netflow9.Packet:
 			netflow9.Dump(p)
 
@@ -0,0 +1,157 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+	"github.com/tehmaze/netflow/write"
+)
+
+// ColumnType is the encoding of the values in a column of a ColumnBatch. All
+// values are fixed width and big endian, as on the wire.
+type ColumnType uint8
+
+// Column types
+const (
+	ColumnUint8 ColumnType = iota + 1
+	ColumnUint16
+	ColumnUint32
+	ColumnIPv4
+)
+
+// Width returns the size of a value in bytes. Unknown types have no width.
+func (t ColumnType) Width() int {
+	switch t {
+	case ColumnUint8:
+		return 1
+	case ColumnUint16:
+		return 2
+	case ColumnUint32, ColumnIPv4:
+		return 4
+	default:
+		return 0
+	}
+}
+
+func (t ColumnType) String() string {
+	switch t {
+	case ColumnUint8:
+		return "uint8"
+	case ColumnUint16:
+		return "uint16"
+	case ColumnUint32:
+		return "uint32"
+	case ColumnIPv4:
+		return "ipv4"
+	default:
+		return "unknown"
+	}
+}
+
+// ColumnSchema describes a column of a ColumnBatch.
+type ColumnSchema struct {
+	Name string
+	Type ColumnType
+}
+
+// ColumnBatchSchema are the columns of a ColumnBatch, the columns and their
+// names match those written by WriteCSV.
+var ColumnBatchSchema = []ColumnSchema{
+	{"srcAddr", ColumnIPv4},
+	{"dstAddr", ColumnIPv4},
+	{"srcPort", ColumnUint16},
+	{"dstPort", ColumnUint16},
+	{"protocol", ColumnUint8},
+	{"packets", ColumnUint32},
+	{"bytes", ColumnUint32},
+	{"first", ColumnUint32},
+	{"last", ColumnUint32},
+}
+
+// ColumnBatch holds a batch of records by column, for storage in or
+// conversion to a column oriented format. Columns[i] holds the Rows values of
+// the column described by Schema[i], one after another.
+type ColumnBatch struct {
+	Rows    int
+	Schema  []ColumnSchema
+	Columns [][]byte
+}
+
+// NewColumnBatch encodes the records by column. Values that are not available
+// for a record, such as addresses in aggregated NetFlow v8 records, are zero.
+func NewColumnBatch(records []FlowRecord) *ColumnBatch {
+	b := &ColumnBatch{
+		Rows:    len(records),
+		Schema:  ColumnBatchSchema,
+		Columns: make([][]byte, len(ColumnBatchSchema)),
+	}
+	for i, c := range b.Schema {
+		b.Columns[i] = make([]byte, len(records)*c.Type.Width())
+	}
+	for i, r := range records {
+		b.put(i, r)
+	}
+	return b
+}
+
+// Column returns the values of the named column.
+func (b *ColumnBatch) Column(name string) (ColumnSchema, []byte, bool) {
+	for i, c := range b.Schema {
+		if c.Name == name {
+			return c, b.Columns[i], true
+		}
+	}
+	return ColumnSchema{}, nil, false
+}
+
+// WriteTo writes the columns to w in the order of the schema, it implements
+// io.WriterTo. The columns of a batch are fixed size, so a column starts at
+// the sum of the widths of the columns before it, multiplied by Rows.
+func (b *ColumnBatch) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, column := range b.Columns {
+		n, err := w.Write(column)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (b *ColumnBatch) put(i int, r FlowRecord) {
+	switch r := r.(type) {
+	case *netflow1.FlowRecord:
+		b.putFlow(i, r.SrcAddr, r.DstAddr, r.SrcPort, r.DstPort, r.Protocol, r.Packets, r.Bytes, r.First, r.Last)
+	case *netflow5.FlowRecord:
+		b.putFlow(i, r.SrcAddr, r.DstAddr, r.SrcPort, r.DstPort, r.Protocol, r.Packets, r.Bytes, r.First, r.Last)
+	case *netflow6.FlowRecord:
+		b.putFlow(i, r.SrcAddr, r.DstAddr, r.SrcPort, r.DstPort, r.Protocol, r.Packets, r.Bytes, r.First, r.Last)
+	case *netflow7.FlowRecord:
+		b.putFlow(i, r.SrcAddr, r.DstAddr, r.SrcPort, r.DstPort, r.Protocol, r.Packets, r.Bytes, r.First, r.Last)
+	case *netflow8.ProtoPortRecord:
+		b.putFlow(i, nil, nil, r.SrcPort, r.DstPort, r.Protocol, r.Packets, r.Bytes, r.First, r.Last)
+	case *netflow8.ASRecord:
+		b.putFlow(i, nil, nil, 0, 0, 0, r.Packets, r.Bytes, r.First, r.Last)
+	}
+}
+
+// putFlow sets the values of row i, in the order of ColumnBatchSchema.
+func (b *ColumnBatch) putFlow(i int, src, dst net.IP, srcPort, dstPort uint16, protocol uint8, packets, bytes, first, last uint32) {
+	c := b.Columns
+	write.PutIPv4(c[0][4*i:], src)
+	write.PutIPv4(c[1][4*i:], dst)
+	binary.BigEndian.PutUint16(c[2][2*i:], srcPort)
+	binary.BigEndian.PutUint16(c[3][2*i:], dstPort)
+	c[4][i] = protocol
+	binary.BigEndian.PutUint32(c[5][4*i:], packets)
+	binary.BigEndian.PutUint32(c[6][4*i:], bytes)
+	binary.BigEndian.PutUint32(c[7][4*i:], first)
+	binary.BigEndian.PutUint32(c[8][4*i:], last)
+}
@@ -0,0 +1,65 @@
+package netflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+)
+
+func TestColumnBatch(t *testing.T) {
+	records := []FlowRecord{
+		&netflow7.FlowRecord{
+			SrcAddr: net.IPv4(192, 168, 1, 10), DstAddr: net.IPv4(10, 0, 0, 5),
+			SrcPort: 443, DstPort: 51000, Protocol: 6,
+			Packets: 10, Bytes: 1500, First: 100000, Last: 101000,
+		},
+		&netflow5.FlowRecord{
+			SrcAddr: net.IP{10, 0, 0, 1}, DstAddr: net.IP{10, 0, 0, 2},
+			SrcPort: 53, DstPort: 1053, Protocol: 17,
+			Packets: 1, Bytes: 64, First: 200, Last: 200,
+		},
+		&netflow8.ASRecord{Flows: 3, Packets: 30, Bytes: 4500, First: 100, Last: 300},
+	}
+
+	b := NewColumnBatch(records)
+	if b.Rows != 3 || len(b.Columns) != len(b.Schema) {
+		t.Fatalf("expected 3 rows and %d columns, got %d and %d", len(b.Schema), b.Rows, len(b.Columns))
+	}
+
+	c, data, ok := b.Column("srcAddr")
+	if !ok || c.Type != ColumnIPv4 {
+		t.Fatalf("expected srcAddr column of type %v, got %v (%t)", ColumnIPv4, c.Type, ok)
+	}
+	want := []net.IP{net.IPv4(192, 168, 1, 10), net.IPv4(10, 0, 0, 1), net.IPv4zero}
+	for i, ip := range want {
+		if got := net.IP(data[4*i : 4*i+4]); !got.Equal(ip) {
+			t.Errorf("row %d: expected source address %s, got %s", i, ip, got)
+		}
+	}
+
+	if _, data, _ = b.Column("bytes"); binary.BigEndian.Uint32(data[8:]) != 4500 {
+		t.Errorf("expected 4500 bytes in row 2, got %d", binary.BigEndian.Uint32(data[8:]))
+	}
+	if _, _, ok = b.Column("nextHop"); ok {
+		t.Error("expected no nextHop column")
+	}
+
+	var (
+		buf   bytes.Buffer
+		width int
+	)
+	for _, c := range b.Schema {
+		width += c.Type.Width()
+	}
+	if n, err := b.WriteTo(&buf); err != nil || n != int64(3*width) {
+		t.Fatalf("expected %d bytes written, got %d (%v)", 3*width, n, err)
+	}
+	if !bytes.Equal(buf.Bytes()[:3*4], b.Columns[0]) {
+		t.Errorf("expected the srcAddr column first, got %x", buf.Bytes()[:3*4])
+	}
+}
@@ -0,0 +1,85 @@
+package netflow
+
+import (
+	"encoding/csv"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+	"github.com/tehmaze/netflow/read"
+)
+
+// CSVHeader are the columns written by WriteCSV.
+var CSVHeader = []string{
+	"srcAddr", "dstAddr", "srcPort", "dstPort", "protocol",
+	"packets", "bytes", "first", "last",
+}
+
+// WriteCSV writes a header row followed by one row per record, with the
+// fields common to all versions. The first and last columns contain the
+// SysUptime in milliseconds at the start and end of the flow. Columns that
+// are not available for a record, such as addresses in aggregated NetFlow v8
+// records, are left empty.
+func WriteCSV(w io.Writer, records []FlowRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := cw.Write(csvRow(r)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvRow(r FlowRecord) []string {
+	switch r := r.(type) {
+	case *netflow1.FlowRecord:
+		return csvFlow(r.SrcAddr, r.DstAddr, r.SrcPort, r.DstPort, r.Protocol, r.Packets, r.Bytes, r.First, r.Last)
+	case *netflow5.FlowRecord:
+		return csvFlow(r.SrcAddr, r.DstAddr, r.SrcPort, r.DstPort, r.Protocol, r.Packets, r.Bytes, r.First, r.Last)
+	case *netflow6.FlowRecord:
+		return csvFlow(r.SrcAddr, r.DstAddr, r.SrcPort, r.DstPort, r.Protocol, r.Packets, r.Bytes, r.First, r.Last)
+	case *netflow7.FlowRecord:
+		return csvFlow(r.SrcAddr, r.DstAddr, r.SrcPort, r.DstPort, r.Protocol, r.Packets, r.Bytes, r.First, r.Last)
+	case *netflow8.ProtoPortRecord:
+		return csvFlow(nil, nil, r.SrcPort, r.DstPort, r.Protocol, r.Packets, r.Bytes, r.First, r.Last)
+	case *netflow8.ASRecord:
+		return []string{"", "", "", "", "",
+			csvUint(r.Packets), csvUint(r.Bytes), csvUint(r.First), csvUint(r.Last)}
+	default:
+		return make([]string, len(CSVHeader))
+	}
+}
+
+func csvFlow(src, dst net.IP, srcPort, dstPort uint16, protocol uint8, packets, bytes, first, last uint32) []string {
+	return []string{
+		csvIP(src),
+		csvIP(dst),
+		csvUint(uint32(srcPort)),
+		csvUint(uint32(dstPort)),
+		read.ProtocolName(protocol),
+		csvUint(packets),
+		csvUint(bytes),
+		csvUint(first),
+		csvUint(last),
+	}
+}
+
+func csvUint(v uint32) string {
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+func csvIP(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
@@ -0,0 +1,43 @@
+package netflow
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+)
+
+const testCSV = `srcAddr,dstAddr,srcPort,dstPort,protocol,packets,bytes,first,last
+192.168.1.10,10.0.0.5,443,51000,tcp,10,1500,100000,101000
+10.0.0.1,10.0.0.2,53,1053,udp,1,64,200,200
+,,,,,30,4500,100,300
+,,0,0,icmp,2,168,100,150
+`
+
+func TestWriteCSV(t *testing.T) {
+	records := []FlowRecord{
+		&netflow7.FlowRecord{
+			SrcAddr: net.IPv4(192, 168, 1, 10), DstAddr: net.IPv4(10, 0, 0, 5),
+			SrcPort: 443, DstPort: 51000, Protocol: 6,
+			Packets: 10, Bytes: 1500, First: 100000, Last: 101000,
+		},
+		&netflow5.FlowRecord{
+			SrcAddr: net.IP{10, 0, 0, 1}, DstAddr: net.IP{10, 0, 0, 2},
+			SrcPort: 53, DstPort: 1053, Protocol: 17,
+			Packets: 1, Bytes: 64, First: 200, Last: 200,
+		},
+		&netflow8.ASRecord{Flows: 3, Packets: 30, Bytes: 4500, First: 100, Last: 300},
+		&netflow8.ProtoPortRecord{Protocol: 1, Packets: 2, Bytes: 168, First: 100, Last: 150},
+	}
+
+	b := new(bytes.Buffer)
+	if err := WriteCSV(b, records); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != testCSV {
+		t.Fatalf("expected\n%s\ngot\n%s", testCSV, b.String())
+	}
+}
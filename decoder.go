@@ -3,21 +3,233 @@ package netflow
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/tehmaze/netflow/ipfix"
 	"github.com/tehmaze/netflow/netflow1"
 	"github.com/tehmaze/netflow/netflow5"
 	"github.com/tehmaze/netflow/netflow6"
 	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
 	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/read"
 	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
 )
 
+// ErrUnsupportedVersion is returned if a packet announces a version that can
+// not be decoded, the returned error wraps it along with the version.
+var ErrUnsupportedVersion = errors.New("netflow: unsupported version")
+
+// ErrTooManyRecords is returned if a packet holds more records than allowed,
+// see WithMaxRecords.
+var ErrTooManyRecords = errors.New("netflow: too many records")
+
+// ErrCountMismatch is returned if the Count in a NetFlow v9 packet header does
+// not match the number of records in the packet, see WithStrictCount.
+var ErrCountMismatch = errors.New("netflow: record count mismatch")
+
+// Errors returned while decoding the contents of a packet, the returned error
+// wraps one of them.
+var (
+	// ErrShortPacket is returned if a packet ends before the header and all
+	// records it announces are read.
+	ErrShortPacket = read.ErrShortPacket
+	// ErrInvalidLength is returned if a NetFlow v9 FlowSet or IPFIX Set has
+	// a length inconsistent with its contents.
+	ErrInvalidLength = read.ErrInvalidLength
+	// ErrUnknownTemplate is returned if records can not be decoded because
+	// their template is not known. A Decoder keeps the raw bytes of such
+	// records, or drops them with WithSkipUnknownTemplates.
+	ErrUnknownTemplate = read.ErrUnknownTemplate
+)
+
+// DefaultMaxRecords is the maximum number of records per packet accepted by
+// a Decoder, unless configured otherwise using WithMaxRecords.
+const DefaultMaxRecords = 65535
+
+// DetectVersion returns the version in the first two bytes of a packet. If
+// the version is not supported, the error wraps ErrUnsupportedVersion.
+func DetectVersion(b []byte) (uint16, error) {
+	if err := read.Need(b, 2); err != nil {
+		return 0, err
+	}
+
+	version := binary.BigEndian.Uint16(b)
+	switch version {
+	case netflow1.Version, netflow5.Version, netflow6.Version, netflow7.Version, netflow8.Version, netflow9.Version, ipfix.Version:
+		return version, nil
+	default:
+		return version, fmt.Errorf("%w %d", ErrUnsupportedVersion, version)
+	}
+}
+
+// ErrUnknownLength is returned by ExpectedLength for NetFlow v9 packets, the
+// header does not announce the length of the packet.
+var ErrUnknownLength = errors.New("netflow: packet length unknown")
+
+// ExpectedLength returns the length in bytes of the packet starting with b,
+// as announced by its header: for the fixed layout versions, the header length
+// plus Count records, and for IPFIX, the Length of the message header. Only
+// the header needs to be available, which makes it suitable for framing a
+// stream of packets. For NetFlow v8, the record length depends on the
+// aggregation scheme, of which the AS and Protocol Port schemes are known.
+// NetFlow v9 packets have no length in the header, their length can only be
+// found by reading all FlowSets; an error wrapping ErrUnknownLength is
+// returned.
+func ExpectedLength(b []byte) (int, error) {
+	version, err := DetectVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	if err = read.Need(b, 4); err != nil {
+		return 0, err
+	}
+	count := int(binary.BigEndian.Uint16(b[2:]))
+
+	switch version {
+	case netflow8.Version:
+		// The aggregation scheme follows the engine type and ID.
+		if err = read.Need(b, 23); err != nil {
+			return 0, err
+		}
+		switch b[22] {
+		case netflow8.AggregationAS:
+			return netflow8.HeaderLen + count*netflow8.ASRecordLen, nil
+		case netflow8.AggregationProtoPort:
+			return netflow8.HeaderLen + count*netflow8.ProtoPortRecordLen, nil
+		default:
+			return 0, fmt.Errorf("netflow: unsupported v8 aggregation scheme %d", b[22])
+		}
+
+	case netflow9.Version:
+		return 0, fmt.Errorf("%w: NetFlow v9 packets have no length field", ErrUnknownLength)
+
+	case ipfix.Version:
+		// The length includes the 16 byte message header.
+		if count < 16 {
+			return 0, fmt.Errorf("protocol error: %w: message length %d is shorter than the header", ErrInvalidLength, count)
+		}
+		return count, nil
+
+	default:
+		l := layouts[version]
+		return l.headerLen + count*l.recordLen, nil
+	}
+}
+
 // Decoder for NetFlow messages.
 type Decoder struct {
 	session.Session
+
+	skipUnknownTemplates bool
+	rawBytes             bool
+	maxRecords           int
+	fieldFilter          []translate.Key
+	strictCount          bool
+	bestEffort           bool
+	recordFilter         func(FlowRecord) bool
+	templateTTL          time.Duration
+	logger               Logger
+}
+
+// DecoderOption configures a Decoder.
+type DecoderOption func(*Decoder)
+
+// WithSkipUnknownTemplates drops the NetFlow v9 Data FlowSets and IPFIX Data
+// Sets referencing a template that is not known, in stead of keeping their
+// raw bytes. The number of dropped sets is reported in Packet.Skipped.
+func WithSkipUnknownTemplates(skip bool) DecoderOption {
+	return func(d *Decoder) {
+		d.skipUnknownTemplates = skip
+	}
+}
+
+// WithRawBytes keeps a copy of the wire bytes of each decoded packet in
+// Packet.Raw, and of each fixed layout record in Packet.RawRecords, so they
+// can be forwarded byte-exact without marshaling them again.
+func WithRawBytes(keep bool) DecoderOption {
+	return func(d *Decoder) {
+		d.rawBytes = keep
+	}
+}
+
+// WithFieldFilter only decodes the NetFlow v9 and IPFIX Data Record fields
+// with the given field types (IANA Information Element IDs, for IPFIX). Other
+// fields are skipped by their length, without decoding their value, and are
+// left out of the records.
+func WithFieldFilter(fieldIDs ...uint16) DecoderOption {
+	return func(d *Decoder) {
+		d.fieldFilter = make([]translate.Key, len(fieldIDs))
+		for i, id := range fieldIDs {
+			d.fieldFilter[i] = translate.Key{FieldID: id}
+		}
+	}
+}
+
+// WithMaxRecords limits the number of records a packet may announce (or for
+// IPFIX, contain) to n. Packets exceeding the limit are rejected with
+// ErrTooManyRecords before their records are read.
+func WithMaxRecords(n int) DecoderOption {
+	return func(d *Decoder) {
+		d.maxRecords = n
+	}
+}
+
+// WithStrictCount rejects NetFlow v9 datagrams where the Count in the header
+// does not match the number of records, with ErrCountMismatch. By default, a
+// mismatch is logged as a warning and the packet is decoded, as exporters are
+// not consistent in what they count. Packets with Data FlowSets for unknown
+// templates are not checked.
+func WithStrictCount(strict bool) DecoderOption {
+	return func(d *Decoder) {
+		d.strictCount = strict
+	}
+}
+
+// WithBestEffort skips the NetFlow v9 FlowSets that can not be decoded, in
+// stead of rejecting the datagram, and reports their errors in Packet.Errors.
+// The FlowSets following a malformed FlowSet are found by its length, so
+// decoding stops at a FlowSet length exceeding the datagram. It only applies
+// to datagrams decoded using DecodeBytes.
+func WithBestEffort(bestEffort bool) DecoderOption {
+	return func(d *Decoder) {
+		d.bestEffort = bestEffort
+	}
+}
+
+// WithRecordFilter only keeps the records of the fixed layout versions (1, 5,
+// 6, 7 and 8) for which keep returns true. The filter is called with every
+// decoded record, before it is added to Packet.Records; the number of
+// records left out is reported in Packet.Filtered. The version specific
+// Message still holds all records.
+func WithRecordFilter(keep func(FlowRecord) bool) DecoderOption {
+	return func(d *Decoder) {
+		d.recordFilter = keep
+	}
+}
+
+// WithTemplateTTL expires the NetFlow v9 and IPFIX templates that are not sent
+// again within ttl after they were last sent, see session.Expiring. The data
+// referencing an expired template is treated as data for an unknown template,
+// until the exporter sends the template again. The option applies to the
+// Decoders of a Session, so NewSession(WithTemplateTTL(ttl)) expires the
+// templates of every source. By default, templates are kept forever.
+func WithTemplateTTL(ttl time.Duration) DecoderOption {
+	return func(d *Decoder) {
+		d.templateTTL = ttl
+	}
+}
+
+// WithDecoderLogger sends the warnings of the Decoder to l. By default,
+// nothing is logged.
+func WithDecoderLogger(l Logger) DecoderOption {
+	return func(d *Decoder) {
+		d.logger = l
+	}
 }
 
 // Message generlized interface.
@@ -25,22 +237,59 @@ type Message interface {
 }
 
 // NewDecoder sets up a decoder suitable for reading NetFlow packets.
-func NewDecoder(s session.Session) *Decoder {
-	return &Decoder{s}
+func NewDecoder(s session.Session, opts ...DecoderOption) *Decoder {
+	d := &Decoder{Session: s, maxRecords: DefaultMaxRecords, logger: nopLogger{}}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.templateTTL > 0 && d.Session != nil {
+		d.Session = session.Expiring(d.Session, d.templateTTL)
+	}
+	return d
 }
 
 // Read a single Netflow message from the network. If an error is returned,
 // there is no guarantee the following reads will be succesful.
 func (d *Decoder) Read(r io.Reader) (Message, error) {
-	data := [2]byte{}
-	if _, err := r.Read(data[:]); err != nil {
+	return d.readMessage(r, nil)
+}
+
+// readMessage reads a single message from r. If r reads a complete datagram,
+// it is passed as well, so the datagram length can bound the message.
+func (d *Decoder) readMessage(r io.Reader, datagram []byte) (Message, error) {
+	// All versions start with the version, followed by the record count or
+	// for IPFIX, the message length.
+	data := [4]byte{}
+	if _, err := io.ReadFull(r, data[:]); err != nil {
+		return nil, shortPacket(err)
+	}
+
+	version, err := DetectVersion(data[:])
+	if err != nil {
 		return nil, err
 	}
+	if count := int(binary.BigEndian.Uint16(data[2:])); version != ipfix.Version && count > d.maxRecords {
+		return nil, d.errTooManyRecords(count)
+	}
+	m, err := d.read(version, io.MultiReader(bytes.NewBuffer(data[:]), r), datagram)
+	if err == io.EOF {
+		// The version and count have been read, so the packet is cut short.
+		err = io.ErrUnexpectedEOF
+	}
+	return m, shortPacket(err)
+}
 
-	version := binary.BigEndian.Uint16(data[:])
-	buffer := bytes.NewBuffer(data[:])
-	mr := io.MultiReader(buffer, r)
+// shortPacket wraps an io.ErrUnexpectedEOF with ErrShortPacket. An io.EOF
+// before the first byte of a packet is returned as is, it signals the end of
+// a stream.
+func shortPacket(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: %v", ErrShortPacket, err)
+	}
+	return err
+}
 
+func (d *Decoder) read(version uint16, mr io.Reader, datagram []byte) (Message, error) {
 	switch version {
 	case netflow1.Version:
 		return netflow1.Read(mr)
@@ -54,13 +303,194 @@ func (d *Decoder) Read(r io.Reader) (Message, error) {
 	case netflow7.Version:
 		return netflow7.Read(mr)
 
+	case netflow8.Version:
+		return netflow8.Read(mr)
+
 	case netflow9.Version:
-		return netflow9.Read(mr, d.Session, nil)
+		var t *netflow9.Translate
+		if d.fieldFilter != nil {
+			t = &netflow9.Translate{Translate: d.translate()}
+		}
+		if datagram == nil {
+			p, err := netflow9.Read(mr, d.templateSession(), t)
+			if err != nil {
+				return nil, err
+			}
+			return p, d.checkCount(p)
+		}
+		if d.bestEffort {
+			p, err := netflow9.ReadBytesBestEffort(datagram, d.templateSession(), t)
+			var errs netflow9.FlowSetErrors
+			if err != nil && !errors.As(err, &errs) {
+				return nil, err
+			}
+			if _, err := io.Copy(io.Discard, mr); err != nil {
+				return nil, err
+			}
+			if errs != nil {
+				// The records of the failed FlowSets are missing from
+				// the count.
+				return p, errs
+			}
+			return p, d.checkCount(p)
+		}
+		p, err := netflow9.ReadBytes(datagram, d.templateSession(), t)
+		if err != nil {
+			return nil, err
+		}
+		// All of the datagram is decoded, drain the reader to leave no
+		// trailing bytes.
+		if _, err = io.Copy(io.Discard, mr); err != nil {
+			return nil, err
+		}
+		return p, d.checkCount(p)
 
 	case ipfix.Version:
-		return ipfix.Read(mr, d.Session, nil)
+		var t *ipfix.Translate
+		if d.fieldFilter != nil {
+			t = &ipfix.Translate{Translate: d.translate()}
+		}
+		m, err := ipfix.Read(mr, d.Session, t)
+		if err != nil {
+			return nil, err
+		}
+		if count := ipfixRecordCount(m); count > d.maxRecords {
+			return nil, d.errTooManyRecords(count)
+		}
+		return m, nil
 
 	default:
-		return nil, fmt.Errorf("netflow: unsupported version %d", version)
+		return nil, fmt.Errorf("%w %d", ErrUnsupportedVersion, version)
+	}
+}
+
+// ipfixRecordCount returns the number of data records in an IPFIX message.
+func ipfixRecordCount(m *ipfix.Message) int {
+	var n int
+	for _, ds := range m.DataSets {
+		n += len(ds.Records)
+	}
+	return n
+}
+
+// checkCount compares the Count in a NetFlow v9 packet header with the records
+// in the packet.
+func (d *Decoder) checkCount(p *netflow9.Packet) error {
+	for _, dfs := range p.DataFlowSets {
+		if dfs.Bytes != nil {
+			return nil
+		}
+	}
+	if n := p.RecordCount(); n != int(p.Header.Count) {
+		if d.strictCount {
+			return fmt.Errorf("%w: header announces %d records, packet has %d", ErrCountMismatch, p.Header.Count, n)
+		}
+		d.logger.Warnf("netflow: v9 source ID %d: header announces %d records, packet has %d", p.Header.SourceID, p.Header.Count, n)
+	}
+	return nil
+}
+
+// templateSession returns the session used to decode NetFlow v9 packets. When
+// logging, template redefinitions changing the fields are logged as warnings.
+func (d *Decoder) templateSession() session.Session {
+	if _, nop := d.logger.(nopLogger); nop || d.Session == nil {
+		return d.Session
+	}
+	return templateWatch{Session: d.Session, logger: d.logger}
+}
+
+// templateWatch is a session logging NetFlow v9 template redefinitions that
+// change the fields of the template, see netflow9.TemplateDiff.
+type templateWatch struct {
+	session.Session
+	domain uint32
+	logger Logger
+}
+
+func (s templateWatch) Domain(id uint32) session.Session {
+	ds, ok := s.Session.(session.DomainSession)
+	if !ok {
+		return s
+	}
+	return templateWatch{Session: ds.Domain(id), domain: id, logger: s.logger}
+}
+
+func (s templateWatch) AddTemplate(t session.Template) {
+	if tr, ok := t.(netflow9.TemplateRecord); ok {
+		if old, ok := s.GetTemplate(tr.TemplateID); ok {
+			if otr, ok := old.(netflow9.TemplateRecord); ok {
+				if changes := netflow9.TemplateDiff(otr, tr); len(changes) > 0 {
+					s.logger.Warnf("netflow: v9 source ID %d: template %d redefined: %v", s.domain, tr.TemplateID, changes)
+				}
+			}
+		}
+	}
+	s.Session.AddTemplate(t)
+}
+
+// translate returns a translator applying the field filter.
+func (d *Decoder) translate() *translate.Translate {
+	return translate.NewTranslate(d.Session).WithFieldFilter(d.fieldFilter...)
+}
+
+func (d *Decoder) errTooManyRecords(count int) error {
+	return fmt.Errorf("%w: %d records, at most %d allowed", ErrTooManyRecords, count, d.maxRecords)
+}
+
+// Decode reads one complete NetFlow packet, the header and all its records,
+// from a stream. When the stream is exhausted, io.EOF is returned.
+func (d *Decoder) Decode(r io.Reader) (*Packet, error) {
+	return d.decode(r, nil)
+}
+
+func (d *Decoder) decode(r io.Reader, datagram []byte) (*Packet, error) {
+	var raw *bytes.Buffer
+	if d.rawBytes {
+		raw = new(bytes.Buffer)
+		r = io.TeeReader(r, raw)
+	}
+	m, err := d.readMessage(r, datagram)
+	var errs netflow9.FlowSetErrors
+	if errors.As(err, &errs) && m != nil {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := newPacket(m)
+	for _, err := range errs {
+		p.Errors = append(p.Errors, err)
+	}
+	if raw != nil {
+		p.setRaw(raw.Bytes())
+	}
+	if d.recordFilter != nil {
+		p.filterRecords(d.recordFilter)
+	}
+	if d.skipUnknownTemplates {
+		switch m := m.(type) {
+		case *netflow9.Packet:
+			p.Skipped = m.SkipUnresolved()
+		case *ipfix.Message:
+			p.Skipped = m.SkipUnresolved()
+		}
+	}
+	return p, nil
+}
+
+// DecodeBytes decodes one complete NetFlow packet from a single datagram.
+// Decoding stops after the number of records announced in the header, any
+// bytes following the last record are available via Packet.Trailing. NetFlow
+// v9 FlowSets are decoded up to the end of the datagram, whatever the Count
+// in the header, see WithStrictCount.
+func (d *Decoder) DecodeBytes(b []byte) (*Packet, error) {
+	r := bytes.NewReader(b)
+	p, err := d.decode(r, b)
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() > 0 {
+		p.trailing = append([]byte(nil), b[len(b)-r.Len():]...)
 	}
+	return p, nil
 }
@@ -0,0 +1,499 @@
+package netflow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/read"
+	"github.com/tehmaze/netflow/session"
+)
+
+// testPacketV7 builds a NetFlow v7 packet with the provided records.
+func testPacketV7(t testing.TB, seq uint32, records ...*netflow7.FlowRecord) []byte {
+	h := netflow7.PacketHeader{
+		Version:      netflow7.Version,
+		Count:        uint16(len(records)),
+		SysUptime:    100 * time.Second,
+		Unix:         time.Unix(1577836800, 0),
+		FlowSequence: seq,
+	}
+
+	b := new(bytes.Buffer)
+	if err := h.Marshal(b); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := r.Marshal(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b.Bytes()
+}
+
+func TestDecoderDecode(t *testing.T) {
+	stream := new(bytes.Buffer)
+	stream.Write(testPacketV7(t, 1, &netflow7.FlowRecord{
+		SrcAddr: net.IPv4(192, 168, 1, 1),
+		SrcPort: 443,
+	}))
+	stream.Write(testPacketV7(t, 2,
+		&netflow7.FlowRecord{SrcPort: 80},
+		&netflow7.FlowRecord{SrcPort: 8080},
+	))
+
+	d := NewDecoder(session.New())
+	for i, count := range []int{1, 2} {
+		p, err := d.Decode(stream)
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if _, ok := p.Message.(*netflow7.Packet); !ok {
+			t.Fatalf("packet %d: expected *netflow7.Packet, got %T", i, p.Message)
+		}
+		if h, ok := p.Header.(*netflow7.PacketHeader); !ok {
+			t.Fatalf("packet %d: expected *netflow7.PacketHeader, got %T", i, p.Header)
+		} else if h.FlowSequence != uint32(i+1) {
+			t.Errorf("packet %d: expected sequence %d, got %d", i, i+1, h.FlowSequence)
+		}
+		if len(p.Records) != count {
+			t.Fatalf("packet %d: expected %d records, got %d", i, count, len(p.Records))
+		}
+	}
+
+	if _, err := d.Decode(stream); err != io.EOF {
+		t.Fatalf("expected io.EOF at end of stream, got %v", err)
+	}
+}
+
+func TestDetectVersion(t *testing.T) {
+	if v, err := DetectVersion([]byte{0x00, 0x07, 0x00, 0x01}); err != nil || v != netflow7.Version {
+		t.Fatalf("expected version 7, got %d (%v)", v, err)
+	}
+
+	v, err := DetectVersion([]byte{0x00, 0x63})
+	if !errors.Is(err, ErrUnsupportedVersion) || v != 99 {
+		t.Fatalf("expected unsupported version 99, got %d (%v)", v, err)
+	}
+
+	if _, err = DetectVersion([]byte{0x00}); !errors.Is(err, read.ErrShortPacket) {
+		t.Fatalf("expected short packet error, got %v", err)
+	}
+}
+
+func TestDecoderUnsupportedVersion(t *testing.T) {
+	d := NewDecoder(session.New())
+	_, err := d.Decode(bytes.NewReader([]byte{0x00, 0x63, 0x00, 0x01}))
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected unsupported version error, got %v", err)
+	}
+	if err.Error() != "netflow: unsupported version 99" {
+		t.Fatalf("unexpected error %q", err)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	invalid := testPacketV9()
+	invalid[23] = 0x02 // flowset length shorter than its header
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"v5 short header", testPacketV5(1)[:12], ErrShortPacket},
+		{"v5 short record", testPacketV5(2)[:24+48+10], ErrShortPacket},
+		{"v5 missing record", testPacketV5(2)[:24+48], ErrShortPacket},
+		{"v9 short flowset", testPacketV9()[:30], ErrShortPacket},
+		{"v9 short version", []byte{0x00, 0x09, 0x00}, ErrShortPacket},
+		{"v9 invalid length", invalid, ErrInvalidLength},
+		{"unsupported", []byte{0x00, 0x63, 0x00, 0x01}, ErrUnsupportedVersion},
+	}
+	for _, test := range tests {
+		_, err := NewDecoder(session.New()).Decode(bytes.NewReader(test.data))
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, err)
+		}
+	}
+
+	// An empty stream is not an error in a packet.
+	if _, err := NewDecoder(session.New()).Decode(bytes.NewReader(nil)); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+
+	// A stalled stream is reported as such, not as a short packet.
+	r := io.MultiReader(bytes.NewReader(testPacketV5(2)[:40]), iotest.ErrReader(os.ErrDeadlineExceeded))
+	if _, err := NewDecoder(session.New()).Decode(r); !errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, ErrShortPacket) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestPacketString(t *testing.T) {
+	data := testPacketV7(t, 42, &netflow7.FlowRecord{SrcPort: 80}, &netflow7.FlowRecord{SrcPort: 443})
+	p, err := NewDecoder(session.New()).Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := p.String(); s != "v7 seq=42 records=2 uptime=1m40s" {
+		t.Fatalf("unexpected summary %q", s)
+	}
+}
+
+// testPacketV9 builds a NetFlow v9 packet, with a Data FlowSet for unknown
+// template 300, followed by a Template FlowSet for template 256 and a Data
+// FlowSet using it.
+func testPacketV9() []byte {
+	return []byte{
+		0x00, 0x09, 0x00, 0x03, // version 9, count 3
+		0x00, 0x01, 0x86, 0xa0, // SysUpTime
+		0x5e, 0x0b, 0xe1, 0x00, // UnixSecs
+		0x00, 0x00, 0x00, 0x01, // SequenceNumber
+		0x00, 0x00, 0x00, 0x01, // SourceID
+		0x01, 0x2c, 0x00, 0x08, // data flowset for template 300
+		0xde, 0xad, 0xbe, 0xef,
+		0x00, 0x00, 0x00, 0x10, // template flowset
+		0x01, 0x00, 0x00, 0x02, // template 256, 2 fields
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		0x00, 0x07, 0x00, 0x02, // sourceTransportPort
+		0x01, 0x00, 0x00, 0x0c, // data flowset for template 256
+		0xc0, 0x00, 0x02, 0x01,
+		0x00, 0x50, 0x00, 0x00, // port 80, padding
+	}
+}
+
+func TestDecoderSkipUnknownTemplates(t *testing.T) {
+	p, err := NewDecoder(session.New()).Decode(bytes.NewReader(testPacketV9()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := p.Message.(*netflow9.Packet); len(m.DataFlowSets) != 2 || m.DataFlowSets[0].Bytes == nil || p.Skipped != 0 {
+		t.Fatalf("expected unresolved data flowset to be kept, got %d flowsets, %d skipped", len(m.DataFlowSets), p.Skipped)
+	}
+
+	p, err = NewDecoder(session.New(), WithSkipUnknownTemplates(true)).Decode(bytes.NewReader(testPacketV9()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Skipped != 1 {
+		t.Fatalf("expected 1 skipped flowset, got %d", p.Skipped)
+	}
+	m := p.Message.(*netflow9.Packet)
+	if len(m.Templates()) != 1 {
+		t.Fatalf("expected template to be learned, got %v", m.Templates())
+	}
+	if drs := m.DataRecords(); len(m.DataFlowSets) != 1 || len(drs) != 1 || len(drs[0].Fields) != 2 {
+		t.Fatalf("expected 1 data record, got %v", drs)
+	}
+}
+
+func TestDecoderDecodeBytesTrailing(t *testing.T) {
+	data := append(testPacketV5(2), make([]byte, 16)...)
+
+	p, err := NewDecoder(session.New()).DecodeBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(p.Records))
+	}
+	if r := p.Records[1].(*netflow5.FlowRecord); r.SrcPort != 1025 {
+		t.Errorf("expected source port 1025, got %d", r.SrcPort)
+	}
+	if !bytes.Equal(p.Trailing(), make([]byte, 16)) {
+		t.Errorf("expected 16 trailing zero bytes, got %x", p.Trailing())
+	}
+
+	p, err = NewDecoder(session.New()).DecodeBytes(testPacketV5(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Trailing() != nil {
+		t.Errorf("expected no trailing bytes, got %x", p.Trailing())
+	}
+}
+
+func TestDecoderMaxRecords(t *testing.T) {
+	d := NewDecoder(session.New(), WithMaxRecords(10))
+	if _, err := d.DecodeBytes(testPacketV5(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := d.DecodeBytes(testPacketV5(11))
+	if !errors.Is(err, ErrTooManyRecords) {
+		t.Fatalf("expected ErrTooManyRecords, got %v", err)
+	}
+
+	// The header is rejected before the records are read.
+	_, err = d.Decode(bytes.NewReader([]byte{0x00, 0x09, 0xff, 0xff}))
+	if !errors.Is(err, ErrTooManyRecords) {
+		t.Fatalf("expected ErrTooManyRecords, got %v", err)
+	}
+}
+
+func TestDecoderRawBytes(t *testing.T) {
+	data := testPacketV5(3)
+	d := NewDecoder(session.New(), WithRawBytes(true))
+	p, err := d.DecodeBytes(append(data, make([]byte, 8)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p.Raw, data) {
+		t.Errorf("expected raw packet %x, got %x", data, p.Raw)
+	}
+	if len(p.RawRecords) != 3 {
+		t.Fatalf("expected 3 raw records, got %d", len(p.RawRecords))
+	}
+	for i, raw := range p.RawRecords {
+		o := 24 + i*48
+		if !bytes.Equal(raw, data[o:o+48]) {
+			t.Errorf("record %d: expected raw bytes %x, got %x", i, data[o:o+48], raw)
+		}
+	}
+
+	data = testPacketV9()
+	if p, err = d.DecodeBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p.Raw, data) {
+		t.Errorf("expected raw packet %x, got %x", data, p.Raw)
+	}
+
+	if p, err = NewDecoder(session.New()).DecodeBytes(testPacketV5(1)); err != nil {
+		t.Fatal(err)
+	}
+	if p.Raw != nil || p.RawRecords != nil {
+		t.Error("expected no raw bytes without WithRawBytes")
+	}
+}
+
+func TestDecoderFieldFilter(t *testing.T) {
+	d := NewDecoder(session.New(), WithFieldFilter(8, 7, 1))
+	p, err := d.DecodeBytes(testPacketV9Records(t, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	drs := p.Message.(*netflow9.Packet).DataRecords()
+	if len(drs) != 3 {
+		t.Fatalf("expected 3 data records, got %d", len(drs))
+	}
+	for i, dr := range drs {
+		if len(dr.Fields) != 3 {
+			t.Fatalf("record %d: expected 3 fields, got %d", i, len(dr.Fields))
+		}
+		var values []string
+		for _, f := range dr.Fields {
+			if f.Translated == nil {
+				t.Fatalf("record %d: field %d is not translated", i, f.Type)
+			}
+			values = append(values, f.Translated.String())
+		}
+		want := fmt.Sprintf("[sourceIPv4Address=192.168.1.%d sourceTransportPort=%d octetDeltaCount=1500]", i+1, 1024+i)
+		if got := fmt.Sprint(values); got != want {
+			t.Errorf("record %d: expected %s, got %s", i, want, got)
+		}
+	}
+}
+
+func TestDecoderStrictCount(t *testing.T) {
+	data := testPacketV9Records(t, 2)
+	for _, strict := range []bool{false, true} {
+		l := new(testLogger)
+		p, err := NewDecoder(session.New(), WithStrictCount(strict), WithDecoderLogger(l)).DecodeBytes(data)
+		if err != nil {
+			t.Fatalf("strict=%t: %v", strict, err)
+		}
+		if n := len(p.Message.(*netflow9.Packet).DataRecords()); n != 2 || len(l.warn) != 0 {
+			t.Errorf("strict=%t: expected 2 records without warnings, got %d and %q", strict, n, l.warn)
+		}
+	}
+
+	// The count of 1 covers the template only, the Data FlowSet is decoded
+	// regardless.
+	for _, count := range []byte{1, 2} {
+		wrong := append([]byte(nil), data...)
+		wrong[3] = count
+
+		l := new(testLogger)
+		p, err := NewDecoder(session.New(), WithDecoderLogger(l)).DecodeBytes(wrong)
+		if err != nil {
+			t.Fatalf("count %d: %v", count, err)
+		}
+		if n := len(p.Message.(*netflow9.Packet).DataRecords()); n != 2 {
+			t.Errorf("count %d: expected 2 records, got %d", count, n)
+		}
+		if len(l.warn) != 1 || !strings.Contains(l.warn[0], "announces "+strconv.Itoa(int(count))+" records, packet has 3") {
+			t.Errorf("count %d: expected count mismatch warning, got %q", count, l.warn)
+		}
+
+		_, err = NewDecoder(session.New(), WithStrictCount(true)).DecodeBytes(wrong)
+		if !errors.Is(err, ErrCountMismatch) {
+			t.Errorf("count %d: expected ErrCountMismatch, got %v", count, err)
+		}
+	}
+}
+
+func TestDecoderTemplateRedefinition(t *testing.T) {
+	var (
+		l = new(testLogger)
+		d = NewDecoder(session.New(), WithDecoderLogger(l))
+	)
+	if _, err := d.DecodeBytes(testPacketV9()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.DecodeBytes(testPacketV9()); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.warn) != 0 {
+		t.Fatalf("expected no warnings for a template sent again, got %q", l.warn)
+	}
+
+	// Template 256 now has the destination port instead of the source port.
+	data := testPacketV9()
+	data[41] = 0x0b
+	p, err := d.DecodeBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.warn) != 1 || !strings.Contains(l.warn[0], "template 256 redefined: [removed field 7/2 at 1 added field 11/2 at 1]") {
+		t.Fatalf("expected redefinition warning, got %q", l.warn)
+	}
+	if f := p.Message.(*netflow9.Packet).DataRecords()[0].Fields[1]; f.Type != 11 {
+		t.Errorf("expected the new template to be used, got field %d", f.Type)
+	}
+}
+
+func TestDecoderBestEffort(t *testing.T) {
+	data := []byte{
+		0x00, 0x09, 0x00, 0x03, // version 9, count 3
+		0x00, 0x01, 0x86, 0xa0, // SysUpTime
+		0x5e, 0x0b, 0xe1, 0x00, // UnixSecs
+		0x00, 0x00, 0x00, 0x01, // SequenceNumber
+		0x00, 0x00, 0x00, 0x01, // SourceID
+		0x00, 0x00, 0x00, 0x10, // template flowset
+		0x01, 0x00, 0x00, 0x02, // template 256, 2 fields
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		0x00, 0x07, 0x00, 0x02, // sourceTransportPort
+		0x01, 0x00, 0x00, 0x0e, // data flowset for template 256, 1.66 records
+		0xc0, 0x00, 0x02, 0x01,
+		0x00, 0x50, 0xc0, 0x00,
+		0x02, 0x02,
+		0x01, 0x00, 0x00, 0x0c, // data flowset for template 256
+		0xc0, 0x00, 0x02, 0x03,
+		0x00, 0x51, 0x00, 0x00, // port 81, padding
+	}
+	if _, err := NewDecoder(session.New()).DecodeBytes(data); !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("expected %v, got %v", ErrInvalidLength, err)
+	}
+
+	p, err := NewDecoder(session.New(), WithBestEffort(true)).DecodeBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Errors) != 1 || !errors.Is(p.Errors[0], ErrInvalidLength) {
+		t.Fatalf("expected 1 invalid length error, got %v", p.Errors)
+	}
+	var fse *netflow9.FlowSetError
+	if !errors.As(p.Errors[0], &fse) || fse.ID != 256 || fse.Offset != 36 {
+		t.Errorf("expected error for flowset 256 at offset 36, got %v", p.Errors[0])
+	}
+	m := p.Message.(*netflow9.Packet)
+	if len(m.Templates()) != 1 {
+		t.Fatalf("expected template to be learned, got %v", m.Templates())
+	}
+	drs := m.DataRecords()
+	if len(drs) != 1 {
+		t.Fatalf("expected 1 data record, got %v", drs)
+	}
+	if port := drs[0].Fields[1].Bytes; port[1] != 81 {
+		t.Errorf("expected the record of the last flowset, got port %v", port)
+	}
+}
+
+func TestExpectedLength(t *testing.T) {
+	v8 := make([]byte, 28)
+	v8[1], v8[3], v8[22] = 8, 2, netflow8.AggregationAS
+
+	tests := []struct {
+		name string
+		b    []byte
+		want int
+	}{
+		{"v1", []byte{0x00, 0x01, 0x00, 0x02}, netflow1.HeaderLen + 2*netflow1.RecordLen},
+		{"v5", testPacketV5(3)[:netflow5.HeaderLen], netflow5.HeaderLen + 3*netflow5.RecordLen},
+		{"v6", []byte{0x00, 0x06, 0x00, 0x01}, netflow6.HeaderLen + netflow6.RecordLen},
+		{"v7", []byte{0x00, 0x07, 0x00, 0x1b}, netflow7.HeaderLen + 27*netflow7.RecordLen},
+		{"v8", v8, netflow8.HeaderLen + 2*netflow8.ASRecordLen},
+		{"ipfix", []byte{0x00, 0x0a, 0x00, 0x38}, 56},
+	}
+	for _, test := range tests {
+		n, err := ExpectedLength(test.b)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if n != test.want {
+			t.Errorf("%s: expected %d bytes, got %d", test.name, test.want, n)
+		}
+	}
+
+	if _, err := ExpectedLength(testPacketV9()); !errors.Is(err, ErrUnknownLength) {
+		t.Errorf("v9: expected %v, got %v", ErrUnknownLength, err)
+	}
+	if _, err := ExpectedLength([]byte{0x00, 0x0a, 0x00, 0x08}); !errors.Is(err, ErrInvalidLength) {
+		t.Errorf("ipfix: expected %v, got %v", ErrInvalidLength, err)
+	}
+	if _, err := ExpectedLength(v8[:20]); !errors.Is(err, ErrShortPacket) {
+		t.Errorf("v8: expected %v, got %v", ErrShortPacket, err)
+	}
+	if _, err := ExpectedLength([]byte{0x00, 0x05}); !errors.Is(err, ErrShortPacket) {
+		t.Errorf("v5: expected %v, got %v", ErrShortPacket, err)
+	}
+	if _, err := ExpectedLength([]byte{0x00, 0x04, 0x00, 0x01}); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("v4: expected %v, got %v", ErrUnsupportedVersion, err)
+	}
+}
+
+func TestDecoderRecordFilter(t *testing.T) {
+	data := testPacketV5(4)
+	for i, protocol := range []byte{6, 17, 6, 1} {
+		data[netflow5.HeaderLen+i*netflow5.RecordLen+38] = protocol
+	}
+
+	tcp := func(r FlowRecord) bool {
+		key, ok := KeyOf(r)
+		return ok && key.Protocol == 6
+	}
+	p, err := NewDecoder(session.New(), WithRecordFilter(tcp), WithRawBytes(true)).DecodeBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Records) != 2 || p.Filtered != 2 {
+		t.Fatalf("expected 2 records and 2 filtered, got %d and %d", len(p.Records), p.Filtered)
+	}
+	for i, r := range p.Records {
+		if r := r.(*netflow5.FlowRecord); r.Protocol != 6 || r.SrcPort != uint16(1024+2*i) {
+			t.Errorf("record %d: expected TCP flow from port %d, got %s", i, 1024+2*i, r)
+		}
+		if want := data[netflow5.HeaderLen+2*i*netflow5.RecordLen:][:netflow5.RecordLen]; !bytes.Equal(p.RawRecords[i], want) {
+			t.Errorf("record %d: expected raw bytes of the record", i)
+		}
+	}
+	if n := len(p.Message.(*netflow5.Packet).Records); n != 4 {
+		t.Errorf("expected the message to hold all 4 records, got %d", n)
+	}
+	if n := recordCount(p); n != 4 {
+		t.Errorf("expected filtered records to be counted for sequence tracking, got %d", n)
+	}
+}
@@ -0,0 +1,51 @@
+package netflow
+
+import "fmt"
+
+// FlowDirection is the direction in which a flow was observed on the
+// interface, as reported in the flowDirection (61) information element.
+type FlowDirection uint8
+
+// Flow directions
+const (
+	Ingress FlowDirection = 0
+	Egress  FlowDirection = 1
+)
+
+func (d FlowDirection) String() string {
+	switch d {
+	case Ingress:
+		return "ingress"
+	case Egress:
+		return "egress"
+	default:
+		return fmt.Sprintf("FlowDirection(%d)", uint8(d))
+	}
+}
+
+// Direction returns the direction the flow was observed in. If the record has
+// no flowDirection field, ok is false.
+func (r GenericRecord) Direction() (d FlowDirection, ok bool) {
+	v, ok := genericUint(r, 61)
+	return FlowDirection(v), ok
+}
+
+// CanonicalDirection returns the ingress record, if a and b are the same flow
+// (having the same FlowKey) observed once on ingress and once on egress, for
+// example by an exporter metering both the incoming and outgoing interface.
+// Counting only the canonical record avoids counting the flow twice. If the
+// records are not such a pair, ok is false.
+func CanonicalDirection(a, b GenericRecord) (canonical GenericRecord, ok bool) {
+	da, oka := a.Direction()
+	db, okb := b.Direction()
+	if !oka || !okb || da == db || a.FlowKey() != b.FlowKey() {
+		return nil, false
+	}
+	if da == Ingress {
+		return a, true
+	}
+	if db == Ingress {
+		return b, true
+	}
+	return nil, false
+}
@@ -0,0 +1,60 @@
+package netflow
+
+import (
+	"testing"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+func TestCanonicalDirection(t *testing.T) {
+	p, err := NewDecoder(session.New()).DecodeBytes([]byte{
+		0x00, 0x0a, 0x00, 0x54, // version 10, length 84
+		0x5e, 0x0b, 0xe1, 0x00, // Export Time
+		0x00, 0x00, 0x00, 0x01, // Sequence Number
+		0x00, 0x00, 0x00, 0x07, // Observation Domain ID
+		0x00, 0x02, 0x00, 0x24, // template set
+		0x01, 0x00, 0x00, 0x07, // template 256, 7 fields
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		0x00, 0x0c, 0x00, 0x04, // destinationIPv4Address
+		0x00, 0x04, 0x00, 0x01, // protocolIdentifier
+		0x00, 0x0a, 0x00, 0x01, // ingressInterface
+		0x00, 0x0e, 0x00, 0x01, // egressInterface
+		0x00, 0x3d, 0x00, 0x01, // flowDirection
+		0x00, 0x01, 0x00, 0x01, // octetDeltaCount
+		0x01, 0x00, 0x00, 0x20, // data set for template 256
+		0xc0, 0x00, 0x02, 0x01, // 192.0.2.1
+		0xc6, 0x33, 0x64, 0x01, // 198.51.100.1
+		0x06, 0x01, 0x02, // tcp, from interface 1 to 2
+		0x01, 0x64, // egress, 100 bytes
+		0xc0, 0x00, 0x02, 0x01, // 192.0.2.1
+		0xc6, 0x33, 0x64, 0x01, // 198.51.100.1
+		0x06, 0x01, 0x02, // tcp, from interface 1 to 2
+		0x00, 0x64, // ingress, 100 bytes
+		0x00, 0x00, // padding
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := GenericRecords(p.Message)
+	if len(rs) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(rs))
+	}
+	if d, ok := rs[0].Direction(); !ok || d != Egress {
+		t.Errorf("expected %v, got %v", Egress, d)
+	}
+
+	r, ok := CanonicalDirection(rs[0], rs[1])
+	if !ok {
+		t.Fatal("expected the records to be the same flow in both directions")
+	}
+	if d, _ := r.Direction(); d != Ingress {
+		t.Errorf("expected the ingress record to be canonical, got %v", d)
+	}
+	if _, ok = CanonicalDirection(rs[1], rs[1]); ok {
+		t.Error("expected two ingress records to not be a pair")
+	}
+	other := append(GenericRecord{{FieldID: 4, Value: uint8(17)}}, rs[1]...)
+	if _, ok = CanonicalDirection(rs[0], other); ok {
+		t.Error("expected records of different flows to not be a pair")
+	}
+}
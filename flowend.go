@@ -0,0 +1,81 @@
+package netflow
+
+import "fmt"
+
+// FlowEndReason is the reason a flow ended, as reported in the flowEndReason
+// (136) information element.
+type FlowEndReason uint8
+
+// Flow end reasons (RFC 5102 section 5.11.3)
+const (
+	FlowEndIdleTimeout     FlowEndReason = 0x01
+	FlowEndActiveTimeout   FlowEndReason = 0x02
+	FlowEndOfFlow          FlowEndReason = 0x03
+	FlowEndForced          FlowEndReason = 0x04
+	FlowEndLackOfResources FlowEndReason = 0x05
+)
+
+func (r FlowEndReason) String() string {
+	switch r {
+	case FlowEndIdleTimeout:
+		return "idle timeout"
+	case FlowEndActiveTimeout:
+		return "active timeout"
+	case FlowEndOfFlow:
+		return "end of flow"
+	case FlowEndForced:
+		return "forced end"
+	case FlowEndLackOfResources:
+		return "lack of resources"
+	default:
+		return fmt.Sprintf("FlowEndReason(%d)", uint8(r))
+	}
+}
+
+// FlowEndReason returns the reason the flow ended. If the record has no
+// flowEndReason field, ok is false.
+func (r GenericRecord) FlowEndReason() (reason FlowEndReason, ok bool) {
+	v, ok := genericUint(r, 136)
+	return FlowEndReason(v), ok
+}
+
+// FirewallEvent is the state of a connection through a firewall, as reported
+// in the firewallEvent (233) information element by Cisco ASA (NSEL) and
+// other firewalls.
+type FirewallEvent uint8
+
+// Firewall events
+const (
+	FirewallEventIgnore FirewallEvent = 0
+	FirewallEventCreate FirewallEvent = 1
+	FirewallEventDelete FirewallEvent = 2
+	FirewallEventDeny   FirewallEvent = 3
+	FirewallEventAlert  FirewallEvent = 4
+	FirewallEventUpdate FirewallEvent = 5
+)
+
+func (e FirewallEvent) String() string {
+	switch e {
+	case FirewallEventIgnore:
+		return "ignore"
+	case FirewallEventCreate:
+		return "flow created"
+	case FirewallEventDelete:
+		return "flow deleted"
+	case FirewallEventDeny:
+		return "flow denied"
+	case FirewallEventAlert:
+		return "flow alert"
+	case FirewallEventUpdate:
+		return "flow update"
+	default:
+		return fmt.Sprintf("FirewallEvent(%d)", uint8(e))
+	}
+}
+
+// FirewallEvent returns the connection state reported by a firewall. If the
+// record has no firewallEvent field, ok is false.
+func (r GenericRecord) FirewallEvent() (event FirewallEvent, ok bool) {
+	v, ok := genericUint(r, 233)
+	return FirewallEvent(v), ok
+}
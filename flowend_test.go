@@ -0,0 +1,45 @@
+package netflow
+
+import (
+	"testing"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+func TestGenericRecordFlowEndReason(t *testing.T) {
+	p, err := NewDecoder(session.New()).DecodeBytes([]byte{
+		0x00, 0x09, 0x00, 0x02, // version 9, count 2
+		0x00, 0x01, 0x86, 0xa0, // SysUpTime
+		0x5e, 0x0b, 0xe1, 0x00, // UnixSecs
+		0x00, 0x00, 0x00, 0x01, // SequenceNumber
+		0x00, 0x00, 0x00, 0x01, // SourceID
+		0x00, 0x00, 0x00, 0x14, // template flowset
+		0x01, 0x00, 0x00, 0x03, // template 256, 3 fields
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		0x00, 0x88, 0x00, 0x01, // flowEndReason
+		0x00, 0xe9, 0x00, 0x01, // firewallEvent
+		0x01, 0x00, 0x00, 0x0c, // data flowset for template 256
+		0xc0, 0x00, 0x02, 0x01,
+		0x02, 0x02, 0x00, 0x00, // active timeout, flow deleted, padding
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := GenericRecords(p.Message)
+	if len(rs) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(rs))
+	}
+	if reason, ok := rs[0].FlowEndReason(); !ok || reason != FlowEndActiveTimeout {
+		t.Errorf("expected %v, got %v", FlowEndActiveTimeout, reason)
+	}
+	if s := FlowEndActiveTimeout.String(); s != "active timeout" {
+		t.Errorf("unexpected flow end reason %q", s)
+	}
+	if event, ok := rs[0].FirewallEvent(); !ok || event != FirewallEventDelete {
+		t.Errorf("expected %v, got %v", FirewallEventDelete, event)
+	}
+
+	if _, ok := (GenericRecord{}).FlowEndReason(); ok {
+		t.Error("expected no flow end reason")
+	}
+}
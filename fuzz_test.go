@@ -0,0 +1,167 @@
+package netflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+	"github.com/tehmaze/netflow/session"
+)
+
+// testPacketFixed builds a fixed layout packet with count zeroed records.
+func testPacketFixed(version uint16, headerLen, recordLen, count int) []byte {
+	b := make([]byte, headerLen+count*recordLen)
+	binary.BigEndian.PutUint16(b[0:], version)
+	binary.BigEndian.PutUint16(b[2:], uint16(count))
+	return b
+}
+
+// fuzzDecode decodes data and checks a successfully decoded packet of a fixed
+// layout version fits in data.
+func fuzzDecode(t *testing.T, data []byte) {
+	p, err := NewDecoder(session.New()).DecodeBytes(data)
+	if err != nil {
+		return
+	}
+
+	var size int
+	switch m := p.Message.(type) {
+	case *netflow1.Packet:
+		size = netflow1.HeaderLen + len(m.Records)*netflow1.RecordLen
+	case *netflow5.Packet:
+		size = netflow5.HeaderLen + len(m.Records)*netflow5.RecordLen
+	case *netflow6.Packet:
+		size = netflow6.HeaderLen + len(m.Records)*netflow6.RecordLen
+	case *netflow7.Packet:
+		size = netflow7.HeaderLen + len(m.Records)*netflow7.RecordLen
+	case *netflow8.Packet:
+		size = netflow8.HeaderLen
+		for _, r := range m.Records {
+			size += r.Len()
+		}
+	default:
+		return
+	}
+	if size > len(data) {
+		t.Fatalf("decoded %d bytes from %d bytes of input", size, len(data))
+	}
+	if size+len(p.Trailing()) != len(data) {
+		t.Fatalf("decoded %d bytes with %d trailing from %d bytes of input", size, len(p.Trailing()), len(data))
+	}
+}
+
+func FuzzUnmarshalV1(f *testing.F) {
+	f.Add(testPacketFixed(netflow1.Version, netflow1.HeaderLen, netflow1.RecordLen, 1))
+	f.Add(testPacketFixed(netflow1.Version, netflow1.HeaderLen, netflow1.RecordLen, 24))
+	f.Fuzz(fuzzDecode)
+}
+
+func FuzzUnmarshalV5(f *testing.F) {
+	f.Add(testPacketV5(1))
+	f.Add(testPacketV5(30))
+	f.Fuzz(fuzzDecode)
+}
+
+func FuzzUnmarshalV6(f *testing.F) {
+	f.Add(testPacketFixed(netflow6.Version, netflow6.HeaderLen, netflow6.RecordLen, 1))
+	f.Add(testPacketFixed(netflow6.Version, netflow6.HeaderLen, netflow6.RecordLen, 27))
+	f.Fuzz(fuzzDecode)
+}
+
+func FuzzUnmarshalV7(f *testing.F) {
+	f.Add(testPacketV7(f, 1, &netflow7.FlowRecord{SrcPort: 80}))
+	f.Add(testPacketFixed(netflow7.Version, netflow7.HeaderLen, netflow7.RecordLen, 27))
+	f.Fuzz(fuzzDecode)
+}
+
+func FuzzUnmarshalV8(f *testing.F) {
+	for _, aggregation := range []byte{netflow8.AggregationAS, netflow8.AggregationProtoPort} {
+		b := testPacketFixed(netflow8.Version, netflow8.HeaderLen, netflow8.ASRecordLen, 2)
+		b[22] = aggregation
+		f.Add(b)
+	}
+	f.Fuzz(fuzzDecode)
+}
+
+func FuzzUnmarshalV9(f *testing.F) {
+	f.Add(testPacketV9())
+	f.Fuzz(fuzzDecode)
+}
+
+func FuzzUnmarshalIPFIX(f *testing.F) {
+	f.Add([]byte{
+		0x00, 0x0a, 0x00, 0x38, // version 10, length 56
+		0x5e, 0x0b, 0xe1, 0x00, // Export Time
+		0x00, 0x00, 0x00, 0x01, // Sequence Number
+		0x00, 0x00, 0x00, 0x07, // Observation Domain ID
+		0x00, 0x02, 0x00, 0x14, // template set
+		0x01, 0x00, 0x00, 0x03, // template 256, 3 fields
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		0x00, 0x07, 0x00, 0x02, // sourceTransportPort
+		0x00, 0x01, 0x00, 0x08, // octetDeltaCount
+		0x01, 0x00, 0x00, 0x14, // data set for template 256
+		0xc0, 0x00, 0x02, 0x01,
+		0x00, 0x50, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		0x05, 0xdc, 0x00, 0x00, // 1500 octets, padding
+	})
+	f.Fuzz(fuzzDecode)
+}
+
+// fuzzRoundTrip decodes data and checks marshaling the decoded packet of a
+// fixed layout version reproduces the decoded bytes.
+func fuzzRoundTrip(t *testing.T, data []byte) {
+	p, err := NewDecoder(session.New()).DecodeBytes(data)
+	if err != nil {
+		return
+	}
+	switch p.Header.ProtocolVersion() {
+	case netflow1.Version, netflow5.Version, netflow6.Version, netflow7.Version:
+	default:
+		return
+	}
+	// The residual nanoseconds in the header (bytes 12-15 in all fixed layout
+	// versions) are not valid beyond a second, such values are normalized
+	// into the export time and do not survive the round trip.
+	if binary.BigEndian.Uint32(data[12:]) >= 1e9 {
+		return
+	}
+	b, err := MarshalPacket(p.Header, p.Records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := data[:len(data)-len(p.Trailing())]; !bytes.Equal(b, want) {
+		t.Fatalf("marshaled packet differs from input:\nwant %x\n got %x", want, b)
+	}
+}
+
+func FuzzRoundTripV1(f *testing.F) {
+	b := testPacketFixed(netflow1.Version, netflow1.HeaderLen, netflow1.RecordLen, 2)
+	copy(b[netflow1.HeaderLen:], testPacketV5(1)[netflow5.HeaderLen:])
+	f.Add(b)
+	f.Fuzz(fuzzRoundTrip)
+}
+
+func FuzzRoundTripV5(f *testing.F) {
+	f.Add(testPacketV5(1))
+	f.Add(testPacketV5(30))
+	f.Fuzz(fuzzRoundTrip)
+}
+
+func FuzzRoundTripV6(f *testing.F) {
+	b := testPacketFixed(netflow6.Version, netflow6.HeaderLen, netflow6.RecordLen, 2)
+	copy(b[netflow6.HeaderLen:], testPacketV5(1)[netflow5.HeaderLen:])
+	f.Add(b)
+	f.Fuzz(fuzzRoundTrip)
+}
+
+func FuzzRoundTripV7(f *testing.F) {
+	f.Add(testPacketV7(f, 1, &netflow7.FlowRecord{SrcPort: 80, Flags: netflow7.FlagShortcut}))
+	f.Add(testPacketFixed(netflow7.Version, netflow7.HeaderLen, netflow7.RecordLen, 27))
+	f.Fuzz(fuzzRoundTrip)
+}
@@ -0,0 +1,133 @@
+package netflow
+
+import (
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/translate"
+)
+
+// GenericField is a field of a NetFlow v9 or IPFIX data record. Value holds
+// the translated value of the field, or the raw bytes if the field could not
+// be translated.
+type GenericField struct {
+	FieldID    uint16
+	Enterprise uint32
+	Value      interface{}
+}
+
+// GenericRecord is a NetFlow v9 or IPFIX data record, with the fields in the
+// order defined by the template.
+type GenericRecord []GenericField
+
+// Get returns the value of the first field with the IANA field ID, fields
+// with an enterprise number are not considered.
+func (r GenericRecord) Get(fieldID uint16) (interface{}, bool) {
+	for _, f := range r {
+		if f.FieldID == fieldID && f.Enterprise == 0 {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// FlowKey returns the 5-tuple of the record, from the sourceIPv4Address (8),
+// destinationIPv4Address (12) or their IPv6 variants (27, 28), the transport
+// ports (7, 11) and the protocolIdentifier (4). Missing fields are left zero.
+func (r GenericRecord) FlowKey() FlowKey {
+	protocol, _ := genericUint(r, 4)
+	return FlowKey{
+		SrcAddr:  genericAddr(r, 8, 27),
+		DstAddr:  genericAddr(r, 12, 28),
+		SrcPort:  genericPort(r, 7),
+		DstPort:  genericPort(r, 11),
+		Protocol: uint8(protocol),
+	}
+}
+
+// GenericRecords returns the data records of a NetFlow v9 packet or IPFIX
+// message, in the order they were decoded. Other messages have no generic
+// records.
+func GenericRecords(m Message) []GenericRecord {
+	var rs []GenericRecord
+	switch m := m.(type) {
+	case *netflow9.Packet:
+		for _, dr := range m.DataRecords() {
+			r := make(GenericRecord, len(dr.Fields))
+			for i, f := range dr.Fields {
+				r[i] = GenericField{FieldID: f.Type, Value: f.Bytes}
+				if f.Translated != nil && f.Translated.Value != nil {
+					r[i].Value = f.Translated.Value
+				}
+			}
+			rs = append(rs, r)
+		}
+
+	case *ipfix.Message:
+		for _, ds := range m.DataSets {
+			for _, dr := range ds.Records {
+				r := make(GenericRecord, len(dr.Fields))
+				for i, f := range dr.Fields {
+					r[i] = GenericField{
+						FieldID:    f.InformationElementID,
+						Enterprise: f.EnterpriseNumber,
+						Value:      f.Bytes,
+					}
+					if f.Translated != nil && f.Translated.Value != nil {
+						r[i].Value = f.Translated.Value
+					}
+				}
+				rs = append(rs, r)
+			}
+		}
+	}
+	return rs
+}
+
+// Octets returns the octetDeltaCount (1), or if the record has none, the
+// octetTotalCount (85). Exporters send these as 8 byte fields, or as 4 byte
+// (or smaller) fields using reduced size encoding, the value is returned as an
+// uint64 either way. If the record has neither field, zero is returned.
+func (r GenericRecord) Octets() uint64 {
+	return r.counter(1, 85)
+}
+
+// Packets returns the packetDeltaCount (2), or if the record has none, the
+// packetTotalCount (86), as for Octets.
+func (r GenericRecord) Packets() uint64 {
+	return r.counter(2, 86)
+}
+
+func (r GenericRecord) counter(fieldIDs ...uint16) uint64 {
+	for _, id := range fieldIDs {
+		if v, ok := genericUint(r, id); ok {
+			return v
+		}
+	}
+	return 0
+}
+
+// MPLSLabels returns the MPLS label stack of the record, from the
+// mplsTopLabelStackSection (70) down to the mplsLabelStackSection10 (79). The
+// stack ends at the first missing section, or at the label with the bottom of
+// stack bit set.
+func (r GenericRecord) MPLSLabels() []translate.MPLSLabel {
+	var labels []translate.MPLSLabel
+	for id := uint16(70); id <= 79; id++ {
+		v, ok := r.Get(id)
+		if !ok {
+			break
+		}
+		if bs, ok := v.([]byte); ok {
+			v = translate.DecodeMPLSLabel(bs)
+		}
+		label, ok := v.(translate.MPLSLabel)
+		if !ok {
+			break
+		}
+		labels = append(labels, label)
+		if label.BottomOfStack {
+			break
+		}
+	}
+	return labels
+}
@@ -0,0 +1,155 @@
+package netflow
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
+)
+
+func TestGenericRecords(t *testing.T) {
+	p, err := NewDecoder(session.New()).DecodeBytes(testPacketV9Records(t, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := GenericRecords(p.Message)
+	if len(rs) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(rs))
+	}
+	var ids []uint16
+	for _, f := range rs[1] {
+		ids = append(ids, f.FieldID)
+	}
+	if want := []uint16{8, 12, 7, 11, 4, 2, 1, 22, 21}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected fields in template order %v, got %v", want, ids)
+	}
+	if v, ok := rs[1].Get(7); !ok || v != uint16(1025) {
+		t.Errorf("expected source port 1025, got %v (%T)", v, v)
+	}
+	if _, ok := rs[1].Get(152); ok {
+		t.Error("expected no flowStartMilliseconds field")
+	}
+
+	p, err = NewDecoder(session.New()).DecodeBytes([]byte{
+		0x00, 0x0a, 0x00, 0x38, // version 10, length 56
+		0x5e, 0x0b, 0xe1, 0x00, // Export Time
+		0x00, 0x00, 0x00, 0x01, // Sequence Number
+		0x00, 0x00, 0x00, 0x07, // Observation Domain ID
+		0x00, 0x02, 0x00, 0x18, // template set
+		0x01, 0x00, 0x00, 0x03, // template 256, 3 fields
+		0x00, 0x07, 0x00, 0x02, // sourceTransportPort
+		0x80, 0x08, 0x00, 0x04, // enterprise field 8
+		0x00, 0x00, 0x00, 0x09, // enterprise number 9
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		0x01, 0x00, 0x00, 0x10, // data set for template 256
+		0x00, 0x50, 0xde, 0xad,
+		0xbe, 0xef, 0xc0, 0x00,
+		0x02, 0x01, 0x00, 0x00, // padding
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs = GenericRecords(p.Message); len(rs) != 1 || len(rs[0]) != 3 {
+		t.Fatalf("expected 1 record with 3 fields, got %v", rs)
+	}
+	if f := rs[0][1]; f.FieldID != 8 || f.Enterprise != 9 || !bytes.Equal(f.Value.([]byte), []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("expected raw enterprise field second, got %+v", f)
+	}
+	if v, ok := rs[0].Get(8); !ok || !net.IPv4(192, 0, 2, 1).Equal(v.(net.IP)) {
+		t.Errorf("expected IANA source address 192.0.2.1, got %v", v)
+	}
+
+	if rs = GenericRecords(nil); rs != nil {
+		t.Errorf("expected no records, got %v", rs)
+	}
+}
+
+func TestGenericRecordCounters(t *testing.T) {
+	p, err := NewDecoder(session.New()).DecodeBytes([]byte{
+		0x00, 0x0a, 0x00, 0x4c, // version 10, length 76
+		0x5e, 0x0b, 0xe1, 0x00, // Export Time
+		0x00, 0x00, 0x00, 0x01, // Sequence Number
+		0x00, 0x00, 0x00, 0x07, // Observation Domain ID
+		0x00, 0x02, 0x00, 0x1c, // template set
+		0x01, 0x00, 0x00, 0x02, // template 256, 2 fields
+		0x00, 0x01, 0x00, 0x04, // octetDeltaCount, reduced size
+		0x00, 0x02, 0x00, 0x08, // packetDeltaCount
+		0x01, 0x01, 0x00, 0x02, // template 257, 2 fields
+		0x00, 0x01, 0x00, 0x08, // octetDeltaCount
+		0x00, 0x56, 0x00, 0x04, // packetTotalCount, reduced size
+		0x01, 0x00, 0x00, 0x10, // data set for template 256
+		0xff, 0xff, 0xff, 0xfe,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x02,
+		0x01, 0x01, 0x00, 0x10, // data set for template 257
+		0x00, 0x00, 0x01, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x2a,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := GenericRecords(p.Message)
+	if len(rs) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(rs))
+	}
+	if v := rs[0].Octets(); v != 0xfffffffe {
+		t.Errorf("expected 4 byte octets 4294967294, got %d", v)
+	}
+	if v := rs[0].Packets(); v != 0x100000002 {
+		t.Errorf("expected 8 byte packets 4294967298, got %d", v)
+	}
+	if v := rs[1].Octets(); v != 1<<40 {
+		t.Errorf("expected 8 byte octets 1099511627776, got %d", v)
+	}
+	if v := rs[1].Packets(); v != 42 {
+		t.Errorf("expected packet total count 42, got %d", v)
+	}
+	if v := (GenericRecord{}).Octets(); v != 0 {
+		t.Errorf("expected no octets, got %d", v)
+	}
+}
+
+func TestGenericRecordMPLSLabels(t *testing.T) {
+	p, err := NewDecoder(session.New()).DecodeBytes([]byte{
+		0x00, 0x0a, 0x00, 0x34, // version 10, length 52
+		0x5e, 0x0b, 0xe1, 0x00, // Export Time
+		0x00, 0x00, 0x00, 0x01, // Sequence Number
+		0x00, 0x00, 0x00, 0x07, // Observation Domain ID
+		0x00, 0x02, 0x00, 0x14, // template set
+		0x01, 0x00, 0x00, 0x03, // template 256, 3 fields
+		0x00, 0x46, 0x00, 0x03, // mplsTopLabelStackSection
+		0x00, 0x47, 0x00, 0x03, // mplsLabelStackSection2
+		0x00, 0x48, 0x00, 0x03, // mplsLabelStackSection3
+		0x01, 0x00, 0x00, 0x10, // data set for template 256
+		0x01, 0x00, 0x0a, // label 4096, tc 5
+		0x00, 0x01, 0x01, // label 16, bottom of stack
+		0x00, 0x00, 0x00, // unused section
+		0x00, 0x00, 0x00, // padding
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := GenericRecords(p.Message)
+	if len(rs) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(rs))
+	}
+	want := []translate.MPLSLabel{
+		{Label: 4096, TC: 5},
+		{Label: 16, BottomOfStack: true},
+	}
+	if labels := rs[0].MPLSLabels(); !reflect.DeepEqual(labels, want) {
+		t.Errorf("expected labels %v, got %v", want, labels)
+	}
+
+	raw := GenericRecord{{FieldID: 70, Value: []byte{0x00, 0x01, 0x01}}}
+	if labels := raw.MPLSLabels(); len(labels) != 1 || labels[0].Label != 16 {
+		t.Errorf("expected raw label 16, got %v", labels)
+	}
+	if labels := (GenericRecord{}).MPLSLabels(); labels != nil {
+		t.Errorf("expected no labels, got %v", labels)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/tehmaze/netflow/read"
 	"github.com/tehmaze/netflow/session"
 )
 
@@ -18,7 +19,11 @@ func errProtocol(f string) error {
 }
 
 func errTemplateNotFound(t uint16) error {
-	return fmt.Errorf("template with id=%d not found", t)
+	return fmt.Errorf("%w: template with id=%d not found", read.ErrUnknownTemplate, t)
+}
+
+func errInvalidLength(f string, v ...interface{}) error {
+	return fmt.Errorf("protocol error: %w: "+f, append([]interface{}{read.ErrInvalidLength}, v...)...)
 }
 
 // Decoder can decode multiple IPFIX messages from a stream.
@@ -48,15 +53,16 @@ func (d *Decoder) Next() (*Message, error) {
 func Read(r io.Reader, s session.Session, t *Translate) (*Message, error) {
 	m := new(Message)
 
-	if t == nil && s != nil {
-		t = NewTranslate(s)
-	}
-
 	if err := m.Header.Unmarshal(r); err != nil {
 		return nil, err
 	}
+
+	s, t = scope(s, t, m.Header.ObservationDomainID)
+	if t == nil && s != nil {
+		t = NewTranslate(s)
+	}
 	if int(m.Header.Length) < m.Header.Len() {
-		return nil, io.ErrShortBuffer
+		return nil, errInvalidLength("message length %d", m.Header.Length)
 	}
 	if m.Header.Version != Version {
 		return nil, errInvalidVersion(m.Header.Version)
@@ -64,3 +70,17 @@ func Read(r io.Reader, s session.Session, t *Translate) (*Message, error) {
 
 	return m, m.UnmarshalSets(r, s, t)
 }
+
+// scope returns the session and translator for the templates of the
+// Observation Domain, if the session scopes templates per domain.
+func scope(s session.Session, t *Translate, domain uint32) (session.Session, *Translate) {
+	ds, ok := s.(session.DomainSession)
+	if !ok {
+		return s, t
+	}
+	s = ds.Domain(domain)
+	if t != nil {
+		t = &Translate{t.Translate.WithSession(s)}
+	}
+	return s, t
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/tehmaze/netflow/read"
 	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
 )
 
 // IPFIX
@@ -52,7 +53,7 @@ type Message struct {
 func (m *Message) UnmarshalSets(r io.Reader, s session.Session, t *Translate) error {
 	// Read the rest of the message, containing the sets.
 	data := make([]byte, int(m.Header.Length)-m.Header.Len())
-	if _, err := r.Read(data); err != nil {
+	if err := read.Full(data, r); err != nil {
 		return err
 	}
 
@@ -69,11 +70,11 @@ func (m *Message) UnmarshalSets(r io.Reader, s session.Session, t *Translate) er
 		}
 
 		if int(header.Length) < header.Len() {
-			return io.ErrUnexpectedEOF
+			return errInvalidLength("set %d length %d", header.ID, header.Length)
 		}
 
 		data := make([]byte, int(header.Length)-header.Len())
-		if _, err := buffer.Read(data); err != nil {
+		if err := read.Full(data, buffer); err != nil {
 			return err
 		}
 
@@ -130,6 +131,7 @@ func (m *Message) UnmarshalSets(r io.Reader, s session.Session, t *Translate) er
 					debugLog.Printf("no session, storing %d raw bytes in data set\n", len(data))
 				}
 				ds.Bytes = data
+				m.DataSets = append(m.DataSets, ds)
 				continue
 			}
 			if tm, ok = s.GetTemplate(header.ID); !ok {
@@ -137,6 +139,7 @@ func (m *Message) UnmarshalSets(r io.Reader, s session.Session, t *Translate) er
 					debugLog.Printf("no template for id=%d, storing %d raw bytes in data set\n", header.ID, len(data))
 				}
 				ds.Bytes = data
+				m.DataSets = append(m.DataSets, ds)
 				continue
 			}
 			if tr, ok = tm.(TemplateRecord); !ok {
@@ -144,6 +147,7 @@ func (m *Message) UnmarshalSets(r io.Reader, s session.Session, t *Translate) er
 					debugLog.Printf("no template record, got %T, storing %d raw bytes in data set\n", tm, len(data))
 				}
 				ds.Bytes = data
+				m.DataSets = append(m.DataSets, ds)
 				continue
 			}
 			if err := ds.Unmarshal(bytes.NewBuffer(data), tr, t); err != nil {
@@ -156,6 +160,24 @@ func (m *Message) UnmarshalSets(r io.Reader, s session.Session, t *Translate) er
 	return nil
 }
 
+// SkipUnresolved removes the Data Sets for which no template was known from
+// the message, and returns the number of removed Sets.
+func (m *Message) SkipUnresolved() int {
+	var (
+		dss     = m.DataSets[:0]
+		skipped int
+	)
+	for _, ds := range m.DataSets {
+		if ds.Bytes != nil {
+			skipped++
+			continue
+		}
+		dss = append(dss, ds)
+	}
+	m.DataSets = dss
+	return skipped
+}
+
 // MessageHeader is a Message Header (RFC 7011 section 3.1)
 //
 // The format of the Message Header on the wire is:
@@ -184,29 +206,50 @@ func (h *MessageHeader) Len() int {
 	return 16
 }
 
+// Reset sets all fields of the header to their zero value.
+func (h *MessageHeader) Reset() {
+	*h = MessageHeader{}
+}
+
+// ProtocolVersion returns the version announced in the header.
+func (h *MessageHeader) ProtocolVersion() uint16 {
+	return h.Version
+}
+
+// Sequence returns the sequence number of the message.
+func (h *MessageHeader) Sequence() uint32 {
+	return h.SequenceNumber
+}
+
+// Domain returns the Observation Domain ID, which scopes the templates of an
+// exporter.
+func (h *MessageHeader) Domain() uint32 {
+	return h.ObservationDomainID
+}
+
+// Uptime returns zero, IPFIX messages carry no exporter uptime.
+func (h *MessageHeader) Uptime() time.Duration {
+	return 0
+}
+
 func (h *MessageHeader) String() string {
 	return fmt.Sprintf("version=%d, length=%d, time=%s, seq=%d, odid=%d",
 		h.Version, h.Length, time.Unix(int64(h.ExportTime), 0), h.SequenceNumber, h.ObservationDomainID)
 }
 
 // Unmarshal a message header from a reader.
+// Unmarshal reads the header from r. The header is read as a whole, if the
+// read fails the header is left unchanged.
 func (h *MessageHeader) Unmarshal(r io.Reader) error {
-	if err := read.Uint16(&h.Version, r); err != nil {
+	var b [16]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
 		return err
 	}
-	if err := read.Uint16(&h.Length, r); err != nil {
-		return err
-	}
-	if err := read.Uint32(&h.ExportTime, r); err != nil {
-		return err
-	}
-	if err := read.Uint32(&h.SequenceNumber, r); err != nil {
-		return err
-	}
-	if err := read.Uint32(&h.ObservationDomainID, r); err != nil {
-		return err
-	}
-
+	h.Version = binary.BigEndian.Uint16(b[0:])
+	h.Length = binary.BigEndian.Uint16(b[2:])
+	h.ExportTime = binary.BigEndian.Uint32(b[4:])
+	h.SequenceNumber = binary.BigEndian.Uint32(b[8:])
+	h.ObservationDomainID = binary.BigEndian.Uint32(b[12:])
 	return nil
 }
 
@@ -223,9 +266,10 @@ func (fs FieldSpecifier) IsEnterprise() bool {
 	return fs.EnterpriseBitSet
 }
 
-// IsVariableLength ...
+// IsVariableLength checks if the field is encoded using a variable length
+// (RFC 7011 section 7).
 func (fs FieldSpecifier) IsVariableLength() bool {
-	return fs.InformationElementID == VariableLength
+	return fs.Length == VariableLength
 }
 
 func (fs FieldSpecifier) Len() int {
@@ -340,14 +384,15 @@ func (h SetHeader) String() string {
 	}
 }
 
+// Unmarshal reads the header from r. If the read fails, the header is left
+// unchanged.
 func (h *SetHeader) Unmarshal(r io.Reader) error {
-	if err := read.Uint16(&h.ID, r); err != nil {
-		return err
-	}
-	if err := read.Uint16(&h.Length, r); err != nil {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
 		return err
 	}
-
+	h.ID = binary.BigEndian.Uint16(b[0:])
+	h.Length = binary.BigEndian.Uint16(b[2:])
 	return nil
 }
 
@@ -446,6 +491,20 @@ func (tr TemplateRecord) Len() int {
 	return 4 + tr.Fields.Len()
 }
 
+// minSize returns the minimum size of a Data Record described by the
+// template, a variable length field takes at least its length prefix.
+func (tr TemplateRecord) minSize() int {
+	var size int
+	for _, fs := range tr.Fields {
+		if fs.IsVariableLength() {
+			size++
+		} else {
+			size += int(fs.Length)
+		}
+	}
+	return size
+}
+
 func (tr TemplateRecord) String() string {
 	return fmt.Sprintf("id=%d fields=%d (%s)", tr.TemplateID, tr.FieldCount, tr.Fields)
 }
@@ -567,14 +626,22 @@ func (ds *DataSet) Unmarshal(r io.Reader, tr TemplateRecord, t *Translate) error
 	buffer := new(bytes.Buffer)
 	buffer.ReadFrom(r)
 
+	// A template without fields, or with only zero length fields, describes
+	// records that take no bytes, reading them would never exhaust the
+	// buffer.
+	if buffer.Len() > 0 && tr.minSize() == 0 {
+		return errInvalidLength("template %d describes records of 0 bytes", tr.TemplateID)
+	}
+
 	ds.Records = make([]DataRecord, 0)
 	for buffer.Len() > 0 {
 		var dr = DataRecord{}
 		dr.TemplateID = tr.TemplateID
 		if err := dr.Unmarshal(buffer, tr.Fields, t); err != nil {
-			// If we hit EOF, we've exhausted the buffer. The current DataRecord is discarded,
-			// and we exit normally.
-			if err == io.EOF {
+			// If we hit EOF, we've exhausted the buffer, any remaining bytes
+			// are padding. The current DataRecord is discarded, and we exit
+			// normally.
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
 				return nil
 			} else {
 				return err
@@ -591,16 +658,25 @@ type DataRecord struct {
 	Fields     Fields
 }
 
+// Unmarshal reads the fields described by the template from r. If reading
+// fails, for example because of a timeout, the record is left unchanged.
 func (dr *DataRecord) Unmarshal(r io.Reader, fss FieldSpecifiers, t *Translate) error {
-	dr.Fields = make(Fields, 0)
+	fields := make(Fields, 0)
 	var err error
 	for i := 0; i < len(fss); i++ {
+		if t != nil && !t.Wants(translate.Key{EnterpriseID: fss[i].EnterpriseNumber, FieldID: fss[i].InformationElementID}) {
+			if err = skipField(r, fss[i]); err != nil {
+				return err
+			}
+			continue
+		}
 		f := Field{}
 		if err = f.Unmarshal(r, fss[i]); err != nil {
 			return err
 		}
-		dr.Fields = append(dr.Fields, f)
+		fields = append(fields, f)
 	}
+	dr.Fields = fields
 
 	if t != nil && len(dr.Fields) > 0 {
 		if err := t.Record(dr); err != nil {
@@ -611,24 +687,92 @@ func (dr *DataRecord) Unmarshal(r io.Reader, fss FieldSpecifiers, t *Translate)
 	return nil
 }
 
+// Values returns the field values keyed by their (enterprise number,
+// information element id) pair, so enterprise-specific fields don't collide
+// with IANA-assigned ones. Translated fields have their native value, other
+// fields contain the raw bytes.
+func (dr DataRecord) Values() map[translate.Key]interface{} {
+	values := make(map[translate.Key]interface{}, len(dr.Fields))
+	for _, f := range dr.Fields {
+		if f.Translated != nil && f.Translated.Value != nil {
+			values[f.Key()] = f.Translated.Value
+		} else {
+			values[f.Key()] = f.Bytes
+		}
+	}
+	return values
+}
+
+// Has reports whether the record includes the IANA information element, so an
+// absent field can be told apart from a field with a zero value. Enterprise
+// specific fields are not considered, use Values for those.
+func (dr DataRecord) Has(informationElementID uint16) bool {
+	for _, f := range dr.Fields {
+		if f.EnterpriseNumber == 0 && f.InformationElementID == informationElementID {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone returns a deep copy of the record, sharing no memory with dr, so it
+// can be kept after the buffers it was decoded from are reused.
+func (dr DataRecord) Clone() DataRecord {
+	c := DataRecord{TemplateID: dr.TemplateID, Fields: make(Fields, len(dr.Fields))}
+	for i, f := range dr.Fields {
+		c.Fields[i] = f.Clone()
+	}
+	return c
+}
+
 type Field struct {
-	Bytes      []byte
-	Translated *TranslatedField
+	InformationElementID uint16
+	EnterpriseNumber     uint32
+	Bytes                []byte
+	Translated           *TranslatedField
+}
+
+// Key returns the translation key of the field.
+func (f Field) Key() translate.Key {
+	return translate.Key{EnterpriseID: f.EnterpriseNumber, FieldID: f.InformationElementID}
 }
 
 func (f *Field) Unmarshal(r io.Reader, fs FieldSpecifier) error {
-	if fs.Length == VariableLength {
+	f.InformationElementID = fs.InformationElementID
+	f.EnterpriseNumber = fs.EnterpriseNumber
+	if fs.IsVariableLength() {
 		var err error
 		f.Bytes, err = read.VariableLength(f.Bytes, r)
 		return err
 	} else {
 		f.Bytes = make([]byte, fs.Length)
-		_, err := r.Read(f.Bytes)
+		_, err := io.ReadFull(r, f.Bytes)
 		return err
 	}
 
 }
 
+// skipField discards the bytes of a field from the reader.
+func skipField(r io.Reader, fs FieldSpecifier) error {
+	if fs.IsVariableLength() {
+		return read.SkipVariableLength(r)
+	}
+	return read.Skip(r, int(fs.Length))
+}
+
+// Clone returns a copy of the field and its translated value, sharing no
+// memory with f.
+func (f Field) Clone() Field {
+	f.Bytes = append([]byte(nil), f.Bytes...)
+	if f.Translated != nil {
+		tf := *f.Translated
+		tf.Bytes = append([]byte(nil), tf.Bytes...)
+		tf.Value = translate.CloneValue(tf.Value)
+		f.Translated = &tf
+	}
+	return f
+}
+
 type Fields []Field
 
 func (fs Fields) Len() int {
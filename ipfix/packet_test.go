@@ -0,0 +1,226 @@
+package ipfix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/tehmaze/netflow/read"
+	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
+)
+
+// testMessage builds an IPFIX message from the provided Sets.
+func testMessage(sets ...[]byte) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint16(b[0:], Version)
+	binary.BigEndian.PutUint32(b[4:], 1577836800) // Export Time
+	binary.BigEndian.PutUint32(b[8:], 42)         // Sequence Number
+	binary.BigEndian.PutUint32(b[12:], 7)         // Observation Domain ID
+	for _, s := range sets {
+		b = append(b, s...)
+	}
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)))
+	return b
+}
+
+// testSet builds a Set, padding is added to align to 4 bytes.
+func testSet(id uint16, data ...byte) []byte {
+	for len(data)%4 != 0 {
+		data = append(data, 0x00)
+	}
+	b := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[2:], uint16(4+len(data)))
+	return append(b, data...)
+}
+
+// testTemplateSet is a template with sourceIPv4Address,
+// sourceTransportPort and octetDeltaCount.
+var testTemplateSet = testSet(2,
+	0x01, 0x00, 0x00, 0x03, // template id 256, 3 fields
+	0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+	0x00, 0x07, 0x00, 0x02, // sourceTransportPort
+	0x00, 0x01, 0x00, 0x08, // octetDeltaCount
+)
+
+func TestRead(t *testing.T) {
+	data := testMessage(testTemplateSet, testSet(256,
+		0xc0, 0x00, 0x02, 0x01, 0x00, 0x50, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0xdc,
+	))
+
+	m, err := Read(bytes.NewReader(data), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := m.Header
+	if h.Version != 10 || int(h.Length) != len(data) || h.ExportTime != 1577836800 || h.SequenceNumber != 42 || h.ObservationDomainID != 7 {
+		t.Fatalf("unexpected header %s", &h)
+	}
+
+	if len(m.TemplateSets) != 1 || len(m.TemplateSets[0].Records) != 1 {
+		t.Fatalf("expected 1 template set with 1 record, got %v", m.TemplateSets)
+	}
+	if tr := m.TemplateSets[0].Records[0]; tr.TemplateID != 256 || len(tr.Fields) != 3 {
+		t.Fatalf("unexpected template record %s", tr)
+	}
+
+	if len(m.DataSets) != 1 || len(m.DataSets[0].Records) != 1 {
+		t.Fatalf("expected 1 data set with 1 record (padding ignored), got %v", m.DataSets)
+	}
+	var want = []struct {
+		Name  string
+		Value string
+	}{
+		{"sourceIPv4Address", "192.0.2.1"},
+		{"sourceTransportPort", "80"},
+		{"octetDeltaCount", "1500"},
+	}
+	for i, f := range m.DataSets[0].Records[0].Fields {
+		if f.Translated == nil {
+			t.Fatalf("field %d: not translated", i)
+		}
+		if f.Translated.Name != want[i].Name {
+			t.Errorf("field %d: expected %s, got %s", i, want[i].Name, f.Translated.Name)
+		}
+		if v := fmt.Sprint(f.Translated.Value); v != want[i].Value {
+			t.Errorf("field %d: expected %s, got %s", i, want[i].Value, v)
+		}
+	}
+}
+
+func TestReadUnknownTemplate(t *testing.T) {
+	data := testMessage(testSet(257, 0x01, 0x02, 0x03, 0x04))
+
+	m, err := Read(bytes.NewReader(data), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DataSets) != 1 || len(m.DataSets[0].Bytes) != 4 {
+		t.Fatalf("expected 1 raw data set of 4 bytes, got %v", m.DataSets)
+	}
+}
+
+func TestReadShortMessage(t *testing.T) {
+	data := testMessage(testTemplateSet)
+
+	_, err := Read(bytes.NewReader(data[:len(data)-4]), session.New(), nil)
+	if !errors.Is(err, read.ErrShortPacket) {
+		t.Fatalf("expected short packet error, got %v", err)
+	}
+}
+
+func TestReadEmptyTemplate(t *testing.T) {
+	for _, template := range [][]byte{
+		{
+			0x01, 0x01, 0x00, 0x00, // template id 257, no fields
+			0x01, 0x02, 0x00, 0x01, // template id 258, 1 field
+			0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		},
+		{
+			0x01, 0x01, 0x00, 0x01, // template id 257, 1 field
+			0x00, 0x08, 0x00, 0x00, // sourceIPv4Address of zero length
+		},
+	} {
+		data := testMessage(testSet(2, template...), testSet(257, 0x01, 0x02, 0x03, 0x04))
+
+		_, err := Read(bytes.NewReader(data), session.New(), nil)
+		if !errors.Is(err, read.ErrInvalidLength) {
+			t.Errorf("%x: expected invalid length error, got %v", template, err)
+		}
+	}
+}
+
+func TestReadEnterpriseFields(t *testing.T) {
+	data := testMessage(testSet(2,
+		0x01, 0x01, 0x00, 0x03, // template id 257, 3 fields
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		0x80, 0x08, 0x00, 0x04, // enterprise field 8
+		0x00, 0x00, 0x00, 0x09, // PEN 9 (Cisco)
+		0xa4, 0x24, 0x00, 0x01, // enterprise field 9252
+		0x00, 0x00, 0x00, 0x09, // PEN 9 (Cisco)
+	), testSet(257,
+		0xc0, 0x00, 0x02, 0x01, 0xde, 0xad, 0xbe, 0xef, 0x03,
+	))
+
+	m, err := Read(bytes.NewReader(data), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := m.TemplateSets[0].Records[0].Fields
+	if fs[0].IsEnterprise() || !fs[1].IsEnterprise() || fs[1].EnterpriseNumber != 9 || fs[1].InformationElementID != 8 {
+		t.Fatalf("unexpected field specifiers %s", fs)
+	}
+	if fs.Len() != 20 {
+		t.Fatalf("expected field specifiers of 20 bytes, got %d", fs.Len())
+	}
+
+	if len(m.DataSets) != 1 || len(m.DataSets[0].Records) != 1 {
+		t.Fatalf("expected 1 data set with 1 record, got %v", m.DataSets)
+	}
+	values := m.DataSets[0].Records[0].Values()
+	if v := fmt.Sprint(values[translate.Key{FieldID: 8}]); v != "192.0.2.1" {
+		t.Errorf("expected sourceIPv4Address 192.0.2.1, got %s", v)
+	}
+	if v, ok := values[translate.Key{EnterpriseID: 9, FieldID: 8}].([]byte); !ok || !bytes.Equal(v, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("expected raw bytes for unknown enterprise field, got %v", values[translate.Key{EnterpriseID: 9, FieldID: 8}])
+	}
+	if v, ok := values[translate.Key{EnterpriseID: 9, FieldID: 9252}].(uint8); !ok || v != 3 {
+		t.Errorf("expected translated enterprise field 3, got %v", values[translate.Key{EnterpriseID: 9, FieldID: 9252}])
+	}
+}
+
+func TestReadFieldFilter(t *testing.T) {
+	data := testMessage(testSet(2,
+		0x01, 0x02, 0x00, 0x03, // template id 258, 3 fields
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		0x00, 0x52, 0xff, 0xff, // interfaceName, variable length
+		0x00, 0x07, 0x00, 0x02, // sourceTransportPort
+	), testSet(258,
+		0xc0, 0x00, 0x02, 0x01, 0x04, 'e', 't', 'h', '0', 0x00, 0x50,
+		0xc0, 0x00, 0x02, 0x02, 0x02, 'l', 'o', 0x01, 0xbb,
+	))
+
+	s := session.New()
+	tr := &Translate{translate.NewTranslate(s).WithFieldFilter(translate.Key{FieldID: 8}, translate.Key{FieldID: 7})}
+	m, err := Read(bytes.NewReader(data), s, tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DataSets) != 1 || len(m.DataSets[0].Records) != 2 {
+		t.Fatalf("expected 1 data set with 2 records, got %v", m.DataSets)
+	}
+	for i, want := range []string{"map[{0 7}:80 {0 8}:192.0.2.1]", "map[{0 7}:443 {0 8}:192.0.2.2]"} {
+		dr := m.DataSets[0].Records[i]
+		if dr.Has(82) {
+			t.Errorf("record %d: expected interfaceName to be skipped", i)
+		}
+		if v := fmt.Sprint(dr.Values()); v != want {
+			t.Errorf("record %d: expected %s, got %s", i, want, v)
+		}
+	}
+}
+
+func TestDataRecordHas(t *testing.T) {
+	// testTemplateSet has no bgpSourceAsNumber (16) and
+	// bgpDestinationAsNumber (17).
+	data := testMessage(testTemplateSet, testSet(256,
+		0xc0, 0x00, 0x02, 0x01, 0x00, 0x50, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	))
+
+	m, err := Read(bytes.NewReader(data), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dr := m.DataSets[0].Records[0]
+	if !dr.Has(1) {
+		t.Error("expected octetDeltaCount to be present, even though it is zero")
+	}
+	if dr.Has(16) || dr.Has(17) {
+		t.Error("expected bgpSourceAsNumber and bgpDestinationAsNumber to be absent")
+	}
+}
@@ -50,23 +50,22 @@ func (t *Translate) Record(dr *DataRecord) error {
 		debugLog.Printf("translating %d/%d fields\n", len(dr.Fields), len(tr.Fields))
 	}
 
-	for i, field := range tr.Fields {
-		if i > len(dr.Fields) {
-			break
-		}
+	// Fields are translated using their own identifiers, as fields may have
+	// been left out by a field filter.
+	for i := range dr.Fields {
 		f := &dr.Fields[i]
 		f.Translated = &TranslatedField{}
-		f.Translated.EnterpriseNumber = field.EnterpriseNumber
-		f.Translated.InformationElementID = field.InformationElementID
+		f.Translated.EnterpriseNumber = f.EnterpriseNumber
+		f.Translated.InformationElementID = f.InformationElementID
 
-		if element, ok := t.Translate.Key(translate.Key{field.EnterpriseNumber, field.InformationElementID}); ok {
+		if element, ok := t.Translate.Key(f.Key()); ok {
 			f.Translated.Name = element.Name
-			f.Translated.Value = translate.Bytes(dr.Fields[i].Bytes, element.Type)
+			f.Translated.Value = element.Value(f.Bytes)
 			if debug {
-				debugLog.Printf("translated {%d, %d} to %s, %v\n", field.EnterpriseNumber, field.InformationElementID, f.Translated.Name, f.Translated.Value)
+				debugLog.Printf("translated {%d, %d} to %s, %v\n", f.EnterpriseNumber, f.InformationElementID, f.Translated.Name, f.Translated.Value)
 			}
 		} else if debug {
-			debugLog.Printf("no translator element for {%d, %d}\n", field.EnterpriseNumber, field.InformationElementID)
+			debugLog.Printf("no translator element for {%d, %d}\n", f.EnterpriseNumber, f.InformationElementID)
 		}
 	}
 
@@ -0,0 +1,37 @@
+package netflow
+
+import (
+	"net/netip"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+)
+
+// FlowKey is the 5-tuple identifying a flow. It is comparable, and can be
+// used as a map key to aggregate or deduplicate records across packets.
+type FlowKey struct {
+	SrcAddr  netip.Addr
+	DstAddr  netip.Addr
+	SrcPort  uint16
+	DstPort  uint16
+	Protocol uint8
+}
+
+// KeyOf returns the FlowKey of a record. The NetFlow v8 aggregation records
+// do not carry a 5-tuple, for those ok is false.
+func KeyOf(r FlowRecord) (key FlowKey, ok bool) {
+	switch r := r.(type) {
+	case *netflow1.FlowRecord:
+		return FlowKey{r.SrcAddrNetip(), r.DstAddrNetip(), r.SrcPort, r.DstPort, r.Protocol}, true
+	case *netflow5.FlowRecord:
+		return FlowKey{r.SrcAddrNetip(), r.DstAddrNetip(), r.SrcPort, r.DstPort, r.Protocol}, true
+	case *netflow6.FlowRecord:
+		return FlowKey{r.SrcAddrNetip(), r.DstAddrNetip(), r.SrcPort, r.DstPort, r.Protocol}, true
+	case *netflow7.FlowRecord:
+		return FlowKey{r.SrcAddrNetip(), r.DstAddrNetip(), r.SrcPort, r.DstPort, r.Protocol}, true
+	default:
+		return FlowKey{}, false
+	}
+}
@@ -0,0 +1,42 @@
+package netflow
+
+import (
+	"net"
+	"testing"
+
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+)
+
+func TestKeyOf(t *testing.T) {
+	var (
+		a = &netflow5.FlowRecord{SrcAddr: net.IPv4(192, 0, 2, 1).To4(), DstAddr: net.IPv4(10, 0, 0, 1).To4(), SrcPort: 1024, DstPort: 80, Protocol: 6, Packets: 10}
+		b = &netflow7.FlowRecord{SrcAddr: net.IPv4(192, 0, 2, 1), DstAddr: net.IPv4(10, 0, 0, 1), SrcPort: 1024, DstPort: 80, Protocol: 6, Packets: 20}
+		c = &netflow5.FlowRecord{SrcAddr: net.IPv4(192, 0, 2, 1).To4(), DstAddr: net.IPv4(10, 0, 0, 1).To4(), SrcPort: 1024, DstPort: 80, Protocol: 17}
+	)
+
+	ka, ok := KeyOf(a)
+	if !ok {
+		t.Fatal("expected a key for a NetFlow v5 record")
+	}
+	kb, _ := KeyOf(b)
+	kc, _ := KeyOf(c)
+	if ka != kb {
+		t.Errorf("expected equal keys for the same 5-tuple, got %v and %v", ka, kb)
+	}
+	if ka == kc {
+		t.Errorf("expected distinct keys for different protocols, got %v", ka)
+	}
+
+	seen := map[FlowKey]int{ka: 1}
+	seen[kb]++
+	seen[kc]++
+	if len(seen) != 2 || seen[ka] != 2 {
+		t.Errorf("expected 2 distinct keys, got %v", seen)
+	}
+
+	if _, ok := KeyOf(&netflow8.ASRecord{}); ok {
+		t.Error("expected no key for a NetFlow v8 AS record")
+	}
+}
@@ -0,0 +1,29 @@
+package netflow
+
+import "encoding/hex"
+
+// Logger receives the diagnostic messages of a Server, see WithLogger.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// nopLogger discards all messages, it is the default Logger of a Server.
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Warnf(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+
+// logDumpSize is the maximum number of datagram bytes included in a log
+// message.
+const logDumpSize = 64
+
+// dump returns the hex encoding of b, truncated to logDumpSize bytes.
+func dump(b []byte) string {
+	if len(b) > logDumpSize {
+		return hex.EncodeToString(b[:logDumpSize]) + "..."
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,146 @@
+package netflow
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+)
+
+// MarshalPacket builds a complete datagram from the header followed by the
+// records. The Count in the header is set to the number of records, the
+// header passed in is not modified. All records must be of the same version
+// as the header; only the NetFlow v1, v5, v6 and v7 layouts are supported.
+func MarshalPacket(h Header, records []FlowRecord) ([]byte, error) {
+	if len(records) > math.MaxUint16 {
+		return nil, fmt.Errorf("netflow: %d records do not fit in a packet", len(records))
+	}
+	count := uint16(len(records))
+
+	switch h := h.(type) {
+	case *netflow1.PacketHeader:
+		c := *h
+		c.Count = count
+		b := c.AppendBytes(make([]byte, 0, netflow1.HeaderLen+len(records)*netflow1.RecordLen))
+		for i, r := range records {
+			r, ok := r.(*netflow1.FlowRecord)
+			if !ok {
+				return nil, errRecordVersion(i, records[i], h)
+			}
+			b = r.AppendBytes(b)
+		}
+		return b, nil
+
+	case *netflow5.PacketHeader:
+		c := *h
+		c.Count = count
+		b := c.AppendBytes(make([]byte, 0, netflow5.HeaderLen+len(records)*netflow5.RecordLen))
+		for i, r := range records {
+			r, ok := r.(*netflow5.FlowRecord)
+			if !ok {
+				return nil, errRecordVersion(i, records[i], h)
+			}
+			b = r.AppendBytes(b)
+		}
+		return b, nil
+
+	case *netflow6.PacketHeader:
+		c := *h
+		c.Count = count
+		b := c.AppendBytes(make([]byte, 0, netflow6.HeaderLen+len(records)*netflow6.RecordLen))
+		for i, r := range records {
+			r, ok := r.(*netflow6.FlowRecord)
+			if !ok {
+				return nil, errRecordVersion(i, records[i], h)
+			}
+			b = r.AppendBytes(b)
+		}
+		return b, nil
+
+	case *netflow7.PacketHeader:
+		c := *h
+		c.Count = count
+		b := c.AppendBytes(make([]byte, 0, netflow7.HeaderLen+len(records)*netflow7.RecordLen))
+		for i, r := range records {
+			r, ok := r.(*netflow7.FlowRecord)
+			if !ok {
+				return nil, errRecordVersion(i, records[i], h)
+			}
+			b = r.AppendBytes(b)
+		}
+		return b, nil
+
+	default:
+		return nil, fmt.Errorf("%w %d", ErrUnsupportedVersion, h.ProtocolVersion())
+	}
+}
+
+func errRecordVersion(i int, r FlowRecord, h Header) error {
+	return fmt.Errorf("netflow: record %d is a %T, expected a v%d record", i, r, h.ProtocolVersion())
+}
+
+// ReExport builds datagrams of at most mtu bytes holding the records, for
+// relaying decoded records to another collector. Each datagram holds as many
+// records as fit, up to the maximum number of records per packet of the
+// version. The header is used for every datagram, with the Count and the flow
+// sequence number updated: the first datagram has the sequence number of the
+// header, the following ones advance it by the number of records in the
+// datagrams before them. The header passed in is not modified, a following
+// call continues the sequence at h.Sequence() plus the number of records.
+// Only the NetFlow v1, v5, v6 and v7 layouts are supported, as for
+// MarshalPacket.
+func ReExport(h Header, records []FlowRecord, mtu int) ([][]byte, error) {
+	l, ok := layouts[h.ProtocolVersion()]
+	if !ok || h.ProtocolVersion() == netflow8.Version {
+		return nil, fmt.Errorf("%w %d", ErrUnsupportedVersion, h.ProtocolVersion())
+	}
+	n := (mtu - l.headerLen) / l.recordLen
+	if n < 1 {
+		return nil, fmt.Errorf("netflow: MTU of %d bytes does not fit a v%d record", mtu, h.ProtocolVersion())
+	}
+	if n > l.maxCount {
+		n = l.maxCount
+	}
+
+	var (
+		bs  [][]byte
+		seq = h.Sequence()
+	)
+	for i := 0; i < len(records); i += n {
+		j := i + n
+		if j > len(records) {
+			j = len(records)
+		}
+		b, err := MarshalPacket(withSequence(h, seq), records[i:j])
+		if err != nil {
+			return nil, err
+		}
+		bs = append(bs, b)
+		seq += uint32(j - i)
+	}
+	return bs, nil
+}
+
+// withSequence returns a copy of the header with the flow sequence number
+// set, NetFlow v1 headers have none and are returned as is.
+func withSequence(h Header, seq uint32) Header {
+	switch h := h.(type) {
+	case *netflow5.PacketHeader:
+		c := *h
+		c.FlowSequence = seq
+		return &c
+	case *netflow6.PacketHeader:
+		c := *h
+		c.FlowSequence = seq
+		return &c
+	case *netflow7.PacketHeader:
+		c := *h
+		c.FlowSequence = seq
+		return &c
+	}
+	return h
+}
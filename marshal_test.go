@@ -0,0 +1,142 @@
+package netflow
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/session"
+)
+
+func TestMarshalPacket(t *testing.T) {
+	h := &netflow7.PacketHeader{
+		Version:      netflow7.Version,
+		SysUptime:    100 * time.Second,
+		Unix:         time.Unix(1577836800, 500),
+		FlowSequence: 42,
+	}
+	records := make([]FlowRecord, 30)
+	for i := range records {
+		records[i] = &netflow7.FlowRecord{
+			SrcAddr:  net.IPv4(192, 168, 1, byte(i)),
+			DstAddr:  net.IPv4(10, 0, 0, 1),
+			NextHop:  net.IPv4(0, 0, 0, 0),
+			Packets:  uint32(i),
+			Bytes:    uint32(i * 1500),
+			SrcPort:  uint16(1024 + i),
+			DstPort:  443,
+			Protocol: 6,
+			RouterSC: net.IPv4(192, 168, 1, 254),
+		}
+	}
+
+	b, err := MarshalPacket(h, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != netflow7.HeaderLen+30*netflow7.RecordLen {
+		t.Fatalf("expected %d bytes, got %d", netflow7.HeaderLen+30*netflow7.RecordLen, len(b))
+	}
+	if h.Count != 0 {
+		t.Errorf("expected header passed in to be left alone, got count %d", h.Count)
+	}
+
+	p, err := NewDecoder(session.New()).DecodeBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Header.(*netflow7.PacketHeader); got.Count != 30 || got.FlowSequence != 42 || got.SysUptime != h.SysUptime || !got.Unix.Equal(h.Unix) {
+		t.Fatalf("unexpected header %s", got)
+	}
+	if len(p.Records) != len(records) {
+		t.Fatalf("expected %d records, got %d", len(records), len(p.Records))
+	}
+	for i, r := range p.Records {
+		if !r.(*netflow7.FlowRecord).Equal(records[i].(*netflow7.FlowRecord)) {
+			t.Errorf("record %d: expected %s, got %s", i, records[i], r)
+		}
+	}
+}
+
+func TestMarshalPacketVersionMismatch(t *testing.T) {
+	h := &netflow7.PacketHeader{Version: netflow7.Version}
+	if _, err := MarshalPacket(h, []FlowRecord{&netflow7.FlowRecord{}, &netflow5.FlowRecord{}}); err == nil {
+		t.Fatal("expected error for v5 record in a v7 packet")
+	}
+
+	_, err := MarshalPacket(&netflow9.PacketHeader{Version: netflow9.Version}, nil)
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestReExport(t *testing.T) {
+	h := &netflow7.PacketHeader{
+		Version:      netflow7.Version,
+		SysUptime:    100 * time.Second,
+		Unix:         time.Unix(1577836800, 0),
+		FlowSequence: 1000,
+	}
+	records := make([]FlowRecord, 100)
+	for i := range records {
+		records[i] = &netflow7.FlowRecord{
+			SrcAddr:  net.IPv4(192, 168, 1, byte(i)),
+			DstAddr:  net.IPv4(10, 0, 0, 1),
+			NextHop:  net.IPv4(0, 0, 0, 0),
+			Packets:  uint32(i),
+			SrcPort:  uint16(1024 + i),
+			DstPort:  443,
+			Protocol: 6,
+			RouterSC: net.IPv4(192, 168, 1, 254),
+		}
+	}
+
+	// 10 records fit in 576 bytes.
+	bs, err := ReExport(h, records, 576)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bs) != 10 {
+		t.Fatalf("expected 10 datagrams, got %d", len(bs))
+	}
+
+	var (
+		d    = NewDecoder(session.New())
+		seq  = uint32(1000)
+		next int
+	)
+	for i, b := range bs {
+		if len(b) > 576 {
+			t.Fatalf("datagram %d: %d bytes exceeds the MTU", i, len(b))
+		}
+		p, err := d.DecodeBytes(b)
+		if err != nil {
+			t.Fatalf("datagram %d: %v", i, err)
+		}
+		if got := p.Header.(*netflow7.PacketHeader); got.FlowSequence != seq || int(got.Count) != len(p.Records) {
+			t.Fatalf("datagram %d: expected sequence %d, got header %s", i, seq, got)
+		}
+		seq += uint32(len(p.Records))
+		for _, r := range p.Records {
+			if !r.(*netflow7.FlowRecord).Equal(records[next].(*netflow7.FlowRecord)) {
+				t.Errorf("record %d: expected %s, got %s", next, records[next], r)
+			}
+			next++
+		}
+	}
+	if next != len(records) {
+		t.Fatalf("expected %d records, got %d", len(records), next)
+	}
+
+	// Without a MTU limit, the datagrams hold the maximum of 27 records.
+	if bs, err = ReExport(h, records, 65535); err != nil || len(bs) != 4 {
+		t.Fatalf("expected 4 datagrams, got %d: %v", len(bs), err)
+	}
+	if _, err = ReExport(h, records, netflow7.HeaderLen); err == nil {
+		t.Fatal("expected error for a MTU not fitting a record")
+	}
+}
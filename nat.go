@@ -0,0 +1,144 @@
+package netflow
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+// NAT event types, as reported in the natEvent (230) information element.
+const (
+	NATEventCreate             = 1 // NAT translation create (historic)
+	NATEventDelete             = 2 // NAT translation delete (historic)
+	NATEventAddressExhausted   = 3
+	NATEventNAT44SessionCreate = 4
+	NATEventNAT44SessionDelete = 5
+	NATEventNAT64SessionCreate = 6
+	NATEventNAT64SessionDelete = 7
+	NATEventNAT44BIBCreate     = 8
+	NATEventNAT44BIBDelete     = 9
+	NATEventNAT64BIBCreate     = 10
+	NATEventNAT64BIBDelete     = 11
+	NATEventPortsExhausted     = 12
+	NATEventQuotaExceeded      = 13
+	NATEventAddressBindCreate  = 14
+	NATEventAddressBindDelete  = 15
+	NATEventPortBlockAllocate  = 16
+	NATEventPortBlockRelease   = 17
+	NATEventThresholdReached   = 18
+)
+
+// NATEvent is a NAT event logged by a Carrier-Grade NAT or firewall, with the
+// flow before and after translation.
+type NATEvent struct {
+	// Event is the NAT event type.
+	Event uint8
+	// Pre is the flow before translation, from the sourceIPv4Address (8),
+	// destinationIPv4Address (12) or their IPv6 variants (27, 28), and the
+	// transport ports (7, 11).
+	Pre FlowKey
+	// Post is the flow after translation, from the postNATSourceIPv4Address
+	// (225), postNATDestinationIPv4Address (226) or their IPv6 variants (281,
+	// 282), and the postNAPT ports (227, 228). Untranslated addresses and
+	// ports are copied from Pre.
+	Post FlowKey
+}
+
+// NATEventOf assembles a NATEvent from a data record. If the record has no
+// natEvent field, ok is false.
+func NATEventOf(r GenericRecord) (e NATEvent, ok bool) {
+	event, ok := genericUint(r, 230)
+	if !ok {
+		return NATEvent{}, false
+	}
+	e.Event = uint8(event)
+	e.Pre = r.FlowKey()
+	e.Post = e.Pre
+	if a := genericAddr(r, 225, 281); a.IsValid() {
+		e.Post.SrcAddr = a
+	}
+	if a := genericAddr(r, 226, 282); a.IsValid() {
+		e.Post.DstAddr = a
+	}
+	if port, ok := genericUint(r, 227); ok {
+		e.Post.SrcPort = uint16(port)
+	}
+	if port, ok := genericUint(r, 228); ok {
+		e.Post.DstPort = uint16(port)
+	}
+	return e, true
+}
+
+// IsCreate reports whether the event creates a session, binding or port
+// block.
+func (e NATEvent) IsCreate() bool {
+	switch e.Event {
+	case NATEventCreate, NATEventNAT44SessionCreate, NATEventNAT64SessionCreate,
+		NATEventNAT44BIBCreate, NATEventNAT64BIBCreate, NATEventAddressBindCreate,
+		NATEventPortBlockAllocate:
+		return true
+	}
+	return false
+}
+
+// IsDelete reports whether the event deletes a session, binding or port
+// block.
+func (e NATEvent) IsDelete() bool {
+	switch e.Event {
+	case NATEventDelete, NATEventNAT44SessionDelete, NATEventNAT64SessionDelete,
+		NATEventNAT44BIBDelete, NATEventNAT64BIBDelete, NATEventAddressBindDelete,
+		NATEventPortBlockRelease:
+		return true
+	}
+	return false
+}
+
+// genericUint returns the value of an unsigned integer field, translated or
+// raw.
+func genericUint(r GenericRecord, fieldID uint16) (uint64, bool) {
+	v, ok := r.Get(fieldID)
+	if !ok {
+		return 0, false
+	}
+	switch v := v.(type) {
+	case uint8:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case []byte:
+		var n uint64
+		for _, b := range v {
+			n = n<<8 | uint64(b)
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+func genericPort(r GenericRecord, fieldID uint16) uint16 {
+	port, _ := genericUint(r, fieldID)
+	return uint16(port)
+}
+
+// genericAddr returns the address of the first present field, translated or
+// raw.
+func genericAddr(r GenericRecord, fieldIDs ...uint16) netip.Addr {
+	for _, id := range fieldIDs {
+		v, ok := r.Get(id)
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case net.IP:
+			return read.Addr(v)
+		case []byte:
+			return read.Addr(net.IP(v))
+		}
+	}
+	return netip.Addr{}
+}
@@ -0,0 +1,67 @@
+package netflow
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+func TestNATEventOf(t *testing.T) {
+	p, err := NewDecoder(session.New()).DecodeBytes([]byte{
+		0x00, 0x0a, 0x00, 0x50, // version 10, length 80
+		0x5e, 0x0b, 0xe1, 0x00, // Export Time
+		0x00, 0x00, 0x00, 0x01, // Sequence Number
+		0x00, 0x00, 0x00, 0x07, // Observation Domain ID
+		0x00, 0x02, 0x00, 0x28, // template set
+		0x01, 0x00, 0x00, 0x08, // template 256, 8 fields
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		0x00, 0x07, 0x00, 0x02, // sourceTransportPort
+		0x00, 0xe1, 0x00, 0x04, // postNATSourceIPv4Address
+		0x00, 0xe3, 0x00, 0x02, // postNAPTSourceTransportPort
+		0x00, 0x04, 0x00, 0x01, // protocolIdentifier
+		0x00, 0xe6, 0x00, 0x01, // natEvent
+		0x00, 0x0c, 0x00, 0x04, // destinationIPv4Address
+		0x00, 0x0b, 0x00, 0x02, // destinationTransportPort
+		0x01, 0x00, 0x00, 0x18, // data set for template 256
+		0x64, 0x40, 0x00, 0x0a, // 100.64.0.10
+		0xc3, 0x50, // port 50000
+		0xcb, 0x00, 0x71, 0x05, // 203.0.113.5
+		0x04, 0x00, // port 1024
+		0x06,                   // tcp
+		0x04,                   // NAT44 session create
+		0xc6, 0x33, 0x64, 0x01, // 198.51.100.1
+		0x01, 0xbb, // port 443
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := GenericRecords(p.Message)
+	if len(rs) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(rs))
+	}
+	e, ok := NATEventOf(rs[0])
+	if !ok {
+		t.Fatal("expected a NAT event")
+	}
+	dst := netip.MustParseAddr("198.51.100.1")
+	want := NATEvent{
+		Event: NATEventNAT44SessionCreate,
+		Pre:   FlowKey{netip.MustParseAddr("100.64.0.10"), dst, 50000, 443, 6},
+		Post:  FlowKey{netip.MustParseAddr("203.0.113.5"), dst, 1024, 443, 6},
+	}
+	if e != want {
+		t.Errorf("expected %+v, got %+v", want, e)
+	}
+	if !e.IsCreate() || e.IsDelete() {
+		t.Errorf("expected a create event, got %d", e.Event)
+	}
+
+	if _, ok := NATEventOf(GenericRecord{{FieldID: 8, Value: []byte{10, 0, 0, 1}}}); ok {
+		t.Error("expected no NAT event without natEvent field")
+	}
+	e, _ = NATEventOf(GenericRecord{{FieldID: 230, Value: []byte{NATEventNAT64SessionDelete}}})
+	if e.IsCreate() || !e.IsDelete() {
+		t.Errorf("expected a delete event, got %d", e.Event)
+	}
+}
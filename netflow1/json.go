@@ -0,0 +1,49 @@
+package netflow1
+
+import (
+	"encoding/json"
+	"net"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+type protocolJSON struct {
+	Number uint8  `json:"number"`
+	Name   string `json:"name"`
+}
+
+// MarshalJSON encodes the flow record as JSON, with resolved protocol names and
+// TCP flags. The First and Last fields are encoded as raw SysUptime values.
+func (r FlowRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		SrcAddr  net.IP       `json:"srcAddr"`
+		DstAddr  net.IP       `json:"dstAddr"`
+		NextHop  net.IP       `json:"nextHop"`
+		Input    uint16       `json:"input"`
+		Output   uint16       `json:"output"`
+		Packets  uint32       `json:"packets"`
+		Bytes    uint32       `json:"bytes"`
+		First    uint32       `json:"first"`
+		Last     uint32       `json:"last"`
+		SrcPort  uint16       `json:"srcPort"`
+		DstPort  uint16       `json:"dstPort"`
+		Protocol protocolJSON `json:"protocol"`
+		ToS      uint8        `json:"tos"`
+		Flags    string       `json:"tcpFlags"`
+	}{
+		SrcAddr:  r.SrcAddr,
+		DstAddr:  r.DstAddr,
+		NextHop:  r.NextHop,
+		Input:    r.Input,
+		Output:   r.Output,
+		Packets:  r.Packets,
+		Bytes:    r.Bytes,
+		First:    r.First,
+		Last:     r.Last,
+		SrcPort:  r.SrcPort,
+		DstPort:  r.DstPort,
+		Protocol: protocolJSON{r.Protocol, read.ProtocolName(r.Protocol)},
+		ToS:      r.ToS,
+		Flags:    read.TCPFlagNames(r.Flags),
+	})
+}
@@ -0,0 +1,50 @@
+package netflow5
+
+import (
+	"fmt"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+// RecordIterator decodes the records of a datagram one at a time into a
+// reused FlowRecord, so no memory is allocated per record. The record
+// returned by Next is only valid until the next call to Next or Reset.
+type RecordIterator struct {
+	Header PacketHeader
+
+	data   []byte
+	record FlowRecord
+	ip     [12]byte
+}
+
+// NewRecordIterator sets up an iterator over the records in the datagram.
+func NewRecordIterator(data []byte) (*RecordIterator, error) {
+	it := new(RecordIterator)
+	return it, it.Reset(data)
+}
+
+// Reset the iterator to the records in the datagram, allowing the iterator
+// to be reused for the next datagram.
+func (it *RecordIterator) Reset(data []byte) error {
+	it.data = nil
+	if _, err := it.Header.UnmarshalBytes(data); err != nil {
+		return err
+	}
+	size := int(it.Header.Count) * RecordLen
+	if err := read.Need(data[HeaderLen:], size); err != nil {
+		return fmt.Errorf("protocol error: %d flows announced: %w", it.Header.Count, err)
+	}
+	it.data = data[HeaderLen : HeaderLen+size]
+	return nil
+}
+
+// Next decodes the next record, it returns false if all records have been
+// consumed.
+func (it *RecordIterator) Next() (*FlowRecord, bool) {
+	if len(it.data) < RecordLen {
+		return nil, false
+	}
+	it.record.decode(it.data, it.ip[:])
+	it.data = it.data[RecordLen:]
+	return &it.record, true
+}
@@ -0,0 +1,170 @@
+package netflow5
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// A datagram with two flow records as exported by a Cisco 7200 with sampled
+// NetFlow enabled.
+var testPacket = []byte{
+	0x00, 0x05, 0x00, 0x02, // version 5, count 2
+	0x00, 0x2c, 0xc7, 0x8e, // sys_uptime 2934670ms
+	0x5e, 0x0b, 0xe1, 0x00, // unix_secs 1577836800
+	0x00, 0x00, 0x03, 0xe8, // unix_nsecs 1000
+	0x00, 0x00, 0x12, 0x34, // flow_sequence
+	0x00,       // engine_type
+	0x03,       // engine_id
+	0x40, 0x64, // sampling_interval, mode 1 interval 100
+
+	0xc0, 0xa8, 0x01, 0x0a, // srcaddr 192.168.1.10
+	0x08, 0x08, 0x08, 0x08, // dstaddr 8.8.8.8
+	0xc0, 0xa8, 0x01, 0x01, // nexthop 192.168.1.1
+	0x00, 0x02, // input
+	0x00, 0x05, // output
+	0x00, 0x00, 0x00, 0x01, // dPkts
+	0x00, 0x00, 0x00, 0x4c, // dOctets
+	0x00, 0x2c, 0xbf, 0xbe, // first
+	0x00, 0x2c, 0xbf, 0xbe, // last
+	0xd4, 0x31, // srcport 54321
+	0x00, 0x35, // dstport 53
+	0x00,       // pad1
+	0x00,       // tcp_flags
+	0x11,       // prot
+	0x00,       // tos
+	0x00, 0x00, // src_as
+	0x3b, 0x41, // dst_as 15169
+	0x18,       // src_mask
+	0x18,       // dst_mask
+	0x00, 0x00, // pad2
+
+	0x0a, 0x00, 0x00, 0x05, // srcaddr 10.0.0.5
+	0xc0, 0xa8, 0x01, 0x0a, // dstaddr 192.168.1.10
+	0x00, 0x00, 0x00, 0x00, // nexthop 0.0.0.0
+	0x00, 0x05, // input
+	0x00, 0x02, // output
+	0x00, 0x00, 0x00, 0x0a, // dPkts
+	0x00, 0x00, 0x3a, 0x98, // dOctets
+	0x00, 0x2c, 0xa0, 0x7e, // first
+	0x00, 0x2c, 0xc3, 0xa6, // last
+	0x01, 0xbb, // srcport 443
+	0xc7, 0x38, // dstport 51000
+	0x00,       // pad1
+	0x1b,       // tcp_flags
+	0x06,       // prot
+	0x28,       // tos
+	0xfd, 0xe8, // src_as 65000
+	0x00, 0x00, // dst_as
+	0x08,       // src_mask
+	0x18,       // dst_mask
+	0x00, 0x00, // pad2
+}
+
+func TestRead(t *testing.T) {
+	p, err := Read(bytes.NewReader(testPacket))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := PacketHeader{
+		Version:          Version,
+		Count:            2,
+		SysUptime:        2934670 * time.Millisecond,
+		Unix:             time.Unix(1577836800, 1000),
+		FlowSequence:     0x1234,
+		EngineType:       0,
+		EngineID:         3,
+		SamplingInterval: 0x4064,
+	}
+	if h := p.Header; h != want {
+		t.Fatalf("expected header %+v, got %+v", want, h)
+	}
+	if s := p.Header.String(); s != "v=5, count=2, uptime=48m54.67s, time="+want.Unix.String()+", seq=4660, type=0, id=3, interval=16484" {
+		t.Errorf("unexpected header string %q", s)
+	}
+
+	if len(p.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(p.Records))
+	}
+	records := []*FlowRecord{
+		{
+			SrcAddr:  net.IPv4(192, 168, 1, 10),
+			DstAddr:  net.IPv4(8, 8, 8, 8),
+			NextHop:  net.IPv4(192, 168, 1, 1),
+			Input:    2,
+			Output:   5,
+			Packets:  1,
+			Bytes:    76,
+			First:    2932670,
+			Last:     2932670,
+			SrcPort:  54321,
+			DstPort:  53,
+			Protocol: 17,
+			DstAS:    15169,
+			SrcMask:  24,
+			DstMask:  24,
+		},
+		{
+			SrcAddr:  net.IPv4(10, 0, 0, 5),
+			DstAddr:  net.IPv4(192, 168, 1, 10),
+			NextHop:  net.IPv4(0, 0, 0, 0),
+			Input:    5,
+			Output:   2,
+			Packets:  10,
+			Bytes:    15000,
+			First:    2924670,
+			Last:     2933670,
+			SrcPort:  443,
+			DstPort:  51000,
+			TCPFlags: 0x1b,
+			Protocol: 6,
+			ToS:      0x28,
+			SrcAS:    65000,
+			SrcMask:  8,
+			DstMask:  24,
+		},
+	}
+	for i, r := range p.Records {
+		if !r.Equal(records[i]) {
+			t.Errorf("record %d: expected %+v, got %+v", i, records[i], r)
+		}
+	}
+	if s := p.Records[1].String(); s != "10.0.0.5:443 -> 192.168.1.10:51000" {
+		t.Errorf("unexpected record string %q", s)
+	}
+}
+
+func TestReadShortPacket(t *testing.T) {
+	if _, err := Read(bytes.NewReader(testPacket[:len(testPacket)-1])); err == nil {
+		t.Fatal("expected error for truncated packet")
+	}
+}
+
+func TestReadCountOutOfBounds(t *testing.T) {
+	b := append([]byte(nil), testPacket...)
+	b[3] = 0
+	if _, err := Read(bytes.NewReader(b)); err == nil {
+		t.Fatal("expected error for packet without records")
+	}
+}
+
+func TestPacketHeaderSampling(t *testing.T) {
+	p, err := Read(bytes.NewReader(testPacket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := p.Header
+	if h.EngineType != 0 || h.EngineID != 3 {
+		t.Errorf("expected engine 0/3, got %d/%d", h.EngineType, h.EngineID)
+	}
+	if h.SamplingMode() != SamplingDeterministic || h.SamplingRate() != 100 {
+		t.Errorf("expected deterministic sampling 1 out of 100, got mode %d interval %d", h.SamplingMode(), h.SamplingRate())
+	}
+
+	h.SamplingInterval = 0xbfff
+	if h.SamplingMode() != SamplingRandom || h.SamplingRate() != 0x3fff {
+		t.Errorf("expected random sampling 1 out of 16383, got mode %d interval %d", h.SamplingMode(), h.SamplingRate())
+	}
+}
@@ -0,0 +1,57 @@
+package netflow6
+
+import (
+	"encoding/json"
+	"net"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+type protocolJSON struct {
+	Number uint8  `json:"number"`
+	Name   string `json:"name"`
+}
+
+// MarshalJSON encodes the flow record as JSON, with resolved protocol names and
+// TCP flags. The First and Last fields are encoded as raw SysUptime values.
+func (r FlowRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		SrcAddr  net.IP       `json:"srcAddr"`
+		DstAddr  net.IP       `json:"dstAddr"`
+		NextHop  net.IP       `json:"nextHop"`
+		Input    uint16       `json:"input"`
+		Output   uint16       `json:"output"`
+		Packets  uint32       `json:"packets"`
+		Bytes    uint32       `json:"bytes"`
+		First    uint32       `json:"first"`
+		Last     uint32       `json:"last"`
+		SrcPort  uint16       `json:"srcPort"`
+		DstPort  uint16       `json:"dstPort"`
+		TCPFlags string       `json:"tcpFlags"`
+		Protocol protocolJSON `json:"protocol"`
+		ToS      uint8        `json:"tos"`
+		SrcAS    uint16       `json:"srcAs"`
+		DstAS    uint16       `json:"dstAs"`
+		SrcMask  uint8        `json:"srcMask"`
+		DstMask  uint8        `json:"dstMask"`
+	}{
+		SrcAddr:  r.SrcAddr,
+		DstAddr:  r.DstAddr,
+		NextHop:  r.NextHop,
+		Input:    r.Input,
+		Output:   r.Output,
+		Packets:  r.Packets,
+		Bytes:    r.Bytes,
+		First:    r.First,
+		Last:     r.Last,
+		SrcPort:  r.SrcPort,
+		DstPort:  r.DstPort,
+		TCPFlags: read.TCPFlagNames(r.TCPFlags),
+		Protocol: protocolJSON{r.Protocol, read.ProtocolName(r.Protocol)},
+		ToS:      r.ToS,
+		SrcAS:    r.SrcAS,
+		DstAS:    r.DstAS,
+		SrcMask:  r.SrcMask,
+		DstMask:  r.DstMask,
+	})
+}
@@ -1,17 +1,24 @@
 package netflow6
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"time"
 
 	"github.com/tehmaze/netflow/read"
+	"github.com/tehmaze/netflow/write"
 )
 
 const (
 	// Version word in the Packet Header
 	Version uint16 = 0x0006
+	// HeaderLen is the size of the Packet Header in bytes
+	HeaderLen = 24
+	// RecordLen is the size of a Flow Record in bytes
+	RecordLen = 52
 )
 
 // Packet is a NetFlow v1 packet
@@ -24,12 +31,20 @@ func (p *Packet) Unmarshal(r io.Reader) error {
 	if err := p.Header.Unmarshal(r); err != nil {
 		return err
 	}
+	// Read all records at once, so we can validate the number of records
+	// announced in the header against the available data.
+	data := make([]byte, int(p.Header.Count)*FlowRecord{}.Len())
+	if err := read.Full(data, r); err != nil {
+		return fmt.Errorf("protocol error: %d flows announced: %w", p.Header.Count, err)
+	}
 	p.Records = make([]*FlowRecord, p.Header.Count)
 	for i := range p.Records {
 		p.Records[i] = new(FlowRecord)
-		if err := p.Records[i].Unmarshal(r); err != nil {
+		n, err := p.Records[i].UnmarshalBytes(data)
+		if err != nil {
 			return err
 		}
+		data = data[n:]
 	}
 	return nil
 }
@@ -46,45 +61,88 @@ type PacketHeader struct {
 	SamplingInterval uint16
 }
 
+// Len returns the length of the Packet Header in bytes.
+func (h PacketHeader) Len() int {
+	return HeaderLen
+}
+
+// Reset sets all fields of the header to their zero value.
+func (h *PacketHeader) Reset() {
+	*h = PacketHeader{}
+}
+
+// ProtocolVersion returns the version announced in the header.
+func (h PacketHeader) ProtocolVersion() uint16 {
+	return h.Version
+}
+
+// Sequence returns the flow sequence number.
+func (h PacketHeader) Sequence() uint32 {
+	return h.FlowSequence
+}
+
+// ExportTime returns the time the packet was exported, combining the seconds
+// and nanoseconds since the epoch.
+func (h PacketHeader) ExportTime() time.Time {
+	return h.Unix
+}
+
+// Uptime returns the uptime of the exporter.
+func (h PacketHeader) Uptime() time.Duration {
+	return h.SysUptime
+}
+
 func (h PacketHeader) String() string {
 	return fmt.Sprintf("v=%d, count=%d, uptime=%s, time=%s, seq=%d, type=%d, id=%d, interval=%d",
 		h.Version, h.Count, time.Duration(h.SysUptime)*time.Second, h.Unix, h.FlowSequence, h.EngineType, h.EngineID, h.SamplingInterval)
 }
 
 func (h *PacketHeader) Unmarshal(r io.Reader) error {
-	if err := read.Uint16(&h.Version, r); err != nil {
-		return err
-	}
-	if err := read.Uint16(&h.Count, r); err != nil {
+	var b [HeaderLen]byte
+	if err := read.Full(b[:], r); err != nil {
 		return err
 	}
+	_, err := h.UnmarshalBytes(b[:])
+	return err
+}
+
+// UnmarshalBytes decodes the header from b and returns the number of bytes
+// consumed.
+func (h *PacketHeader) UnmarshalBytes(b []byte) (int, error) {
+	if err := read.Need(b, HeaderLen); err != nil {
+		return 0, err
+	}
+	h.Version = binary.BigEndian.Uint16(b[0:])
+	h.Count = binary.BigEndian.Uint16(b[2:])
 	// The spec says at most 24 flows in one packet, but reality disagrees.
 	if h.Count < 1 || h.Count > 32 {
-		return fmt.Errorf("protocol error: %d flows out of bounds", h.Count)
-	}
-	var u uint32
-	if err := read.Uint32(&u, r); err != nil {
-		return err
-	}
-	h.SysUptime = time.Duration(u) * time.Millisecond
-	var t uint64
-	if err := read.Uint64(&t, r); err != nil {
-		return err
-	}
-	h.Unix = time.Unix(int64(t>>32), int64(t&0xffffffff))
-	if err := read.Uint32(&h.FlowSequence, r); err != nil {
-		return err
-	}
-	if err := read.Uint8(&h.EngineType, r); err != nil {
-		return err
-	}
-	if err := read.Uint8(&h.EngineID, r); err != nil {
-		return err
-	}
-	if err := read.Uint16(&h.SamplingInterval, r); err != nil {
-		return err
-	}
-	return nil
+		return 0, fmt.Errorf("protocol error: %d flows out of bounds", h.Count)
+	}
+	h.SysUptime = time.Duration(binary.BigEndian.Uint32(b[4:])) * time.Millisecond
+	h.Unix = time.Unix(int64(binary.BigEndian.Uint32(b[8:])), int64(binary.BigEndian.Uint32(b[12:])))
+	h.FlowSequence = binary.BigEndian.Uint32(b[16:])
+	h.EngineType = b[20]
+	h.EngineID = b[21]
+	h.SamplingInterval = binary.BigEndian.Uint16(b[22:])
+	return HeaderLen, nil
+}
+
+// AppendBytes appends the wire format of the header to b and returns the
+// extended slice.
+func (h *PacketHeader) AppendBytes(b []byte) []byte {
+	n := len(b)
+	b = append(b, make([]byte, HeaderLen)...)
+	p := b[n:]
+	binary.BigEndian.PutUint16(p[0:], h.Version)
+	binary.BigEndian.PutUint16(p[2:], h.Count)
+	binary.BigEndian.PutUint32(p[4:], uint32(h.SysUptime/time.Millisecond))
+	binary.BigEndian.PutUint32(p[8:], uint32(h.Unix.Unix()))
+	binary.BigEndian.PutUint32(p[12:], uint32(h.Unix.Nanosecond()))
+	binary.BigEndian.PutUint32(p[16:], h.FlowSequence)
+	p[20] = h.EngineType
+	p[21] = h.EngineID
+	binary.BigEndian.PutUint16(p[22:], h.SamplingInterval)
+	return b
 }
 
 // FlowRecord is a NetFlow v1 Flow Record
@@ -133,79 +191,168 @@ type FlowRecord struct {
 	Pad3 uint32 // 48-51
 }
 
+// Len returns the length of the Flow Record in bytes.
+func (r FlowRecord) Len() int {
+	return RecordLen
+}
+
+// Reset sets all fields of the record to their zero value.
+func (r *FlowRecord) Reset() {
+	*r = FlowRecord{}
+}
+
+// Clone returns a copy of the record with its own copy of the addresses, so it
+// stays valid after r is reused, such as the record returned by a
+// RecordIterator.
+func (r *FlowRecord) Clone() *FlowRecord {
+	c := *r
+	c.SrcAddr = append(net.IP(nil), r.SrcAddr...)
+	c.DstAddr = append(net.IP(nil), r.DstAddr...)
+	c.NextHop = append(net.IP(nil), r.NextHop...)
+	return &c
+}
+
+// AppendBytes appends the wire format of the record to b and returns the
+// extended slice. It does not allocate if b has enough capacity.
+func (r *FlowRecord) AppendBytes(b []byte) []byte {
+	n := len(b)
+	b = append(b, make([]byte, RecordLen)...)
+	p := b[n:]
+	write.PutIPv4(p[0:], r.SrcAddr)
+	write.PutIPv4(p[4:], r.DstAddr)
+	write.PutIPv4(p[8:], r.NextHop)
+	binary.BigEndian.PutUint16(p[12:], r.Input)
+	binary.BigEndian.PutUint16(p[14:], r.Output)
+	binary.BigEndian.PutUint32(p[16:], r.Packets)
+	binary.BigEndian.PutUint32(p[20:], r.Bytes)
+	binary.BigEndian.PutUint32(p[24:], r.First)
+	binary.BigEndian.PutUint32(p[28:], r.Last)
+	binary.BigEndian.PutUint16(p[32:], r.SrcPort)
+	binary.BigEndian.PutUint16(p[34:], r.DstPort)
+	p[36] = r.Pad1
+	p[37] = r.TCPFlags
+	p[38] = r.Protocol
+	p[39] = r.ToS
+	binary.BigEndian.PutUint16(p[40:], r.SrcAS)
+	binary.BigEndian.PutUint16(p[42:], r.DstAS)
+	p[44] = r.SrcMask
+	p[45] = r.DstMask
+	binary.BigEndian.PutUint16(p[46:], r.Pad2)
+	binary.BigEndian.PutUint32(p[48:], r.Pad3)
+	return b
+}
+
 func (r FlowRecord) String() string {
 	return fmt.Sprintf("%s:%d -> %s:%d", r.SrcAddr, r.SrcPort, r.DstAddr, r.DstPort)
 }
 
+// SrcAddrNetip returns the source address as netip.Addr.
+func (r *FlowRecord) SrcAddrNetip() netip.Addr {
+	return read.Addr(r.SrcAddr)
+}
+
+// DstAddrNetip returns the destination address as netip.Addr.
+func (r *FlowRecord) DstAddrNetip() netip.Addr {
+	return read.Addr(r.DstAddr)
+}
+
+// SrcPrefix returns the source network, using the source mask length.
+func (r *FlowRecord) SrcPrefix() net.IPNet {
+	return read.IPv4Prefix(r.SrcAddr, r.SrcMask)
+}
+
+// DstPrefix returns the destination network, using the destination mask
+// length.
+func (r *FlowRecord) DstPrefix() net.IPNet {
+	return read.IPv4Prefix(r.DstAddr, r.DstMask)
+}
+
+// Equal compares the record fields, ignoring the padding fields.
+func (r *FlowRecord) Equal(o *FlowRecord) bool {
+	return r.SrcAddr.Equal(o.SrcAddr) &&
+		r.DstAddr.Equal(o.DstAddr) &&
+		r.NextHop.Equal(o.NextHop) &&
+		r.Input == o.Input &&
+		r.Output == o.Output &&
+		r.Packets == o.Packets &&
+		r.Bytes == o.Bytes &&
+		r.First == o.First &&
+		r.Last == o.Last &&
+		r.SrcPort == o.SrcPort &&
+		r.DstPort == o.DstPort &&
+		r.TCPFlags == o.TCPFlags &&
+		r.Protocol == o.Protocol &&
+		r.ToS == o.ToS &&
+		r.SrcAS == o.SrcAS &&
+		r.DstAS == o.DstAS &&
+		r.SrcMask == o.SrcMask &&
+		r.DstMask == o.DstMask
+}
+
 func (r *FlowRecord) Unmarshal(h io.Reader) error {
-	r.SrcAddr = make(net.IP, 4)
-	if _, err := h.Read(r.SrcAddr); err != nil { // 0-3
-		return err
-	}
-	r.DstAddr = make(net.IP, 4)
-	if _, err := h.Read(r.DstAddr); err != nil { // 4-7
-		return err
-	}
-	r.NextHop = make(net.IP, 4)
-	if _, err := h.Read(r.NextHop); err != nil { // 8-11
-		return err
-	}
-	if err := read.Uint16(&r.Input, h); err != nil { // 12-13
-		return err
-	}
-	if err := read.Uint16(&r.Output, h); err != nil { // 14-15
-		return err
-	}
-	if err := read.Uint32(&r.Packets, h); err != nil { // 16-19
-		return err
-	}
-	if err := read.Uint32(&r.Bytes, h); err != nil { // 20-23
-		return err
-	}
-	if err := read.Uint32(&r.First, h); err != nil { // 24-27
-		return err
-	}
-	if err := read.Uint32(&r.Last, h); err != nil { // 28-31
-		return err
-	}
-	if err := read.Uint16(&r.SrcPort, h); err != nil { // 32-33
-		return err
-	}
-	if err := read.Uint16(&r.DstPort, h); err != nil { // 34-35
-		return err
-	}
-	if err := read.Uint8(&r.Pad1, h); err != nil { // 36
-		return err
-	}
-	if err := read.Uint8(&r.TCPFlags, h); err != nil { // 37
-		return err
-	}
-	if err := read.Uint8(&r.Protocol, h); err != nil { // 38
-		return err
-	}
-	if err := read.Uint8(&r.ToS, h); err != nil { // 39
-		return err
-	}
-	if err := read.Uint16(&r.SrcAS, h); err != nil { // 40-41
-		return err
-	}
-	if err := read.Uint16(&r.DstAS, h); err != nil { // 42-43
-		return err
-	}
-	if err := read.Uint8(&r.SrcMask, h); err != nil { // 44
-		return err
-	}
-	if err := read.Uint8(&r.DstMask, h); err != nil { // 45
-		return err
-	}
-	if err := read.Uint16(&r.Pad2, h); err != nil { // 46-47
-		return err
-	}
-	if err := read.Uint32(&r.Pad3, h); err != nil { // 47-51
+	var b [RecordLen]byte
+	if err := read.Full(b[:], h); err != nil {
 		return err
 	}
+	_, err := r.UnmarshalBytes(b[:])
+	return err
+}
 
-	return nil
+// WriteTo writes the wire format of the record to w, it implements
+// io.WriterTo.
+func (r *FlowRecord) WriteTo(w io.Writer) (int64, error) {
+	var b [RecordLen]byte
+	n, err := w.Write(r.AppendBytes(b[:0]))
+	return int64(n), err
+}
+
+// ReadFrom reads a single record from rd, it implements io.ReaderFrom. Unlike
+// most implementations it does not read until EOF, but exactly RecordLen
+// bytes.
+func (r *FlowRecord) ReadFrom(rd io.Reader) (int64, error) {
+	var b [RecordLen]byte
+	n, err := read.FullN(b[:], rd)
+	if err != nil {
+		return int64(n), err
+	}
+	_, err = r.UnmarshalBytes(b[:])
+	return int64(n), err
+}
+
+// UnmarshalBytes decodes the record from b and returns the number of bytes
+// consumed.
+func (r *FlowRecord) UnmarshalBytes(b []byte) (int, error) {
+	if err := read.Need(b, RecordLen); err != nil {
+		return 0, err
+	}
+	// Copy the addresses in one allocation, so the record doesn't keep a
+	// reference to b.
+	r.decode(b, make(net.IP, 12))
+	return RecordLen, nil
+}
+
+// decode the record from b, using ip as storage for the addresses.
+func (r *FlowRecord) decode(b []byte, ip net.IP) {
+	copy(ip, b[0:12])
+	r.SrcAddr, r.DstAddr, r.NextHop = ip[0:4:4], ip[4:8:8], ip[8:12:12]
+	r.Input = binary.BigEndian.Uint16(b[12:])
+	r.Output = binary.BigEndian.Uint16(b[14:])
+	r.Packets = binary.BigEndian.Uint32(b[16:])
+	r.Bytes = binary.BigEndian.Uint32(b[20:])
+	r.First = binary.BigEndian.Uint32(b[24:])
+	r.Last = binary.BigEndian.Uint32(b[28:])
+	r.SrcPort = binary.BigEndian.Uint16(b[32:])
+	r.DstPort = binary.BigEndian.Uint16(b[34:])
+	r.Pad1 = b[36]
+	r.TCPFlags = b[37]
+	r.Protocol = b[38]
+	r.ToS = b[39]
+	r.SrcAS = binary.BigEndian.Uint16(b[40:])
+	r.DstAS = binary.BigEndian.Uint16(b[42:])
+	r.SrcMask = b[44]
+	r.DstMask = b[45]
+	r.Pad2 = binary.BigEndian.Uint16(b[46:])
+	r.Pad3 = binary.BigEndian.Uint32(b[48:])
 }
 
 func (f FlowRecord) SampleInterval() int {
@@ -0,0 +1,115 @@
+package netflow7
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+// testPacketData is a datagram with count copies of testRecord.
+func testPacketData(count int) []byte {
+	data := testHeader(uint16(count))
+	for i := 0; i < count; i++ {
+		data = append(data, testRecord...)
+	}
+	return data
+}
+
+func TestRecordIterator(t *testing.T) {
+	want := new(FlowRecord)
+	if err := want.Unmarshal(bytes.NewReader(testRecord)); err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := NewRecordIterator(testPacketData(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it.Header.Count != 3 {
+		t.Fatalf("expected count 3, got %d", it.Header.Count)
+	}
+	var n int
+	for r, ok := it.Next(); ok; r, ok = it.Next() {
+		if !r.Equal(want) {
+			t.Errorf("record %d: expected %+v, got %+v", n, want, r)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 records, got %d", n)
+	}
+
+	data := testPacketData(2)
+	if n := testing.AllocsPerRun(100, func() {
+		if err := it.Reset(data); err != nil {
+			t.Fatal(err)
+		}
+		for _, ok := it.Next(); ok; _, ok = it.Next() {
+		}
+	}); n != 0 {
+		t.Errorf("expected no allocations, got %.0f", n)
+	}
+}
+
+func TestRecordIteratorShort(t *testing.T) {
+	data := testPacketData(2)
+	if _, err := NewRecordIterator(data[:len(data)-1]); !errors.Is(err, read.ErrShortPacket) {
+		t.Fatalf("expected short packet error, got %v", err)
+	}
+}
+
+func BenchmarkPacketUnmarshal(b *testing.B) {
+	data := testPacketData(30)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := new(Packet)
+		if err := p.Unmarshal(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+		for _, r := range p.Records {
+			_ = r.Bytes
+		}
+	}
+}
+
+func BenchmarkRecordIterator(b *testing.B) {
+	data := testPacketData(30)
+	it := new(RecordIterator)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := it.Reset(data); err != nil {
+			b.Fatal(err)
+		}
+		for r, ok := it.Next(); ok; r, ok = it.Next() {
+			_ = r.Bytes
+		}
+	}
+}
+
+func TestFlowRecordClone(t *testing.T) {
+	data := testPacketData(2)
+	it, err := NewRecordIterator(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := it.Next()
+	c := r.Clone()
+
+	// The next record reuses the address storage of the iterator.
+	data[HeaderLen+RecordLen] = 0x0a
+	data[HeaderLen+RecordLen+48] = 0x0a
+	it.Next()
+	r.Packets++
+
+	if c.Packets != 10 || !c.SrcAddr.Equal(net.IP(testRecord[0:4])) || !c.RouterSC.Equal(net.IP(testRecord[48:52])) {
+		t.Errorf("expected clone to be unchanged, got %+v", c)
+	}
+	if r.SrcAddr[0] != 0x0a || r.RouterSC[0] != 0x0a {
+		t.Fatalf("expected the iterator to reuse the record, got %+v", r)
+	}
+}
@@ -0,0 +1,27 @@
+package netflow7
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestFlowRecordMarshalJSON(t *testing.T) {
+	r := new(FlowRecord)
+	if err := r.Unmarshal(bytes.NewReader(testRecord)); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"srcAddr":"192.168.1.10","dstAddr":"10.0.0.5","nextHop":"192.168.1.1",` +
+		`"input":2,"output":5,"packets":10,"bytes":1500,"first":100000,"last":101000,` +
+		`"srcPort":443,"dstPort":51000,"tcpFlags":"SYN,ACK","protocol":{"number":6,"name":"tcp"},` +
+		`"tos":0,"srcAs":65000,"dstAs":15,"srcMask":24,"dstMask":16,"flags":0,"routerSC":"192.168.1.254"}`
+	if string(b) != want {
+		t.Fatalf("expected JSON\n%s, got\n%s", want, b)
+	}
+}
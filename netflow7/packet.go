@@ -1,17 +1,42 @@
 package netflow7
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"time"
 
 	"github.com/tehmaze/netflow/read"
+	"github.com/tehmaze/netflow/write"
 )
 
 const (
 	// Version word in the Packet Header
 	Version uint16 = 0x0007
+	// HeaderLen is the size of the Packet Header in bytes
+	HeaderLen = 24
+	// RecordLen is the size of a Flow Record in bytes
+	RecordLen = 52
+)
+
+// Bits of the FlowRecord Flags. The Catalyst 5000 series switches export
+// flows according to their flow mask; fields not covered by the mask are not
+// valid and are usually zero.
+const (
+	// FlagInvalid marks the flow itself as invalid.
+	FlagInvalid uint16 = 0x0001
+	// FlagSrcInvalid marks the source address and mask as not valid, as
+	// exported with a destination only flow mask.
+	FlagSrcInvalid uint16 = 0x0002
+	// FlagPortsInvalid marks the ports and protocol as not valid, as exported
+	// with a source-destination flow mask.
+	FlagPortsInvalid uint16 = 0x0004
+	// FlagShortcut marks the flow as switched by the Catalyst, bypassing the
+	// router in RouterSC.
+	FlagShortcut uint16 = 0x0008
 )
 
 // Packet is a NetFlow v7 packet
@@ -24,12 +49,20 @@ func (p *Packet) Unmarshal(r io.Reader) error {
 	if err := p.Header.Unmarshal(r); err != nil {
 		return err
 	}
+	// Read all records at once, so we can validate the number of records
+	// announced in the header against the available data.
+	data := make([]byte, int(p.Header.Count)*FlowRecord{}.Len())
+	if n, err := read.FullN(data, r); err != nil {
+		return fmt.Errorf("protocol error: %d flows announced: %w", p.Header.Count, shortRecords(err, HeaderLen, n))
+	}
 	p.Records = make([]*FlowRecord, p.Header.Count)
 	for i := range p.Records {
 		p.Records[i] = new(FlowRecord)
-		if err := p.Records[i].Unmarshal(r); err != nil {
+		n, err := p.Records[i].UnmarshalBytes(data)
+		if err != nil {
 			return err
 		}
+		data = data[n:]
 	}
 	return nil
 }
@@ -44,36 +77,107 @@ type PacketHeader struct {
 	Reserved     uint32
 }
 
+// Len returns the length of the Packet Header in bytes.
+func (h PacketHeader) Len() int {
+	return HeaderLen
+}
+
+// Reset sets all fields of the header to their zero value.
+func (h *PacketHeader) Reset() {
+	*h = PacketHeader{}
+}
+
+// ProtocolVersion returns the version announced in the header.
+func (h PacketHeader) ProtocolVersion() uint16 {
+	return h.Version
+}
+
+// Sequence returns the flow sequence number.
+func (h PacketHeader) Sequence() uint32 {
+	return h.FlowSequence
+}
+
+// ExportTime returns the time the packet was exported, combining the seconds
+// and nanoseconds since the epoch.
+func (h PacketHeader) ExportTime() time.Time {
+	return h.Unix
+}
+
+// Uptime returns the uptime of the exporter.
+func (h PacketHeader) Uptime() time.Duration {
+	return h.SysUptime
+}
+
 func (h PacketHeader) String() string {
 	return fmt.Sprintf("v=%d, count=%d, uptime=%s, time=%s, seq=%d",
 		h.Version, h.Count, time.Duration(h.SysUptime)*time.Second, h.Unix, h.FlowSequence)
 }
 
 func (h *PacketHeader) Unmarshal(r io.Reader) error {
-	if err := read.Uint16(&h.Version, r); err != nil {
+	var b [HeaderLen]byte
+	if err := read.Full(b[:], r); err != nil {
 		return err
 	}
-	if err := read.Uint16(&h.Count, r); err != nil {
-		return err
+	_, err := h.UnmarshalBytes(b[:])
+	return err
+}
+
+// UnmarshalBytes decodes the header from b and returns the number of bytes
+// consumed.
+func (h *PacketHeader) UnmarshalBytes(b []byte) (int, error) {
+	if err := read.Need(b, HeaderLen); err != nil {
+		return 0, err
 	}
+	h.Version = binary.BigEndian.Uint16(b[0:])
+	h.Count = binary.BigEndian.Uint16(b[2:])
 	// The spec says at most 24 flows in one packet, but reality disagrees.
 	if h.Count < 1 || h.Count > 32 {
-		return fmt.Errorf("protocol error: %d flows out of bounds", h.Count)
+		return 0, fmt.Errorf("protocol error: %d flows out of bounds", h.Count)
+	}
+	h.SysUptime = time.Duration(binary.BigEndian.Uint32(b[4:])) * time.Millisecond
+	h.Unix = time.Unix(int64(binary.BigEndian.Uint32(b[8:])), int64(binary.BigEndian.Uint32(b[12:])))
+	h.FlowSequence = binary.BigEndian.Uint32(b[16:])
+	h.Reserved = binary.BigEndian.Uint32(b[20:])
+	return HeaderLen, nil
+}
+
+// AppendBytes appends the wire format of the header to b and returns the
+// extended slice.
+func (h *PacketHeader) AppendBytes(b []byte) []byte {
+	n := len(b)
+	b = append(b, make([]byte, HeaderLen)...)
+	p := b[n:]
+	binary.BigEndian.PutUint16(p[0:], h.Version)
+	binary.BigEndian.PutUint16(p[2:], h.Count)
+	binary.BigEndian.PutUint32(p[4:], uint32(h.SysUptime/time.Millisecond))
+	binary.BigEndian.PutUint32(p[8:], uint32(h.Unix.Unix()))
+	binary.BigEndian.PutUint32(p[12:], uint32(h.Unix.Nanosecond()))
+	binary.BigEndian.PutUint32(p[16:], h.FlowSequence)
+	binary.BigEndian.PutUint32(p[20:], h.Reserved)
+	return b
+}
+
+// Marshal writes the header in wire format.
+func (h *PacketHeader) Marshal(w io.Writer) error {
+	if err := write.Uint16(h.Version, w); err != nil { // 0-1
+		return err
 	}
-	var u uint32
-	if err := read.Uint32(&u, r); err != nil {
+	if err := write.Uint16(h.Count, w); err != nil { // 2-3
 		return err
 	}
-	h.SysUptime = time.Duration(u) * time.Millisecond
-	var t uint64
-	if err := read.Uint64(&t, r); err != nil {
+	if err := write.Uint32(uint32(h.SysUptime/time.Millisecond), w); err != nil { // 4-7
 		return err
 	}
-	h.Unix = time.Unix(int64(t>>32), int64(t&0xffffffff))
-	if err := read.Uint32(&h.FlowSequence, r); err != nil {
+	if err := write.Uint32(uint32(h.Unix.Unix()), w); err != nil { // 8-11
 		return err
 	}
-	if err := read.Uint32(&h.Reserved, r); err != nil {
+	if err := write.Uint32(uint32(h.Unix.Nanosecond()), w); err != nil { // 12-15
+		return err
+	}
+	if err := write.Uint32(h.FlowSequence, w); err != nil { // 16-19
+		return err
+	}
+	if err := write.Uint32(h.Reserved, w); err != nil { // 20-23
 		return err
 	}
 	return nil
@@ -125,82 +229,374 @@ type FlowRecord struct {
 	RouterSC net.IP // 48-51
 }
 
+// fields are the names of the Flow Record fields by their offset.
+var fields = []struct {
+	offset int
+	name   string
+}{
+	{0, "SrcAddr"}, {4, "DstAddr"}, {8, "NextHop"}, {12, "Input"}, {14, "Output"},
+	{16, "Packets"}, {20, "Bytes"}, {24, "First"}, {28, "Last"}, {32, "SrcPort"},
+	{34, "DstPort"}, {36, "Pad1"}, {37, "TCPFlags"}, {38, "Protocol"}, {39, "ToS"},
+	{40, "SrcAS"}, {42, "DstAS"}, {44, "SrcMask"}, {45, "DstMask"}, {46, "Flags"},
+	{48, "RouterSC"},
+}
+
+// fieldAt returns the name of the Flow Record field at the offset.
+func fieldAt(offset int) string {
+	name := fields[0].name
+	for _, f := range fields {
+		if f.offset > offset {
+			break
+		}
+		name = f.name
+	}
+	return name
+}
+
+// shortRecords adds the position where the records ran out to a
+// read.ShortReadError, given the offset of the first record and the number of
+// bytes of records available.
+func shortRecords(err error, offset, n int) error {
+	var short *read.ShortReadError
+	if errors.As(err, &short) {
+		short.Offset = offset + n
+		short.Field = fmt.Sprintf("record %d field %s", n/RecordLen, fieldAt(n%RecordLen))
+	}
+	return err
+}
+
+// Len returns the length of the Flow Record in bytes.
+func (r FlowRecord) Len() int {
+	return RecordLen
+}
+
+// Reset sets all fields of the record to their zero value.
+func (r *FlowRecord) Reset() {
+	*r = FlowRecord{}
+}
+
+// Clone returns a copy of the record with its own copy of the addresses, so it
+// stays valid after r is reused, such as the record returned by a
+// RecordIterator.
+func (r *FlowRecord) Clone() *FlowRecord {
+	c := *r
+	c.SrcAddr = append(net.IP(nil), r.SrcAddr...)
+	c.DstAddr = append(net.IP(nil), r.DstAddr...)
+	c.NextHop = append(net.IP(nil), r.NextHop...)
+	c.RouterSC = append(net.IP(nil), r.RouterSC...)
+	return &c
+}
+
+// AppendBytes appends the wire format of the record to b and returns the
+// extended slice. It does not allocate if b has enough capacity.
+func (r *FlowRecord) AppendBytes(b []byte) []byte {
+	n := len(b)
+	b = append(b, make([]byte, RecordLen)...)
+	p := b[n:]
+	write.PutIPv4(p[0:], r.SrcAddr)
+	write.PutIPv4(p[4:], r.DstAddr)
+	write.PutIPv4(p[8:], r.NextHop)
+	binary.BigEndian.PutUint16(p[12:], r.Input)
+	binary.BigEndian.PutUint16(p[14:], r.Output)
+	binary.BigEndian.PutUint32(p[16:], r.Packets)
+	binary.BigEndian.PutUint32(p[20:], r.Bytes)
+	binary.BigEndian.PutUint32(p[24:], r.First)
+	binary.BigEndian.PutUint32(p[28:], r.Last)
+	binary.BigEndian.PutUint16(p[32:], r.SrcPort)
+	binary.BigEndian.PutUint16(p[34:], r.DstPort)
+	p[36] = r.Pad1
+	p[37] = r.TCPFlags
+	p[38] = r.Protocol
+	p[39] = r.ToS
+	binary.BigEndian.PutUint16(p[40:], r.SrcAS)
+	binary.BigEndian.PutUint16(p[42:], r.DstAS)
+	p[44] = r.SrcMask
+	p[45] = r.DstMask
+	binary.BigEndian.PutUint16(p[46:], r.Flags)
+	write.PutIPv4(p[48:], r.RouterSC)
+	return b
+}
+
 func (r FlowRecord) String() string {
 	return fmt.Sprintf("%s:%d -> %s:%d", r.SrcAddr, r.SrcPort, r.DstAddr, r.DstPort)
 }
 
+// DetailString returns a single line description of the record, including the
+// SNMP interfaces, protocol, counters and TCP flags, such as:
+//
+//	if2->if5 tcp 192.168.1.1:443 -> 10.0.0.5:51000 pkts=10 bytes=1500 [SYN,ACK]
+func (r FlowRecord) DetailString() string {
+	return fmt.Sprintf("if%d->if%d %s %s pkts=%d bytes=%d [%s]",
+		r.Input, r.Output, read.ProtocolName(r.Protocol), r.String(),
+		r.Packets, r.Bytes, read.TCPFlagNames(r.TCPFlags))
+}
+
+// SrcAddrNetip returns the source address as netip.Addr.
+func (r *FlowRecord) SrcAddrNetip() netip.Addr {
+	return read.Addr(r.SrcAddr)
+}
+
+// DstAddrNetip returns the destination address as netip.Addr.
+func (r *FlowRecord) DstAddrNetip() netip.Addr {
+	return read.Addr(r.DstAddr)
+}
+
+// SrcPrefix returns the source network, using the source mask length.
+func (r *FlowRecord) SrcPrefix() net.IPNet {
+	return read.IPv4Prefix(r.SrcAddr, r.SrcMask)
+}
+
+// DstPrefix returns the destination network, using the destination mask
+// length.
+func (r *FlowRecord) DstPrefix() net.IPNet {
+	return read.IPv4Prefix(r.DstAddr, r.DstMask)
+}
+
+// Equal compares the record fields, ignoring the padding fields.
+func (r *FlowRecord) Equal(o *FlowRecord) bool {
+	return r.SrcAddr.Equal(o.SrcAddr) &&
+		r.DstAddr.Equal(o.DstAddr) &&
+		r.NextHop.Equal(o.NextHop) &&
+		r.Input == o.Input &&
+		r.Output == o.Output &&
+		r.Packets == o.Packets &&
+		r.Bytes == o.Bytes &&
+		r.First == o.First &&
+		r.Last == o.Last &&
+		r.SrcPort == o.SrcPort &&
+		r.DstPort == o.DstPort &&
+		r.TCPFlags == o.TCPFlags &&
+		r.Protocol == o.Protocol &&
+		r.ToS == o.ToS &&
+		r.SrcAS == o.SrcAS &&
+		r.DstAS == o.DstAS &&
+		r.SrcMask == o.SrcMask &&
+		r.DstMask == o.DstMask &&
+		r.Flags == o.Flags &&
+		r.RouterSC.Equal(o.RouterSC)
+}
+
 func (r *FlowRecord) Unmarshal(h io.Reader) error {
-	r.SrcAddr = make(net.IP, 4)
-	if _, err := h.Read(r.SrcAddr); err != nil { // 0-3
+	var b [RecordLen]byte
+	if err := read.Full(b[:], h); err != nil {
+		return err
+	}
+	_, err := r.UnmarshalBytes(b[:])
+	return err
+}
+
+// WriteTo writes the wire format of the record to w, it implements
+// io.WriterTo.
+func (r *FlowRecord) WriteTo(w io.Writer) (int64, error) {
+	var b [RecordLen]byte
+	n, err := w.Write(r.AppendBytes(b[:0]))
+	return int64(n), err
+}
+
+// ReadFrom reads a single record from rd, it implements io.ReaderFrom. Unlike
+// most implementations it does not read until EOF, but exactly RecordLen
+// bytes.
+func (r *FlowRecord) ReadFrom(rd io.Reader) (int64, error) {
+	var b [RecordLen]byte
+	n, err := read.FullN(b[:], rd)
+	if err != nil {
+		return int64(n), err
+	}
+	_, err = r.UnmarshalBytes(b[:])
+	return int64(n), err
+}
+
+// UnmarshalBytes decodes the record from b and returns the number of bytes
+// consumed.
+func (r *FlowRecord) UnmarshalBytes(b []byte) (int, error) {
+	if err := read.Need(b, RecordLen); err != nil {
+		return 0, shortRecords(err, 0, len(b))
+	}
+	// Copy the addresses in one allocation, so the record doesn't keep a
+	// reference to b.
+	r.decode(b, make(net.IP, 16))
+	return RecordLen, nil
+}
+
+// decode the record from b, using ip as storage for the addresses.
+func (r *FlowRecord) decode(b []byte, ip net.IP) {
+	copy(ip, b[0:12])
+	copy(ip[12:], b[48:52])
+	r.SrcAddr, r.DstAddr, r.NextHop, r.RouterSC = ip[0:4:4], ip[4:8:8], ip[8:12:12], ip[12:16:16]
+	r.Input = binary.BigEndian.Uint16(b[12:])
+	r.Output = binary.BigEndian.Uint16(b[14:])
+	r.Packets = binary.BigEndian.Uint32(b[16:])
+	r.Bytes = binary.BigEndian.Uint32(b[20:])
+	r.First = binary.BigEndian.Uint32(b[24:])
+	r.Last = binary.BigEndian.Uint32(b[28:])
+	r.SrcPort = binary.BigEndian.Uint16(b[32:])
+	r.DstPort = binary.BigEndian.Uint16(b[34:])
+	r.Pad1 = b[36]
+	r.TCPFlags = b[37]
+	r.Protocol = b[38]
+	r.ToS = b[39]
+	r.SrcAS = binary.BigEndian.Uint16(b[40:])
+	r.DstAS = binary.BigEndian.Uint16(b[42:])
+	r.SrcMask = b[44]
+	r.DstMask = b[45]
+	r.Flags = binary.BigEndian.Uint16(b[46:])
+}
+
+// Marshal writes the flow record in the same wire order as Unmarshal reads it.
+func (r *FlowRecord) Marshal(w io.Writer) error {
+	if err := write.IPv4(r.SrcAddr, w); err != nil { // 0-3
 		return err
 	}
-	r.DstAddr = make(net.IP, 4)
-	if _, err := h.Read(r.DstAddr); err != nil { // 4-7
+	if err := write.IPv4(r.DstAddr, w); err != nil { // 4-7
 		return err
 	}
-	r.NextHop = make(net.IP, 4)
-	if _, err := h.Read(r.NextHop); err != nil { // 8-11
+	if err := write.IPv4(r.NextHop, w); err != nil { // 8-11
 		return err
 	}
-	if err := read.Uint16(&r.Input, h); err != nil { // 12-13
+	if err := write.Uint16(r.Input, w); err != nil { // 12-13
 		return err
 	}
-	if err := read.Uint16(&r.Output, h); err != nil { // 14-15
+	if err := write.Uint16(r.Output, w); err != nil { // 14-15
 		return err
 	}
-	if err := read.Uint32(&r.Packets, h); err != nil { // 16-19
+	if err := write.Uint32(r.Packets, w); err != nil { // 16-19
 		return err
 	}
-	if err := read.Uint32(&r.Bytes, h); err != nil { // 20-23
+	if err := write.Uint32(r.Bytes, w); err != nil { // 20-23
 		return err
 	}
-	if err := read.Uint32(&r.First, h); err != nil { // 24-27
+	if err := write.Uint32(r.First, w); err != nil { // 24-27
 		return err
 	}
-	if err := read.Uint32(&r.Last, h); err != nil { // 28-31
+	if err := write.Uint32(r.Last, w); err != nil { // 28-31
 		return err
 	}
-	if err := read.Uint16(&r.SrcPort, h); err != nil { // 32-33
+	if err := write.Uint16(r.SrcPort, w); err != nil { // 32-33
 		return err
 	}
-	if err := read.Uint16(&r.DstPort, h); err != nil { // 34-35
+	if err := write.Uint16(r.DstPort, w); err != nil { // 34-35
 		return err
 	}
-	if err := read.Uint8(&r.Pad1, h); err != nil { // 36
+	if err := write.Uint8(r.Pad1, w); err != nil { // 36
 		return err
 	}
-	if err := read.Uint8(&r.TCPFlags, h); err != nil { // 37
+	if err := write.Uint8(r.TCPFlags, w); err != nil { // 37
 		return err
 	}
-	if err := read.Uint8(&r.Protocol, h); err != nil { // 38
+	if err := write.Uint8(r.Protocol, w); err != nil { // 38
 		return err
 	}
-	if err := read.Uint8(&r.ToS, h); err != nil { // 39
+	if err := write.Uint8(r.ToS, w); err != nil { // 39
 		return err
 	}
-	if err := read.Uint16(&r.SrcAS, h); err != nil { // 40-41
+	if err := write.Uint16(r.SrcAS, w); err != nil { // 40-41
 		return err
 	}
-	if err := read.Uint16(&r.DstAS, h); err != nil { // 42-43
+	if err := write.Uint16(r.DstAS, w); err != nil { // 42-43
 		return err
 	}
-	if err := read.Uint8(&r.SrcMask, h); err != nil { // 44
+	if err := write.Uint8(r.SrcMask, w); err != nil { // 44
 		return err
 	}
-	if err := read.Uint8(&r.DstMask, h); err != nil { // 45
+	if err := write.Uint8(r.DstMask, w); err != nil { // 45
 		return err
 	}
-	if err := read.Uint16(&r.Flags, h); err != nil { // 46-47
+	if err := write.Uint16(r.Flags, w); err != nil { // 46-47
 		return err
 	}
-	r.RouterSC = make(net.IP, 4)
-	if _, err := h.Read(r.RouterSC); err != nil { // 48-51
+	if err := write.IPv4(r.RouterSC, w); err != nil { // 48-51
 		return err
 	}
 
 	return nil
 }
 
+// AbsoluteTimes converts the First and Last SysUptime values of the record to
+// wall clock times, using the export time and SysUptime of the packet header
+// to determine when the device booted.
+//
+// The SysUptime counter is 32 bits wide and rolls over after about 49.7 days;
+// a First or Last value exceeding the SysUptime of the header is assumed to
+// predate the roll over. The end time is clamped so it is never before the
+// start time.
+func (r *FlowRecord) AbsoluteTimes(h *PacketHeader) (start, end time.Time) {
+	boot := h.Unix.Add(-h.SysUptime)
+	start = boot.Add(uptimeOffset(r.First, h.SysUptime))
+	end = boot.Add(uptimeOffset(r.Last, h.SysUptime))
+	if end.Before(start) {
+		end = start
+	}
+	return
+}
+
+// uptimeOffset returns the offset of a SysUptime value relative to boot time.
+func uptimeOffset(v uint32, uptime time.Duration) time.Duration {
+	d := time.Duration(v) * time.Millisecond
+	if d > uptime {
+		d -= time.Duration(1<<32) * time.Millisecond
+	}
+	return d
+}
+
+// IsValid reports whether the Flags do not mark the flow as invalid.
+func (r *FlowRecord) IsValid() bool {
+	return r.Flags&FlagInvalid == 0
+}
+
+// HasValidSrc reports whether the source address and mask are valid.
+func (r *FlowRecord) HasValidSrc() bool {
+	return r.Flags&FlagSrcInvalid == 0
+}
+
+// HasValidPorts reports whether the ports and protocol are valid.
+func (r *FlowRecord) HasValidPorts() bool {
+	return r.Flags&FlagPortsInvalid == 0
+}
+
+// IsShortcut reports whether the flow was switched bypassing the router in
+// RouterSC.
+func (r *FlowRecord) IsShortcut() bool {
+	return r.Flags&FlagShortcut != 0
+}
+
+// Duration returns the time between the First and Last SysUptime values of
+// the record. If Last is smaller than First, the SysUptime counter is assumed
+// to have rolled over once during the flow and the duration is computed
+// modulo 2^32 milliseconds.
+func (r *FlowRecord) Duration() time.Duration {
+	return time.Duration(r.Last-r.First) * time.Millisecond
+}
+
+// Bitrate returns the average throughput of the flow in bits per second. A
+// flow with a zero duration has a bitrate of 0.
+func (r *FlowRecord) Bitrate() float64 {
+	d := r.Duration()
+	if d <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) * 8 / d.Seconds()
+}
+
+// ICMPTypeCode returns the ICMP type and code of an ICMP flow, which
+// exporters encode in the destination port as type*256+code. If the flow is
+// not an ICMP flow, ok is false. Use read.ICMPName to render the values.
+func (r *FlowRecord) ICMPTypeCode() (icmpType, code uint8, ok bool) {
+	if r.Protocol != 1 {
+		return 0, 0, false
+	}
+	return uint8(r.DstPort >> 8), uint8(r.DstPort), true
+}
+
+// Scaled returns the packet and byte counts of the record multiplied by the
+// sampling rate. A rate of 0 means the flow was not sampled, and is treated
+// as a rate of 1.
+func (r *FlowRecord) Scaled(rate uint32) (packets, octets uint64) {
+	if rate == 0 {
+		rate = 1
+	}
+	return uint64(r.Packets) * uint64(rate), uint64(r.Bytes) * uint64(rate)
+}
+
 func (f FlowRecord) SampleInterval() int {
 	return 1
 }
@@ -0,0 +1,488 @@
+package netflow7
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"reflect"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+// A single flow record as captured from a Catalyst 5000 NFFC export.
+var testRecord = []byte{
+	0xc0, 0xa8, 0x01, 0x0a, // srcaddr 192.168.1.10
+	0x0a, 0x00, 0x00, 0x05, // dstaddr 10.0.0.5
+	0xc0, 0xa8, 0x01, 0x01, // nexthop 192.168.1.1
+	0x00, 0x02, // input
+	0x00, 0x05, // output
+	0x00, 0x00, 0x00, 0x0a, // dPkts
+	0x00, 0x00, 0x05, 0xdc, // dOctets
+	0x00, 0x01, 0x86, 0xa0, // first
+	0x00, 0x01, 0x8a, 0x88, // last
+	0x01, 0xbb, // srcport
+	0xc7, 0x38, // dstport
+	0x00,       // pad1
+	0x12,       // tcp_flags
+	0x06,       // prot
+	0x00,       // tos
+	0xfd, 0xe8, // src_as
+	0x00, 0x0f, // dst_as
+	0x18,       // src_mask
+	0x10,       // dst_mask
+	0x00, 0x00, // flags
+	0xc0, 0xa8, 0x01, 0xfe, // router_sc
+}
+
+// testHeader returns a packet header announcing count flow records.
+func testHeader(count uint16) []byte {
+	h := make([]byte, 24)
+	binary.BigEndian.PutUint16(h[0:], Version)
+	binary.BigEndian.PutUint16(h[2:], count)
+	binary.BigEndian.PutUint32(h[4:], 100000)     // SysUptime
+	binary.BigEndian.PutUint32(h[8:], 1577836800) // UnixSecs
+	binary.BigEndian.PutUint32(h[16:], 1)         // FlowSequence
+	return h
+}
+
+func TestPacketUnmarshal(t *testing.T) {
+	data := append(testHeader(2), testRecord...)
+	data = append(data, testRecord...)
+
+	p := new(Packet)
+	if err := p.Unmarshal(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(p.Records))
+	}
+}
+
+func TestPacketHeaderExportTime(t *testing.T) {
+	b := testHeader(1)
+	binary.BigEndian.PutUint32(b[12:], 500000000) // UnixNSecs
+
+	var h PacketHeader
+	if err := h.Unmarshal(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Unix(1577836800, 500000000)
+	if et := h.ExportTime(); !et.Equal(want) || et.Nanosecond() != 500000000 {
+		t.Fatalf("expected %s, got %s", want, et)
+	}
+}
+
+func TestPacketHeaderMarshal(t *testing.T) {
+	h := PacketHeader{
+		Version:      Version,
+		Count:        3,
+		SysUptime:    100 * time.Second,
+		Unix:         time.Unix(1577836800, 123456789),
+		FlowSequence: 42,
+		Reserved:     7,
+	}
+
+	b := new(bytes.Buffer)
+	if err := h.Marshal(b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != HeaderLen {
+		t.Fatalf("expected %d bytes, got %d", HeaderLen, b.Len())
+	}
+
+	var got PacketHeader
+	if err := got.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, h) {
+		t.Fatalf("expected %+v, got %+v", h, got)
+	}
+}
+
+func TestPacketUnmarshalShort(t *testing.T) {
+	// Header claims more records than the packet holds
+	data := append(testHeader(30), testRecord...)
+
+	p := new(Packet)
+	err := p.Unmarshal(bytes.NewReader(data))
+	if !errors.Is(err, read.ErrShortPacket) {
+		t.Fatalf("expected ErrShortPacket, got %v", err)
+	}
+
+	// The second record is cut off in the middle of the Packets field.
+	data = append(testHeader(2), testRecord...)
+	data = append(data, testRecord[:18]...)
+	err = new(Packet).Unmarshal(bytes.NewReader(data))
+	var short *read.ShortReadError
+	if !errors.As(err, &short) {
+		t.Fatalf("expected ShortReadError, got %v", err)
+	}
+	if short.Expected != 2*RecordLen || short.Available != RecordLen+18 || short.Missing() != RecordLen-18 {
+		t.Errorf("expected %d of %d bytes, got %d of %d", RecordLen+18, 2*RecordLen, short.Available, short.Expected)
+	}
+	if want := HeaderLen + RecordLen + 18; short.Offset != want {
+		t.Errorf("expected offset %d, got %d", want, short.Offset)
+	}
+	if want := "record 1 field Packets"; short.Field != want {
+		t.Errorf("expected field %q, got %q", want, short.Field)
+	}
+}
+
+func TestFlowRecordUnmarshalTimeout(t *testing.T) {
+	r := FlowRecord{SrcPort: 80}
+	rd := io.MultiReader(bytes.NewReader(testRecord[:20]), iotest.ErrReader(os.ErrDeadlineExceeded))
+	if err := r.Unmarshal(rd); !errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, read.ErrShortPacket) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if !r.Equal(&FlowRecord{SrcPort: 80}) {
+		t.Errorf("expected record to be left unchanged, got %+v", r)
+	}
+}
+
+func TestFlowRecordMarshal(t *testing.T) {
+	if len(testRecord) != 52 {
+		t.Fatalf("test record is %d bytes, expected 52", len(testRecord))
+	}
+
+	r := new(FlowRecord)
+	if err := r.Unmarshal(bytes.NewReader(testRecord)); err != nil {
+		t.Fatal(err)
+	}
+
+	b := new(bytes.Buffer)
+	if err := r.Marshal(b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), testRecord) {
+		t.Fatalf("expected marshaled record\n%x, got\n%x", testRecord, b.Bytes())
+	}
+}
+
+func TestFlowRecordMarshalPad(t *testing.T) {
+	r := &FlowRecord{DstPort: 0xffff, TCPFlags: 0xff, Protocol: 6}
+
+	b := new(bytes.Buffer)
+	if err := r.Marshal(b); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range [][]byte{b.Bytes(), r.AppendBytes(nil)} {
+		if len(p) != RecordLen {
+			t.Fatalf("expected a %d byte record, got %d bytes", RecordLen, len(p))
+		}
+		// The pad byte sits between DstPort and TCPFlags.
+		if !bytes.Equal(p[34:39], []byte{0xff, 0xff, 0x00, 0xff, 0x06}) {
+			t.Errorf("expected dstport, pad, tcp_flags and prot at 34-38, got %x", p[34:39])
+		}
+	}
+}
+
+func TestFlowRecordUnmarshalBytes(t *testing.T) {
+	want := new(FlowRecord)
+	if err := want.Unmarshal(bytes.NewReader(testRecord)); err != nil {
+		t.Fatal(err)
+	}
+
+	b := append(append([]byte{}, testRecord...), 0xff)
+	r := new(FlowRecord)
+	n, err := r.UnmarshalBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != RecordLen {
+		t.Fatalf("expected %d bytes to be consumed, got %d", RecordLen, n)
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Fatalf("expected %+v, got %+v", want, r)
+	}
+
+	// Addresses must not alias the input buffer
+	b[0] = 0x00
+	if r.SrcAddr.String() != "192.168.1.10" {
+		t.Fatalf("expected source 192.168.1.10, got %s", r.SrcAddr)
+	}
+
+	if _, err = r.UnmarshalBytes(testRecord[:RecordLen-1]); !errors.Is(err, read.ErrShortPacket) {
+		t.Fatalf("expected short packet error, got %v", err)
+	}
+}
+
+func TestFlowRecordAppendBytes(t *testing.T) {
+	r := new(FlowRecord)
+	if err := r.Unmarshal(bytes.NewReader(testRecord)); err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := []byte{0xaa, 0xbb}
+	b := r.AppendBytes(prefix)
+	if !bytes.Equal(b[:2], prefix) || !bytes.Equal(b[2:], testRecord) {
+		t.Fatalf("expected appended record\n%x, got\n%x", testRecord, b[2:])
+	}
+
+	// An unset address is encoded as 0.0.0.0
+	b = (&FlowRecord{}).AppendBytes(nil)
+	if !bytes.Equal(b, make([]byte, RecordLen)) {
+		t.Fatalf("expected empty record, got %x", b)
+	}
+
+	if n := testing.AllocsPerRun(100, func() {
+		b = r.AppendBytes(b[:0])
+	}); n != 0 {
+		t.Errorf("expected no allocations, got %.0f", n)
+	}
+}
+
+func TestFlowRecordEqual(t *testing.T) {
+	a := new(FlowRecord)
+	if err := a.Unmarshal(bytes.NewReader(testRecord)); err != nil {
+		t.Fatal(err)
+	}
+
+	b := *a
+	b.Pad1 = 0xff
+	b.SrcAddr = net.IPv4(192, 168, 1, 10) // 16 byte form
+	if !a.Equal(&b) {
+		t.Fatal("expected records differing in padding to be equal")
+	}
+
+	b.SrcPort++
+	if a.Equal(&b) {
+		t.Fatal("expected records differing in source port to differ")
+	}
+}
+
+func TestFlowRecordPrefix(t *testing.T) {
+	r := &FlowRecord{
+		SrcAddr: net.IP{10, 1, 2, 3},
+		SrcMask: 24,
+		DstAddr: net.IP{192, 0, 2, 1},
+		DstMask: 0,
+	}
+	if n := r.SrcPrefix(); n.String() != "10.1.2.0/24" {
+		t.Errorf("expected source prefix 10.1.2.0/24, got %s", n.String())
+	}
+	if n := r.DstPrefix(); n.String() != "0.0.0.0/0" {
+		t.Errorf("expected destination prefix 0.0.0.0/0, got %s", n.String())
+	}
+}
+
+func TestFlowRecordNetip(t *testing.T) {
+	r := new(FlowRecord)
+	if _, err := r.UnmarshalBytes(testRecord); err != nil {
+		t.Fatal(err)
+	}
+	if a := r.SrcAddrNetip(); a != netip.MustParseAddr("192.168.1.10") {
+		t.Errorf("expected source 192.168.1.10, got %s", a)
+	}
+	if a := r.DstAddrNetip(); a != netip.MustParseAddr("10.0.0.5") {
+		t.Errorf("expected destination 10.0.0.5, got %s", a)
+	}
+
+	// Addresses set by hand are usually in 16 byte form.
+	r.SrcAddr = net.IPv4(192, 168, 1, 10)
+	if a := r.SrcAddrNetip(); a != netip.MustParseAddr("192.168.1.10") {
+		t.Errorf("expected source 192.168.1.10, got %s", a)
+	}
+}
+
+func TestFlowRecordReset(t *testing.T) {
+	r := new(FlowRecord)
+	if err := r.Unmarshal(bytes.NewReader(testRecord)); err != nil {
+		t.Fatal(err)
+	}
+	r.Reset()
+	if !reflect.DeepEqual(*r, FlowRecord{}) {
+		t.Fatalf("expected zero record, got %+v", *r)
+	}
+
+	h := new(PacketHeader)
+	if err := h.Unmarshal(bytes.NewReader(testHeader(1))); err != nil {
+		t.Fatal(err)
+	}
+	h.Reset()
+	if !reflect.DeepEqual(*h, PacketHeader{}) {
+		t.Fatalf("expected zero header, got %+v", *h)
+	}
+}
+
+func TestFlowRecordAbsoluteTimes(t *testing.T) {
+	h := &PacketHeader{
+		SysUptime: 100 * time.Second,
+		Unix:      time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	var tests = []struct {
+		First, Last uint32
+		Start, End  string
+	}{
+		{40000, 90000, "2019-12-31T23:59:00Z", "2019-12-31T23:59:50Z"},
+		{100000, 100000, "2020-01-01T00:00:00Z", "2020-01-01T00:00:00Z"},
+		// First was recorded before the SysUptime counter rolled over
+		{0xffffffff - 999, 5000, "2019-12-31T23:58:19Z", "2019-12-31T23:58:25Z"},
+	}
+	for _, test := range tests {
+		r := &FlowRecord{First: test.First, Last: test.Last}
+		start, end := r.AbsoluteTimes(h)
+		if s := start.UTC().Format(time.RFC3339); s != test.Start {
+			t.Errorf("first=%d: expected start %s, got %s", test.First, test.Start, s)
+		}
+		if e := end.UTC().Format(time.RFC3339); e != test.End {
+			t.Errorf("last=%d: expected end %s, got %s", test.Last, test.End, e)
+		}
+	}
+}
+
+func TestFlowRecordScaled(t *testing.T) {
+	r := &FlowRecord{Packets: 10, Bytes: 1500}
+	if p, o := r.Scaled(1000); p != 10000 || o != 1500000 {
+		t.Errorf("expected 10000 packets and 1500000 bytes, got %d and %d", p, o)
+	}
+	if p, o := r.Scaled(0); p != 10 || o != 1500 {
+		t.Errorf("expected unsampled counts, got %d and %d", p, o)
+	}
+
+	// Large flows must not overflow 32 bits
+	r = &FlowRecord{Packets: 0xffffffff, Bytes: 0xffffffff}
+	if p, o := r.Scaled(1000); p != 0xffffffff*1000 || o != 0xffffffff*1000 {
+		t.Errorf("expected %d packets and bytes, got %d and %d", uint64(0xffffffff*1000), p, o)
+	}
+}
+
+func TestFlowRecordDuration(t *testing.T) {
+	var tests = []struct {
+		First, Last uint32
+		Bytes       uint32
+		Duration    time.Duration
+		Bitrate     float64
+	}{
+		{1000, 3000, 1000, 2 * time.Second, 4000},
+		{5000, 5000, 1000, 0, 0},
+		// SysUptime rolled over during the flow
+		{0xfffffe0c, 0x000001f4, 1000, time.Second, 8000},
+	}
+	for _, test := range tests {
+		r := &FlowRecord{First: test.First, Last: test.Last, Bytes: test.Bytes}
+		if d := r.Duration(); d != test.Duration {
+			t.Errorf("first=%d, last=%d: expected duration %s, got %s", test.First, test.Last, test.Duration, d)
+		}
+		if b := r.Bitrate(); b != test.Bitrate {
+			t.Errorf("first=%d, last=%d: expected bitrate %g, got %g", test.First, test.Last, test.Bitrate, b)
+		}
+	}
+}
+
+func TestFlowRecordICMPTypeCode(t *testing.T) {
+	r := &FlowRecord{Protocol: 1, DstPort: 2048}
+	typ, code, ok := r.ICMPTypeCode()
+	if !ok || typ != 8 || code != 0 {
+		t.Fatalf("expected type 8 code 0, got %d %d (%t)", typ, code, ok)
+	}
+	if name := read.ICMPName(typ, code); name != "echo-request" {
+		t.Errorf("expected echo-request, got %q", name)
+	}
+
+	r = &FlowRecord{Protocol: 6, DstPort: 2048}
+	if _, _, ok = r.ICMPTypeCode(); ok {
+		t.Error("expected TCP flow not to have an ICMP type and code")
+	}
+}
+
+func TestFlowRecordFlags(t *testing.T) {
+	r := new(FlowRecord)
+	if !r.IsValid() || !r.HasValidSrc() || !r.HasValidPorts() || r.IsShortcut() {
+		t.Errorf("expected valid flow without flags, got flags %#04x", r.Flags)
+	}
+
+	b := make([]byte, RecordLen)
+	binary.BigEndian.PutUint16(b[46:], FlagInvalid|FlagPortsInvalid)
+	if _, err := r.UnmarshalBytes(b); err != nil {
+		t.Fatal(err)
+	}
+	if r.IsValid() {
+		t.Error("expected flow to be invalid")
+	}
+	if !r.HasValidSrc() || r.HasValidPorts() {
+		t.Errorf("expected only the ports to be invalid, got flags %#04x", r.Flags)
+	}
+
+	r.Flags = FlagShortcut
+	if !r.IsValid() || !r.IsShortcut() {
+		t.Errorf("expected valid shortcut flow, got flags %#04x", r.Flags)
+	}
+}
+
+func TestFlowRecordDetailString(t *testing.T) {
+	r := FlowRecord{
+		SrcAddr:  net.IPv4(192, 168, 1, 1),
+		DstAddr:  net.IPv4(10, 0, 0, 5),
+		Input:    2,
+		Output:   5,
+		Packets:  10,
+		Bytes:    1500,
+		SrcPort:  443,
+		DstPort:  51000,
+		TCPFlags: 0x12,
+		Protocol: 6,
+	}
+	if s := r.DetailString(); s != "if2->if5 tcp 192.168.1.1:443 -> 10.0.0.5:51000 pkts=10 bytes=1500 [SYN,ACK]" {
+		t.Errorf("unexpected detail %q", s)
+	}
+	if s := r.String(); s != "192.168.1.1:443 -> 10.0.0.5:51000" {
+		t.Errorf("unexpected string %q", s)
+	}
+}
+
+func BenchmarkFlowRecordMarshal(b *testing.B) {
+	r := new(FlowRecord)
+	if err := r.Unmarshal(bytes.NewReader(testRecord)); err != nil {
+		b.Fatal(err)
+	}
+	w := new(bytes.Buffer)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Reset()
+		if err := r.Marshal(w); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFlowRecordAppendBytes(b *testing.B) {
+	r := new(FlowRecord)
+	if err := r.Unmarshal(bytes.NewReader(testRecord)); err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, 0, RecordLen)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = r.AppendBytes(buf[:0])
+	}
+}
+
+func BenchmarkFlowRecordUnmarshal(b *testing.B) {
+	r := new(FlowRecord)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := r.Unmarshal(bytes.NewReader(testRecord)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFlowRecordUnmarshalBytes(b *testing.B) {
+	r := new(FlowRecord)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.UnmarshalBytes(testRecord); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package netflow8
+
+import "io"
+
+func Read(r io.Reader) (*Packet, error) {
+	p := new(Packet)
+	return p, p.Unmarshal(r)
+}
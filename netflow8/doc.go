@@ -0,0 +1,11 @@
+/*
+Package netflow8 contains decoders for the NetFlow version 8 protocol.
+
+About
+
+The Version 8 (V8) format adds router based aggregation, the router summarizes
+flows into aggregation caches and exports the aggregated records. The layout
+of the records depends on the aggregation scheme announced in the header,
+currently the AS and Protocol Port aggregation schemes are supported.
+*/
+package netflow8
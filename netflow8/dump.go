@@ -0,0 +1,36 @@
+package netflow8
+
+import (
+	"fmt"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+func Dump(p *Packet) {
+	fmt.Println("NetFlow version 8 packet", p.Header)
+	fmt.Printf("  %d flow records:\n", len(p.Records))
+	for i, r := range p.Records {
+		fmt.Printf("    record %d:\n", i)
+		switch r := r.(type) {
+		case *ASRecord:
+			fmt.Println("      flows:   ", r.Flows)
+			fmt.Println("      bytes:   ", r.Bytes)
+			fmt.Println("      packets: ", r.Packets)
+			fmt.Println("      first:   ", r.First)
+			fmt.Println("      last:    ", r.Last)
+			fmt.Println("      srcAs:   ", r.SrcAS)
+			fmt.Println("      dstAs:   ", r.DstAS)
+			fmt.Println("      input:   ", r.Input)
+			fmt.Println("      output:  ", r.Output)
+		case *ProtoPortRecord:
+			fmt.Println("      flows:   ", r.Flows)
+			fmt.Println("      bytes:   ", r.Bytes)
+			fmt.Println("      packets: ", r.Packets)
+			fmt.Println("      first:   ", r.First)
+			fmt.Println("      last:    ", r.Last)
+			fmt.Println("      protocol:", r.Protocol, read.Protocol(r.Protocol))
+			fmt.Println("      srcPort: ", r.SrcPort)
+			fmt.Println("      dstPort: ", r.DstPort)
+		}
+	}
+}
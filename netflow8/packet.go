@@ -0,0 +1,368 @@
+package netflow8
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+const (
+	// Version word in the Packet Header
+	Version uint16 = 0x0008
+	// HeaderLen is the size of the Packet Header in bytes
+	HeaderLen = 28
+)
+
+// Aggregation schemes
+const (
+	AggregationAS        uint8 = 1
+	AggregationProtoPort uint8 = 2
+)
+
+const (
+	// ASRecordLen is the size of an AS aggregation record in bytes
+	ASRecordLen = 28
+	// ASRecordMax is the maximum number of AS aggregation records in a packet
+	ASRecordMax = 51
+	// ProtoPortRecordLen is the size of a Protocol Port aggregation record in
+	// bytes
+	ProtoPortRecordLen = 28
+	// ProtoPortRecordMax is the maximum number of Protocol Port aggregation
+	// records in a packet
+	ProtoPortRecordMax = 51
+)
+
+// FlowRecord is an aggregated flow record, the record layout depends on the
+// aggregation scheme.
+type FlowRecord interface {
+	// Len returns the length of the record on the wire in bytes.
+	Len() int
+	String() string
+	Unmarshal(io.Reader) error
+	UnmarshalBytes([]byte) (int, error)
+	AppendBytes([]byte) []byte
+}
+
+// Packet is a NetFlow v8 packet
+type Packet struct {
+	Header  PacketHeader
+	Records []FlowRecord
+}
+
+func (p *Packet) Unmarshal(r io.Reader) error {
+	if err := p.Header.Unmarshal(r); err != nil {
+		return err
+	}
+
+	var (
+		size, max int
+		record    func() FlowRecord
+	)
+	switch p.Header.Aggregation {
+	case AggregationAS:
+		size, max = ASRecordLen, ASRecordMax
+		record = func() FlowRecord { return new(ASRecord) }
+	case AggregationProtoPort:
+		size, max = ProtoPortRecordLen, ProtoPortRecordMax
+		record = func() FlowRecord { return new(ProtoPortRecord) }
+	default:
+		return fmt.Errorf("protocol error: unsupported aggregation scheme %d", p.Header.Aggregation)
+	}
+	if int(p.Header.Count) > max {
+		return fmt.Errorf("protocol error: %d flows out of bounds", p.Header.Count)
+	}
+
+	// Read all records at once, so we can validate the number of records
+	// announced in the header against the available data.
+	data := make([]byte, int(p.Header.Count)*size)
+	if err := read.Full(data, r); err != nil {
+		return fmt.Errorf("protocol error: %d flows announced: %w", p.Header.Count, err)
+	}
+	p.Records = make([]FlowRecord, p.Header.Count)
+	for i := range p.Records {
+		p.Records[i] = record()
+		n, err := p.Records[i].UnmarshalBytes(data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// PacketHeader is a NetFlow v8 packet header
+type PacketHeader struct {
+	Version      uint16
+	Count        uint16
+	SysUptime    time.Duration // 32 bit milliseconds
+	Unix         time.Time     // 32 bit seconds + 32 bit nanoseconds
+	FlowSequence uint32
+	EngineType   uint8
+	EngineID     uint8
+	// Aggregation is the aggregation scheme of the records
+	Aggregation uint8
+	// AggregationVersion is the version of the aggregation scheme
+	AggregationVersion uint8
+	Reserved           uint32
+}
+
+// Len returns the length of the Packet Header in bytes.
+func (h PacketHeader) Len() int {
+	return HeaderLen
+}
+
+// Reset sets all fields of the header to their zero value.
+func (h *PacketHeader) Reset() {
+	*h = PacketHeader{}
+}
+
+// ProtocolVersion returns the version announced in the header.
+func (h PacketHeader) ProtocolVersion() uint16 {
+	return h.Version
+}
+
+// Sequence returns the flow sequence number.
+func (h PacketHeader) Sequence() uint32 {
+	return h.FlowSequence
+}
+
+// ExportTime returns the time the packet was exported, combining the seconds
+// and nanoseconds since the epoch.
+func (h PacketHeader) ExportTime() time.Time {
+	return h.Unix
+}
+
+// Uptime returns the uptime of the exporter.
+func (h PacketHeader) Uptime() time.Duration {
+	return h.SysUptime
+}
+
+func (h PacketHeader) String() string {
+	return fmt.Sprintf("v=%d, count=%d, uptime=%s, time=%s, seq=%d, aggregation=%d",
+		h.Version, h.Count, h.SysUptime, h.Unix, h.FlowSequence, h.Aggregation)
+}
+
+func (h *PacketHeader) Unmarshal(r io.Reader) error {
+	if err := read.Uint16(&h.Version, r); err != nil {
+		return err
+	}
+	if err := read.Uint16(&h.Count, r); err != nil {
+		return err
+	}
+	if h.Count < 1 {
+		return fmt.Errorf("protocol error: %d flows out of bounds", h.Count)
+	}
+	var u uint32
+	if err := read.Uint32(&u, r); err != nil {
+		return err
+	}
+	h.SysUptime = time.Duration(u) * time.Millisecond
+	var t uint64
+	if err := read.Uint64(&t, r); err != nil {
+		return err
+	}
+	h.Unix = time.Unix(int64(t>>32), int64(t&0xffffffff))
+	if err := read.Uint32(&h.FlowSequence, r); err != nil {
+		return err
+	}
+	if err := read.Uint8(&h.EngineType, r); err != nil {
+		return err
+	}
+	if err := read.Uint8(&h.EngineID, r); err != nil {
+		return err
+	}
+	if err := read.Uint8(&h.Aggregation, r); err != nil {
+		return err
+	}
+	if err := read.Uint8(&h.AggregationVersion, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&h.Reserved, r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ASRecord is a NetFlow v8 AS aggregation record
+type ASRecord struct {
+	// Flows is the number of flows aggregated in the record
+	Flows uint32 // 0-3
+	// Packets is the number of packets in the aggregated flows
+	Packets uint32 // 4-7
+	// Octets is the number of bytes in the aggregated flows
+	Bytes uint32 // 8-11
+	// First is the SysUptime at start of the first flow
+	First uint32 // 12-15
+	// Last is the SysUptime at end of the last flow
+	Last uint32 // 16-19
+	// SrcAS is the source Autonomous System Number
+	SrcAS uint16 // 20-21
+	// DstAS is the destination Autonomous System Number
+	DstAS uint16 // 22-23
+	// Input is the SNMP index of input interface
+	Input uint16 // 24-25
+	// Output is the SNMP index of output interface
+	Output uint16 // 26-27
+}
+
+func (r ASRecord) Len() int {
+	return ASRecordLen
+}
+
+// Reset sets all fields of the record to their zero value.
+func (r *ASRecord) Reset() {
+	*r = ASRecord{}
+}
+
+func (r ASRecord) String() string {
+	return fmt.Sprintf("AS%d -> AS%d (%d flows)", r.SrcAS, r.DstAS, r.Flows)
+}
+
+// Equal compares the record fields.
+func (r *ASRecord) Equal(o *ASRecord) bool {
+	return *r == *o
+}
+
+func (r *ASRecord) Unmarshal(h io.Reader) error {
+	var b [ASRecordLen]byte
+	if err := read.Full(b[:], h); err != nil {
+		return err
+	}
+	_, err := r.UnmarshalBytes(b[:])
+	return err
+}
+
+// UnmarshalBytes decodes the record from b and returns the number of bytes
+// consumed.
+func (r *ASRecord) UnmarshalBytes(b []byte) (int, error) {
+	if err := read.Need(b, ASRecordLen); err != nil {
+		return 0, err
+	}
+	r.Flows = binary.BigEndian.Uint32(b[0:])
+	r.Packets = binary.BigEndian.Uint32(b[4:])
+	r.Bytes = binary.BigEndian.Uint32(b[8:])
+	r.First = binary.BigEndian.Uint32(b[12:])
+	r.Last = binary.BigEndian.Uint32(b[16:])
+	r.SrcAS = binary.BigEndian.Uint16(b[20:])
+	r.DstAS = binary.BigEndian.Uint16(b[22:])
+	r.Input = binary.BigEndian.Uint16(b[24:])
+	r.Output = binary.BigEndian.Uint16(b[26:])
+	return ASRecordLen, nil
+}
+
+// AppendBytes appends the wire format of the record to b and returns the
+// extended slice.
+func (r *ASRecord) AppendBytes(b []byte) []byte {
+	n := len(b)
+	b = append(b, make([]byte, ASRecordLen)...)
+	p := b[n:]
+	binary.BigEndian.PutUint32(p[0:], r.Flows)
+	binary.BigEndian.PutUint32(p[4:], r.Packets)
+	binary.BigEndian.PutUint32(p[8:], r.Bytes)
+	binary.BigEndian.PutUint32(p[12:], r.First)
+	binary.BigEndian.PutUint32(p[16:], r.Last)
+	binary.BigEndian.PutUint16(p[20:], r.SrcAS)
+	binary.BigEndian.PutUint16(p[22:], r.DstAS)
+	binary.BigEndian.PutUint16(p[24:], r.Input)
+	binary.BigEndian.PutUint16(p[26:], r.Output)
+	return b
+}
+
+// ProtoPortRecord is a NetFlow v8 Protocol Port aggregation record
+type ProtoPortRecord struct {
+	// Flows is the number of flows aggregated in the record
+	Flows uint32 // 0-3
+	// Packets is the number of packets in the aggregated flows
+	Packets uint32 // 4-7
+	// Octets is the number of bytes in the aggregated flows
+	Bytes uint32 // 8-11
+	// First is the SysUptime at start of the first flow
+	First uint32 // 12-15
+	// Last is the SysUptime at end of the last flow
+	Last uint32 // 16-19
+	// Protocol number (IP)
+	Protocol uint8 // 20
+	// Pad1 are unused bytes
+	Pad1 uint8 // 21
+	// Reserved are reserved (unused) bytes
+	Reserved uint16 // 22-23
+	// SrcPort is the TCP/UDP source port number or equivalent
+	SrcPort uint16 // 24-25
+	// DstPort is the TCP/UDP destination port number or equivalent
+	DstPort uint16 // 26-27
+}
+
+func (r ProtoPortRecord) Len() int {
+	return ProtoPortRecordLen
+}
+
+// Reset sets all fields of the record to their zero value.
+func (r *ProtoPortRecord) Reset() {
+	*r = ProtoPortRecord{}
+}
+
+func (r ProtoPortRecord) String() string {
+	return fmt.Sprintf("%s %d -> %d (%d flows)", read.ProtocolName(r.Protocol), r.SrcPort, r.DstPort, r.Flows)
+}
+
+// Equal compares the record fields, ignoring the padding and reserved fields.
+func (r *ProtoPortRecord) Equal(o *ProtoPortRecord) bool {
+	return r.Flows == o.Flows &&
+		r.Packets == o.Packets &&
+		r.Bytes == o.Bytes &&
+		r.First == o.First &&
+		r.Last == o.Last &&
+		r.Protocol == o.Protocol &&
+		r.SrcPort == o.SrcPort &&
+		r.DstPort == o.DstPort
+}
+
+func (r *ProtoPortRecord) Unmarshal(h io.Reader) error {
+	var b [ProtoPortRecordLen]byte
+	if err := read.Full(b[:], h); err != nil {
+		return err
+	}
+	_, err := r.UnmarshalBytes(b[:])
+	return err
+}
+
+// UnmarshalBytes decodes the record from b and returns the number of bytes
+// consumed.
+func (r *ProtoPortRecord) UnmarshalBytes(b []byte) (int, error) {
+	if err := read.Need(b, ProtoPortRecordLen); err != nil {
+		return 0, err
+	}
+	r.Flows = binary.BigEndian.Uint32(b[0:])
+	r.Packets = binary.BigEndian.Uint32(b[4:])
+	r.Bytes = binary.BigEndian.Uint32(b[8:])
+	r.First = binary.BigEndian.Uint32(b[12:])
+	r.Last = binary.BigEndian.Uint32(b[16:])
+	r.Protocol = b[20]
+	r.Pad1 = b[21]
+	r.Reserved = binary.BigEndian.Uint16(b[22:])
+	r.SrcPort = binary.BigEndian.Uint16(b[24:])
+	r.DstPort = binary.BigEndian.Uint16(b[26:])
+	return ProtoPortRecordLen, nil
+}
+
+// AppendBytes appends the wire format of the record to b and returns the
+// extended slice.
+func (r *ProtoPortRecord) AppendBytes(b []byte) []byte {
+	n := len(b)
+	b = append(b, make([]byte, ProtoPortRecordLen)...)
+	p := b[n:]
+	binary.BigEndian.PutUint32(p[0:], r.Flows)
+	binary.BigEndian.PutUint32(p[4:], r.Packets)
+	binary.BigEndian.PutUint32(p[8:], r.Bytes)
+	binary.BigEndian.PutUint32(p[12:], r.First)
+	binary.BigEndian.PutUint32(p[16:], r.Last)
+	p[20] = r.Protocol
+	p[21] = r.Pad1
+	binary.BigEndian.PutUint16(p[22:], r.Reserved)
+	binary.BigEndian.PutUint16(p[24:], r.SrcPort)
+	binary.BigEndian.PutUint16(p[26:], r.DstPort)
+	return b
+}
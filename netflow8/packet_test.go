@@ -0,0 +1,122 @@
+package netflow8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+// testPacket builds a NetFlow v8 packet using the aggregation scheme.
+func testPacket(aggregation uint8, count uint16, records ...byte) []byte {
+	b := make([]byte, HeaderLen)
+	binary.BigEndian.PutUint16(b[0:], Version)
+	binary.BigEndian.PutUint16(b[2:], count)
+	binary.BigEndian.PutUint32(b[4:], 100000)     // SysUptime
+	binary.BigEndian.PutUint32(b[8:], 1577836800) // UnixSecs
+	binary.BigEndian.PutUint32(b[16:], 42)        // FlowSequence
+	b[20] = 1                                     // EngineType
+	b[21] = 2                                     // EngineID
+	b[22] = aggregation
+	b[23] = 2 // AggregationVersion
+	return append(b, records...)
+}
+
+func TestPacketAS(t *testing.T) {
+	data := testPacket(AggregationAS, 2,
+		0x00, 0x00, 0x00, 0x03, // flows
+		0x00, 0x00, 0x00, 0x0a, // packets
+		0x00, 0x00, 0x05, 0xdc, // bytes
+		0x00, 0x01, 0x86, 0xa0, // first
+		0x00, 0x01, 0x8a, 0x88, // last
+		0xfd, 0xe8, 0x00, 0x0f, // src as 65000, dst as 15
+		0x00, 0x02, 0x00, 0x05, // input 2, output 5
+		0x00, 0x00, 0x00, 0x01, // flows
+		0x00, 0x00, 0x00, 0x01, // packets
+		0x00, 0x00, 0x00, 0x40, // bytes
+		0x00, 0x01, 0x86, 0xa0, // first
+		0x00, 0x01, 0x86, 0xa0, // last
+		0x00, 0x0f, 0xfd, 0xe8, // src as 15, dst as 65000
+		0x00, 0x05, 0x00, 0x02, // input 5, output 2
+	)
+
+	p, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := p.Header
+	if h.Count != 2 || h.SysUptime != 100*time.Second || h.Unix.Unix() != 1577836800 || h.FlowSequence != 42 ||
+		h.EngineType != 1 || h.EngineID != 2 || h.Aggregation != AggregationAS || h.AggregationVersion != 2 {
+		t.Fatalf("unexpected header %s", h)
+	}
+
+	if len(p.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(p.Records))
+	}
+	r, ok := p.Records[0].(*ASRecord)
+	if !ok {
+		t.Fatalf("expected *ASRecord, got %T", p.Records[0])
+	}
+	want := ASRecord{
+		Flows: 3, Packets: 10, Bytes: 1500, First: 100000, Last: 101000,
+		SrcAS: 65000, DstAS: 15, Input: 2, Output: 5,
+	}
+	if *r != want {
+		t.Fatalf("expected %+v, got %+v", want, *r)
+	}
+	if r, ok := p.Records[1].(*ASRecord); !ok || r.SrcAS != 15 || r.DstAS != 65000 {
+		t.Fatalf("unexpected second record %v", p.Records[1])
+	}
+}
+
+func TestPacketProtoPort(t *testing.T) {
+	data := testPacket(AggregationProtoPort, 1,
+		0x00, 0x00, 0x00, 0x07, // flows
+		0x00, 0x00, 0x00, 0x0a, // packets
+		0x00, 0x00, 0x05, 0xdc, // bytes
+		0x00, 0x01, 0x86, 0xa0, // first
+		0x00, 0x01, 0x8a, 0x88, // last
+		0x06, 0x00, 0x00, 0x00, // protocol 6, pad, reserved
+		0x01, 0xbb, 0xc7, 0x38, // src port 443, dst port 51000
+	)
+
+	p, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(p.Records))
+	}
+	r, ok := p.Records[0].(*ProtoPortRecord)
+	if !ok {
+		t.Fatalf("expected *ProtoPortRecord, got %T", p.Records[0])
+	}
+	want := ProtoPortRecord{
+		Flows: 7, Packets: 10, Bytes: 1500, First: 100000, Last: 101000,
+		Protocol: 6, SrcPort: 443, DstPort: 51000,
+	}
+	if *r != want {
+		t.Fatalf("expected %+v, got %+v", want, *r)
+	}
+	if s := r.String(); s != "tcp 443 -> 51000 (7 flows)" {
+		t.Errorf("unexpected string %q", s)
+	}
+}
+
+func TestPacketUnsupportedAggregation(t *testing.T) {
+	data := testPacket(9, 1, make([]byte, 40)...)
+	if _, err := Read(bytes.NewReader(data)); err == nil {
+		t.Fatal("expected error for unsupported aggregation scheme")
+	}
+}
+
+func TestPacketShort(t *testing.T) {
+	data := testPacket(AggregationAS, 2, make([]byte, ASRecordLen)...)
+	if _, err := Read(bytes.NewReader(data)); !errors.Is(err, read.ErrShortPacket) {
+		t.Fatalf("expected short packet error, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package netflow9
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// TemplateBuilder builds a Template Record field by field. Together with a
+// DataFlowSetBuilder it produces packets to test collectors with.
+type TemplateBuilder struct {
+	tr TemplateRecord
+}
+
+// NewTemplateBuilder starts a Template Record with the given template ID,
+// which should be 256 or higher.
+func NewTemplateBuilder(id uint16) *TemplateBuilder {
+	return &TemplateBuilder{tr: TemplateRecord{TemplateID: id}}
+}
+
+// AddField adds a field of the given type and length in bytes, use
+// VariableLength for variable length fields.
+func (b *TemplateBuilder) AddField(fieldType, length uint16) *TemplateBuilder {
+	b.tr.Fields = append(b.tr.Fields, FieldSpecifier{Type: fieldType, Length: length})
+	return b
+}
+
+// Build returns the Template Record.
+func (b *TemplateBuilder) Build() TemplateRecord {
+	tr := b.tr
+	tr.FieldCount = uint16(len(tr.Fields))
+	tr.Fields = append(FieldSpecifiers(nil), tr.Fields...)
+	return tr
+}
+
+// AppendTemplateFlowSet appends a Template FlowSet holding the Template
+// Records to b and returns the extended slice.
+func AppendTemplateFlowSet(b []byte, trs ...TemplateRecord) []byte {
+	n := len(b)
+	b = append(b, 0, 0, 0, 0) // FlowSet header
+	for _, tr := range trs {
+		b = appendUint16(b, tr.TemplateID)
+		b = appendUint16(b, uint16(len(tr.Fields)))
+		for _, f := range tr.Fields {
+			b = appendUint16(b, f.Type)
+			b = appendUint16(b, f.Length)
+		}
+	}
+	return finishFlowSet(b, n, 0)
+}
+
+// finishFlowSet pads the FlowSet starting at offset n to 4 bytes and fills in
+// its header.
+func finishFlowSet(b []byte, n int, id uint16) []byte {
+	for (len(b)-n)%4 != 0 {
+		b = append(b, 0)
+	}
+	binary.BigEndian.PutUint16(b[n:], id)
+	binary.BigEndian.PutUint16(b[n+2:], uint16(len(b)-n))
+	return b
+}
+
+// DataFlowSetBuilder encodes Data Records described by a Template Record into
+// a Data FlowSet.
+type DataFlowSetBuilder struct {
+	tr    TemplateRecord
+	data  []byte
+	count int
+}
+
+// NewDataFlowSetBuilder starts an empty Data FlowSet for the template.
+func NewDataFlowSetBuilder(tr TemplateRecord) *DataFlowSetBuilder {
+	return &DataFlowSetBuilder{tr: tr}
+}
+
+// AddRecord encodes a Data Record with one value per template field. Values
+// can be unsigned integers or ints, which must fit the field length, or
+// []byte, string or net.IP values of exactly the field length. Variable length
+// fields take []byte or string values.
+func (b *DataFlowSetBuilder) AddRecord(values ...interface{}) error {
+	if len(values) != len(b.tr.Fields) {
+		return fmt.Errorf("template %d: %d values for %d fields", b.tr.TemplateID, len(values), len(b.tr.Fields))
+	}
+
+	data := b.data
+	for i, v := range values {
+		var err error
+		if data, err = appendValue(data, b.tr.Fields[i], v); err != nil {
+			return fmt.Errorf("template %d: field %d (type %d): %w", b.tr.TemplateID, i, b.tr.Fields[i].Type, err)
+		}
+	}
+	b.data = data
+	b.count++
+	return nil
+}
+
+// Len returns the number of Data Records added.
+func (b *DataFlowSetBuilder) Len() int {
+	return b.count
+}
+
+// AppendBytes appends the Data FlowSet, including its header and padding, to
+// dst and returns the extended slice.
+func (b *DataFlowSetBuilder) AppendBytes(dst []byte) []byte {
+	n := len(dst)
+	dst = append(dst, 0, 0, 0, 0) // FlowSet header
+	dst = append(dst, b.data...)
+	return finishFlowSet(dst, n, b.tr.TemplateID)
+}
+
+func appendValue(b []byte, f FieldSpecifier, v interface{}) ([]byte, error) {
+	if f.IsVariableLength() {
+		var p []byte
+		switch v := v.(type) {
+		case []byte:
+			p = v
+		case string:
+			p = []byte(v)
+		default:
+			return nil, fmt.Errorf("%T value for variable length field", v)
+		}
+		switch {
+		case len(p) < 0xff:
+			b = append(b, uint8(len(p)))
+		case len(p) <= 0xffff:
+			b = append(b, 0xff)
+			b = appendUint16(b, uint16(len(p)))
+		default:
+			return nil, fmt.Errorf("%d bytes do not fit a variable length field", len(p))
+		}
+		return append(b, p...), nil
+	}
+
+	var u uint64
+	switch v := v.(type) {
+	case []byte:
+		return appendBytes(b, f, v)
+	case string:
+		return appendBytes(b, f, []byte(v))
+	case net.IP:
+		if f.Length == net.IPv4len {
+			if ip4 := v.To4(); ip4 != nil {
+				v = ip4
+			}
+		}
+		return appendBytes(b, f, v)
+	case uint8:
+		u = uint64(v)
+	case uint16:
+		u = uint64(v)
+	case uint32:
+		u = uint64(v)
+	case uint64:
+		u = v
+	case int:
+		if v < 0 {
+			return nil, fmt.Errorf("negative value %d", v)
+		}
+		u = uint64(v)
+	default:
+		return nil, fmt.Errorf("unsupported %T value", v)
+	}
+	if f.Length == 0 || f.Length > 8 {
+		return nil, fmt.Errorf("integer value for field of %d bytes", f.Length)
+	}
+	if f.Length < 8 && u>>(8*f.Length) != 0 {
+		return nil, fmt.Errorf("value %d does not fit in %d bytes", u, f.Length)
+	}
+	for i := int(f.Length) - 1; i >= 0; i-- {
+		b = append(b, uint8(u>>(8*uint(i))))
+	}
+	return b, nil
+}
+
+func appendBytes(b []byte, f FieldSpecifier, p []byte) ([]byte, error) {
+	if len(p) != int(f.Length) {
+		return nil, fmt.Errorf("%d bytes for field of %d bytes", len(p), f.Length)
+	}
+	return append(b, p...), nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, uint8(v>>8), uint8(v))
+}
@@ -0,0 +1,83 @@
+package netflow9
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+func TestBuilder(t *testing.T) {
+	tr := NewTemplateBuilder(256).
+		AddField(8, 4).               // sourceIPv4Address
+		AddField(7, 2).               // sourceTransportPort
+		AddField(4, 1).               // protocolIdentifier
+		AddField(1, 8).               // octetDeltaCount
+		AddField(82, VariableLength). // interfaceName
+		Build()
+	if tr.FieldCount != 5 {
+		t.Fatalf("expected 5 fields, got %d", tr.FieldCount)
+	}
+
+	dfs := NewDataFlowSetBuilder(tr)
+	if err := dfs.AddRecord(net.IPv4(192, 0, 2, 1), uint16(80), uint8(6), uint64(1500), "eth0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dfs.AddRecord(net.IP{192, 0, 2, 2}, 443, 17, 64, []byte("ge-0/0/1")); err != nil {
+		t.Fatal(err)
+	}
+
+	h := PacketHeader{Version: Version, Count: uint16(1 + dfs.Len()), SequenceNumber: 1}
+	b := h.AppendBytes(nil)
+	b = AppendTemplateFlowSet(b, tr)
+	b = dfs.AppendBytes(b)
+	if len(b)%4 != 0 {
+		t.Fatalf("expected packet aligned to 4 bytes, got %d", len(b))
+	}
+
+	p, err := Read(bytes.NewReader(b), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trs := p.Templates(); len(trs) != 1 || trs[0].String() != tr.String() {
+		t.Fatalf("expected template %s, got %v", tr, trs)
+	}
+	drs := p.DataRecords()
+	if len(drs) != 2 {
+		t.Fatalf("expected 2 data records, got %d", len(drs))
+	}
+	var want = [][]string{
+		{"192.0.2.1", "80", "6", "1500", "eth0"},
+		{"192.0.2.2", "443", "17", "64", "ge-0/0/1"},
+	}
+	for i, dr := range drs {
+		for j, f := range dr.Fields {
+			if f.Translated == nil {
+				t.Fatalf("record %d field %d: not translated", i, j)
+			}
+			if v := fmt.Sprint(f.Translated.Value); v != want[i][j] {
+				t.Errorf("record %d field %d: expected %s, got %s", i, j, want[i][j], v)
+			}
+		}
+	}
+}
+
+func TestBuilderValidate(t *testing.T) {
+	dfs := NewDataFlowSetBuilder(NewTemplateBuilder(256).AddField(8, 4).AddField(7, 2).Build())
+	for _, values := range [][]interface{}{
+		{net.IPv4(192, 0, 2, 1)},
+		{net.IPv4(192, 0, 2, 1), uint32(65536)},
+		{[]byte{192, 0, 2}, uint16(80)},
+		{net.IPv4(192, 0, 2, 1), -1},
+		{net.IPv4(192, 0, 2, 1), 1.5},
+	} {
+		if err := dfs.AddRecord(values...); err == nil {
+			t.Errorf("%v: expected error", values)
+		}
+	}
+	if dfs.Len() != 0 {
+		t.Fatalf("expected no records to be added, got %d", dfs.Len())
+	}
+}
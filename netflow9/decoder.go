@@ -2,9 +2,12 @@ package netflow9
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/tehmaze/netflow/read"
 	"github.com/tehmaze/netflow/session"
 )
 
@@ -17,7 +20,54 @@ func errProtocol(f string, v ...interface{}) error {
 }
 
 func errTemplateNotFound(t uint16) error {
-	return fmt.Errorf("template with id=%d not found", t)
+	return fmt.Errorf("%w: template with id=%d not found", read.ErrUnknownTemplate, t)
+}
+
+func errInvalidLength(f string, v ...interface{}) error {
+	return fmt.Errorf("protocol error: %w: "+f, append([]interface{}{read.ErrInvalidLength}, v...)...)
+}
+
+// FlowSetError is an error decoding a single FlowSet, see
+// Packet.UnmarshalFlowSetsBestEffort.
+type FlowSetError struct {
+	// ID is the FlowSet ID.
+	ID uint16
+	// Offset is the offset of the FlowSet in the packet.
+	Offset int
+	Err    error
+}
+
+func (e *FlowSetError) Error() string {
+	return fmt.Sprintf("flowset %d at offset %d: %v", e.ID, e.Offset, e.Err)
+}
+
+func (e *FlowSetError) Unwrap() error {
+	return e.Err
+}
+
+// FlowSetErrors are the errors of the FlowSets of a packet that could not be
+// decoded.
+type FlowSetErrors []*FlowSetError
+
+func (es FlowSetErrors) Error() string {
+	if len(es) == 1 {
+		return es[0].Error()
+	}
+	s := make([]string, len(es))
+	for i, e := range es {
+		s[i] = e.Error()
+	}
+	return fmt.Sprintf("%d flowsets failed: %s", len(es), strings.Join(s, "; "))
+}
+
+// Is reports whether any of the errors matches target.
+func (es FlowSetErrors) Is(target error) bool {
+	for _, e := range es {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
 }
 
 // Decoder can decode multiple IPFIX messages from a stream.
@@ -33,7 +83,7 @@ func NewDecoder(r io.Reader, s session.Session) *Decoder {
 
 // Decode decodes a single message from a buffer of bytes.
 func (d *Decoder) Decode(data []byte) (*Packet, error) {
-	return Read(bytes.NewBuffer(data), d.Session, d.Translate)
+	return ReadBytes(data, d.Session, d.Translate)
 }
 
 // Next decodes the next message from the stream. Note that if there is an
@@ -47,21 +97,85 @@ func (d *Decoder) Next() (*Packet, error) {
 func Read(r io.Reader, s session.Session, t *Translate) (*Packet, error) {
 	p := new(Packet)
 
+	if err := p.Header.Unmarshal(r); err != nil {
+		return nil, err
+	}
+	if p.Header.Version != Version {
+		return nil, errInvalidVersion(p.Header.Version)
+	}
+
+	s, t = scope(s, t, p.Header.SourceID)
+	if t == nil && s != nil {
+		t = NewTranslate(s)
+	}
+	if p.Header.Count == 0 {
+		return p, nil
+	}
+	return p, p.UnmarshalFlowSets(r, s, t)
+}
+
+// ReadBytes decodes a single NetFlow packet from a complete datagram. Unlike
+// Read, all FlowSets up to the end of the datagram are decoded, regardless of
+// the Count in the header, see Packet.UnmarshalFlowSetsBytes.
+func ReadBytes(b []byte, s session.Session, t *Translate) (*Packet, error) {
+	p := new(Packet)
+
+	r := bytes.NewReader(b)
+	if err := p.Header.Unmarshal(r); err != nil {
+		return nil, err
+	}
+	if p.Header.Version != Version {
+		return nil, errInvalidVersion(p.Header.Version)
+	}
+
+	s, t = scope(s, t, p.Header.SourceID)
 	if t == nil && s != nil {
 		t = NewTranslate(s)
 	}
+	return p, p.UnmarshalFlowSetsBytes(b[len(b)-r.Len():], s, t)
+}
 
+// ReadBytesBestEffort is like ReadBytes, but FlowSets that can not be decoded
+// are skipped, see Packet.UnmarshalFlowSetsBestEffort. If only FlowSets
+// failed, the packet is returned along with the FlowSetErrors.
+func ReadBytesBestEffort(b []byte, s session.Session, t *Translate) (*Packet, error) {
+	p := new(Packet)
+
+	r := bytes.NewReader(b)
 	if err := p.Header.Unmarshal(r); err != nil {
 		return nil, err
 	}
 	if p.Header.Version != Version {
 		return nil, errInvalidVersion(p.Header.Version)
 	}
-	if p.Header.Len() < 4 {
-		return nil, io.ErrShortBuffer
+
+	s, t = scope(s, t, p.Header.SourceID)
+	if t == nil && s != nil {
+		t = NewTranslate(s)
 	}
-	if p.Header.Count == 0 {
-		return p, nil
+	return p, p.UnmarshalFlowSetsBestEffort(b[len(b)-r.Len():], s, t)
+}
+
+// DecodeAll reads a single packet and returns its Data Records grouped by
+// template ID, along with the Template Records learned from the packet.
+func DecodeAll(r io.Reader, s session.Session, t *Translate) (map[uint16][]DataRecord, []TemplateRecord, error) {
+	p, err := Read(r, s, t)
+	if err != nil {
+		return nil, nil, err
 	}
-	return p, p.UnmarshalFlowSets(r, s, t)
+	return p.DataRecordsByTemplate(), p.Templates(), nil
+}
+
+// scope returns the session and translator for the templates of the Source
+// ID, if the session scopes templates per domain.
+func scope(s session.Session, t *Translate, sourceID uint32) (session.Session, *Translate) {
+	ds, ok := s.(session.DomainSession)
+	if !ok {
+		return s, t
+	}
+	s = ds.Domain(sourceID)
+	if t != nil {
+		t = &Translate{t.Translate.WithSession(s)}
+	}
+	return s, t
 }
@@ -0,0 +1,121 @@
+package netflow9
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FieldChangeKind is the kind of change of a field between two definitions of
+// a template.
+type FieldChangeKind uint8
+
+// Field change kinds
+const (
+	FieldAdded FieldChangeKind = iota + 1
+	FieldRemoved
+	FieldModified
+)
+
+func (k FieldChangeKind) String() string {
+	switch k {
+	case FieldAdded:
+		return "added"
+	case FieldRemoved:
+		return "removed"
+	case FieldModified:
+		return "modified"
+	default:
+		return fmt.Sprintf("FieldChangeKind(%d)", uint8(k))
+	}
+}
+
+// FieldChange is a change of a field between two definitions of a template.
+// For an added field Old is the zero FieldSpecifier and OldIndex is -1, for a
+// removed field the same goes for New and NewIndex.
+type FieldChange struct {
+	Kind     FieldChangeKind
+	Old      FieldSpecifier
+	New      FieldSpecifier
+	OldIndex int
+	NewIndex int
+}
+
+func (c FieldChange) String() string {
+	switch c.Kind {
+	case FieldAdded:
+		return fmt.Sprintf("added field %d/%d at %d", c.New.Type, c.New.Length, c.NewIndex)
+	case FieldRemoved:
+		return fmt.Sprintf("removed field %d/%d at %d", c.Old.Type, c.Old.Length, c.OldIndex)
+	default:
+		return fmt.Sprintf("modified field %d/%d at %d to %d/%d at %d",
+			c.Old.Type, c.Old.Length, c.OldIndex, c.New.Type, c.New.Length, c.NewIndex)
+	}
+}
+
+// TemplateDiff compares two definitions of a template. Fields are matched by
+// their type, a type used more than once is matched in order of appearance.
+// A matched field is modified if its length changed, or if it moved relative
+// to the other matched fields. Without changes, both definitions decode Data
+// Records the same way.
+func TemplateDiff(old, new TemplateRecord) []FieldChange {
+	oldKeys, newKeys := occurrences(old.Fields), occurrences(new.Fields)
+	oldIndex := make(map[occurrence]int, len(oldKeys))
+	for i, k := range oldKeys {
+		oldIndex[k] = i
+	}
+	newIndex := make(map[occurrence]int, len(newKeys))
+	for j, k := range newKeys {
+		newIndex[k] = j
+	}
+
+	var (
+		changes []FieldChange
+		matched [][2]int // old and new index of the fields in both, in old order
+	)
+	for i, k := range oldKeys {
+		if j, ok := newIndex[k]; ok {
+			matched = append(matched, [2]int{i, j})
+			continue
+		}
+		changes = append(changes, FieldChange{Kind: FieldRemoved, Old: old.Fields[i], OldIndex: i, NewIndex: -1})
+	}
+
+	// The matched fields kept their order if the new indices are ascending.
+	order := make([]int, len(matched))
+	for p, m := range matched {
+		order[p] = m[1]
+	}
+	sort.Ints(order)
+	for p, m := range matched {
+		i, j := m[0], m[1]
+		if old.Fields[i].Length != new.Fields[j].Length || order[p] != j {
+			changes = append(changes, FieldChange{Kind: FieldModified, Old: old.Fields[i], New: new.Fields[j], OldIndex: i, NewIndex: j})
+		}
+	}
+
+	for j, k := range newKeys {
+		if _, ok := oldIndex[k]; !ok {
+			changes = append(changes, FieldChange{Kind: FieldAdded, New: new.Fields[j], OldIndex: -1, NewIndex: j})
+		}
+	}
+	return changes
+}
+
+// occurrence identifies a field in a template by its type, and the number of
+// fields of the same type before it.
+type occurrence struct {
+	fieldType uint16
+	n         int
+}
+
+func occurrences(fss FieldSpecifiers) []occurrence {
+	var (
+		keys = make([]occurrence, len(fss))
+		seen = make(map[uint16]int, len(fss))
+	)
+	for i, fs := range fss {
+		keys[i] = occurrence{fs.Type, seen[fs.Type]}
+		seen[fs.Type]++
+	}
+	return keys
+}
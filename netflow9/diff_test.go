@@ -0,0 +1,45 @@
+package netflow9
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplateDiff(t *testing.T) {
+	old := TemplateRecord{TemplateID: 256, Fields: FieldSpecifiers{
+		{Type: 8, Length: 4},  // sourceIPv4Address
+		{Type: 12, Length: 4}, // destinationIPv4Address
+		{Type: 7, Length: 2},  // sourceTransportPort
+		{Type: 1, Length: 4},  // octetDeltaCount
+	}}
+	if changes := TemplateDiff(old, old); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %v", changes)
+	}
+
+	new := TemplateRecord{TemplateID: 256, Fields: FieldSpecifiers{
+		{Type: 8, Length: 4},
+		{Type: 12, Length: 4},
+		{Type: 11, Length: 2}, // destinationTransportPort
+		{Type: 1, Length: 8},
+	}}
+	want := []FieldChange{
+		{Kind: FieldRemoved, Old: FieldSpecifier{7, 2}, OldIndex: 2, NewIndex: -1},
+		{Kind: FieldModified, Old: FieldSpecifier{1, 4}, New: FieldSpecifier{1, 8}, OldIndex: 3, NewIndex: 3},
+		{Kind: FieldAdded, New: FieldSpecifier{11, 2}, OldIndex: -1, NewIndex: 2},
+	}
+	if changes := TemplateDiff(old, new); !reflect.DeepEqual(changes, want) {
+		t.Errorf("expected %v, got %v", want, changes)
+	}
+
+	// Swapping two fields changes how records are decoded.
+	new = TemplateRecord{TemplateID: 256, Fields: FieldSpecifiers{
+		old.Fields[1], old.Fields[0], old.Fields[2], old.Fields[3],
+	}}
+	changes := TemplateDiff(old, new)
+	if len(changes) != 2 || changes[0].Kind != FieldModified || changes[0].Old.Type != 8 || changes[0].NewIndex != 1 {
+		t.Errorf("expected the swapped fields to be modified, got %v", changes)
+	}
+	if s := changes[0].String(); s != "modified field 8/4 at 0 to 8/4 at 1" {
+		t.Errorf("unexpected change %q", s)
+	}
+}
@@ -9,6 +9,23 @@ func Dump(p *Packet) {
 	fmt.Println("NetFlow version 9 packet")
 	for _, ds := range p.DataFlowSets {
 		fmt.Printf("  data set template %d, length: %d\n", ds.Header.ID, ds.Header.Length)
+		if ds.OptionsRecords != nil {
+			fmt.Printf("    %d options records:\n", len(ds.OptionsRecords))
+			for i, odr := range ds.OptionsRecords {
+				fmt.Printf("      record %d:\n", i)
+				for _, f := range odr.ScopeFields {
+					fmt.Printf("        scope %s: %v\n", ScopeName(f.Type), f.Bytes)
+				}
+				for _, f := range odr.OptionFields {
+					if f.Translated != nil && f.Translated.Name != "" {
+						fmt.Printf("        %s: %v\n", f.Translated.Name, f.Translated.Value)
+					} else {
+						fmt.Printf("        %d: %v (raw)\n", f.Type, f.Bytes)
+					}
+				}
+			}
+			continue
+		}
 		if ds.Records == nil {
 			fmt.Printf("    %d raw bytes:\n", len(ds.Bytes))
 			fmt.Println(hex.Dump(ds.Bytes))
@@ -2,17 +2,26 @@ package netflow9
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tehmaze/netflow/read"
 	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
 )
 
 const (
 	// Version word in the Packet Header
 	Version uint16 = 0x0009
+	// HeaderLen is the size of the Packet Header in bytes
+	HeaderLen = 20
+	// VariableLength used in the Field Specifier, not part of RFC 3954 but
+	// used by exporters following the IPFIX encoding (RFC 7011 section 7)
+	VariableLength uint16 = 0xffff
 )
 
 // Packet consists of a Packet Header followed by one or more FlowSets. The
@@ -37,23 +46,36 @@ type Packet struct {
 
 // PacketHeader is a Packet Header (RFC 3954 section 5.1)
 type PacketHeader struct {
-	Version        uint16
-	Count          uint16
-	SysUpTime      uint32
-	UnixSecs       uint32
+	// Version is always 9.
+	Version uint16
+	// Count is the total number of records in the packet, so Template
+	// Records, Options Template Records and Data Records combined. It is not
+	// the number of FlowSets, nor the number of flows as in NetFlow v5.
+	Count uint16
+	// SysUpTime is the time in milliseconds since the device booted.
+	SysUpTime uint32
+	// UnixSecs is the export time in seconds since the UNIX epoch.
+	UnixSecs uint32
+	// SequenceNumber is incremented by one for every packet sent by the
+	// Exporter, not for every record.
 	SequenceNumber uint32
-	SourceID       uint32
+	// SourceID identifies the exporting process (observation domain) on the
+	// device. Template IDs are scoped by the exporter address and SourceID.
+	SourceID uint32
 }
 
+// UnmarshalFlowSets reads the FlowSets of a packet from a stream, until the
+// number of records announced in the header are read. See
+// UnmarshalFlowSetsBytes to read the FlowSets of a complete datagram.
 func (p *Packet) UnmarshalFlowSets(r io.Reader, s session.Session, t *Translate) error {
 	if debug {
 		debugLog.Printf("decoding %d flow sets, sequence number: %d\n", p.Header.Count, p.Header.SequenceNumber)
 	}
-	var records uint16 = 0
+	var records int
 
 	for i := uint16(0); i < p.Header.Count; i++ {
 		// We have all expected flows
-		if records >= p.Header.Count {
+		if records >= int(p.Header.Count) {
 			return nil
 		}
 		// Read the next set header
@@ -64,135 +86,350 @@ func (p *Packet) UnmarshalFlowSets(r io.Reader, s session.Session, t *Translate)
 			}
 			return err
 		}
+		n, err := p.unmarshalFlowSet(header, r, s, t)
+		if err != nil {
+			return err
+		}
+		records += n
+	}
 
-		switch header.ID {
-		case 0: // Template FlowSet
-			tfs := TemplateFlowSet{}
-			tfs.Header = header
+	return nil
+}
 
-			readSize := int(tfs.Header.Length) - tfs.Header.Len()
-			if readSize < 4 {
-				if debug {
-					debugLog.Printf("short read size of %d\n", readSize)
-				}
-				return io.ErrShortBuffer
-			}
-			data := make([]byte, readSize)
-			if _, err := r.Read(data); err != nil {
-				if debug {
-					debugLog.Printf("failed to read %d bytes: %v\n", readSize, err)
-				}
-				return err
+// UnmarshalFlowSetsBytes reads the FlowSets of a packet from the datagram
+// body following the header, using the FlowSet lengths, until the body is
+// exhausted. Exporters do not agree on the Count in the header, some count
+// FlowSets in stead of records, so it is not used to find the last FlowSet.
+// Less than a FlowSet header of remaining bytes are ignored as padding.
+func (p *Packet) UnmarshalFlowSetsBytes(b []byte, s session.Session, t *Translate) error {
+	r := bytes.NewReader(b)
+	for r.Len() >= 4 {
+		header := FlowSetHeader{}
+		if err := header.Unmarshal(r); err != nil {
+			return err
+		}
+		if _, err := p.unmarshalFlowSet(header, r, s, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalFlowSetsBestEffort is like UnmarshalFlowSetsBytes, but a FlowSet
+// that can not be decoded is skipped by its length, and the FlowSets following
+// it are decoded. The errors are returned as FlowSetErrors. Decoding stops at
+// a FlowSet with a length exceeding the datagram, as the next FlowSet can not
+// be found.
+func (p *Packet) UnmarshalFlowSetsBestEffort(b []byte, s session.Session, t *Translate) error {
+	var (
+		r    = bytes.NewReader(b)
+		errs FlowSetErrors
+	)
+	for r.Len() >= 4 {
+		offset := HeaderLen + len(b) - r.Len()
+		header := FlowSetHeader{}
+		if err := header.Unmarshal(r); err != nil {
+			return err
+		}
+
+		size := int(header.Length) - header.Len()
+		if size < 0 || size > r.Len() {
+			if _, err := p.unmarshalFlowSet(header, r, s, t); err != nil {
+				errs = append(errs, &FlowSetError{ID: header.ID, Offset: offset, Err: err})
 			}
+			break
+		}
+		body := b[len(b)-r.Len():][:size]
+		r.Seek(int64(size), io.SeekCurrent)
+		if _, err := p.unmarshalFlowSet(header, bytes.NewReader(body), s, t); err != nil {
+			errs = append(errs, &FlowSetError{ID: header.ID, Offset: offset, Err: err})
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// unmarshalFlowSet reads the FlowSet following the header, and returns the
+// number of records it contributes to the Count in the packet header.
+func (p *Packet) unmarshalFlowSet(header FlowSetHeader, r io.Reader, s session.Session, t *Translate) (int, error) {
+	switch header.ID {
+	case 0: // Template FlowSet
+		tfs := TemplateFlowSet{}
+		tfs.Header = header
 
-			if err := tfs.UnmarshalRecords(bytes.NewBuffer(data)); err != nil {
-				return err
+		readSize := int(tfs.Header.Length) - tfs.Header.Len()
+		if readSize < 4 {
+			if debug {
+				debugLog.Printf("short read size of %d\n", readSize)
 			}
+			return 0, errInvalidLength("template flowset length %d", tfs.Header.Length)
+		}
+		data := make([]byte, readSize)
+		if err := read.Full(data, r); err != nil {
 			if debug {
-				debugLog.Printf("unmarshaled %d records: %v\n", len(tfs.Records), tfs)
+				debugLog.Printf("failed to read %d bytes: %v\n", readSize, err)
 			}
+			return 0, err
+		}
 
-			for _, tr := range tfs.Records {
-				tr.register(s)
-			}
+		if err := tfs.UnmarshalRecords(bytes.NewBuffer(data)); err != nil {
+			return 0, err
+		}
+		if debug {
+			debugLog.Printf("unmarshaled %d records: %v\n", len(tfs.Records), tfs)
+		}
 
-			records += 1
-			p.TemplateFlowSets = append(p.TemplateFlowSets, tfs)
+		for _, tr := range tfs.Records {
+			tr.register(s)
+		}
 
-		case 1: // Options Template FlowSet
-			ofs := OptionsTemplateFlowSet{}
-			ofs.Header = header
+		// The count includes every template record, not the FlowSet.
+		p.TemplateFlowSets = append(p.TemplateFlowSets, tfs)
+		return len(tfs.Records), nil
 
-			readSize := int(ofs.Header.Length) - ofs.Header.Len()
-			if readSize < 4 {
-				return io.ErrShortBuffer
-			}
-			data := make([]byte, readSize)
-			if _, err := r.Read(data); err != nil {
-				return err
-			}
+	case 1: // Options Template FlowSet
+		ofs := OptionsTemplateFlowSet{}
+		ofs.Header = header
 
-			records += 1
-			p.OptionsTemplateFlowSets = append(p.OptionsTemplateFlowSets, ofs)
+		readSize := int(ofs.Header.Length) - ofs.Header.Len()
+		if readSize < 4 {
+			return 0, errInvalidLength("options template flowset length %d", ofs.Header.Length)
+		}
+		data := make([]byte, readSize)
+		if err := read.Full(data, r); err != nil {
+			return 0, err
+		}
 
-		default:
-			dfs := DataFlowSet{}
-			dfs.Header = header
+		if err := ofs.UnmarshalRecords(bytes.NewBuffer(data)); err != nil {
+			return 0, err
+		}
+		if debug {
+			debugLog.Printf("unmarshaled %d options records: %v\n", len(ofs.Records), ofs)
+		}
 
-			if dfs.Header.Length < 4 {
-				return io.ErrShortBuffer
-			}
-			data := make([]byte, int(dfs.Header.Length)-dfs.Header.Len())
-			if _, err := r.Read(data); err != nil {
-				return err
-			}
+		for _, otr := range ofs.Records {
+			otr.register(s)
+		}
+
+		p.OptionsTemplateFlowSets = append(p.OptionsTemplateFlowSets, ofs)
+		return len(ofs.Records), nil
+
+	default:
+		dfs := DataFlowSet{}
+		dfs.Header = header
+
+		if dfs.Header.Length < 4 {
+			return 0, errInvalidLength("data flowset %d length %d", dfs.Header.ID, dfs.Header.Length)
+		}
+		data := make([]byte, int(dfs.Header.Length)-dfs.Header.Len())
+		if err := read.Full(data, r); err != nil {
+			return 0, err
+		}
 
-			var (
-				tm session.Template
-				tr TemplateRecord
-				ok bool
-			)
-			// If we don't have a session, or no template to resolve the Data
-			// Set contained Data Records, we'll store the raw bytes in stead.
-			if s == nil {
-				if debug {
-					debugLog.Printf("no session, storing %d raw bytes in data set\n", len(data))
-				}
-				dfs.Bytes = data
-				continue
+		var (
+			tm session.Template
+			tr TemplateRecord
+			ok bool
+		)
+		// If we don't have a session, or no template to resolve the Data
+		// Set contained Data Records, we'll store the raw bytes in stead.
+		if s == nil {
+			if debug {
+				debugLog.Printf("no session, storing %d raw bytes in data set\n", len(data))
 			}
-			if tm, ok = s.GetTemplate(header.ID); !ok {
-				if debug {
-					debugLog.Printf("no template for id=%d, storing %d raw bytes in data set\n", header.ID, len(data))
-				}
-				dfs.Bytes = data
-				continue
+			dfs.Bytes = data
+			p.DataFlowSets = append(p.DataFlowSets, dfs)
+			return 0, nil
+		}
+		if tm, ok = s.GetTemplate(header.ID); !ok {
+			if debug {
+				debugLog.Printf("no template for id=%d, storing %d raw bytes in data set\n", header.ID, len(data))
 			}
-			if tr, ok = tm.(TemplateRecord); !ok {
-				if debug {
-					debugLog.Printf("no template record, got %T, storing %d raw bytes in data set\n", tm, len(data))
-				}
-				dfs.Bytes = data
-				continue
+			dfs.Bytes = data
+			p.DataFlowSets = append(p.DataFlowSets, dfs)
+			return 0, nil
+		}
+		if otr, ok := tm.(OptionsTemplateRecord); ok {
+			if err := dfs.UnmarshalOptions(bytes.NewBuffer(data), otr, t); err != nil {
+				return 0, err
 			}
-			if err := dfs.Unmarshal(bytes.NewBuffer(data), tr, t); err != nil {
-				return err
+			p.DataFlowSets = append(p.DataFlowSets, dfs)
+			return len(dfs.OptionsRecords), nil
+		}
+		if tr, ok = tm.(TemplateRecord); !ok {
+			if debug {
+				debugLog.Printf("no template record, got %T, storing %d raw bytes in data set\n", tm, len(data))
 			}
-			records += uint16(len(dfs.Records))
+			dfs.Bytes = data
 			p.DataFlowSets = append(p.DataFlowSets, dfs)
+			return 0, nil
 		}
+		if err := dfs.Unmarshal(bytes.NewBuffer(data), tr, t); err != nil {
+			return 0, err
+		}
+		p.DataFlowSets = append(p.DataFlowSets, dfs)
+		return len(dfs.Records) + len(dfs.Shaped), nil
 	}
+}
 
-	return nil
+// RecordCount returns the number of template, options template, data and
+// options data records in the packet, as announced by the Count in the
+// header. The records of unresolved Data FlowSets are not counted.
+func (p *Packet) RecordCount() int {
+	var n int
+	for _, tfs := range p.TemplateFlowSets {
+		n += len(tfs.Records)
+	}
+	for _, ofs := range p.OptionsTemplateFlowSets {
+		n += len(ofs.Records)
+	}
+	for _, dfs := range p.DataFlowSets {
+		n += len(dfs.Records) + len(dfs.OptionsRecords) + len(dfs.Shaped)
+	}
+	return n
 }
 
-func (h PacketHeader) Len() int {
-	return 20
+// Templates returns all Template Records learned from this packet.
+func (p *Packet) Templates() []TemplateRecord {
+	var trs []TemplateRecord
+	for _, tfs := range p.TemplateFlowSets {
+		trs = append(trs, tfs.Records...)
+	}
+	return trs
 }
 
-func (h *PacketHeader) Unmarshal(r io.Reader) error {
-	if err := read.Uint16(&h.Version, r); err != nil {
-		return err
+// OptionsDataRecords returns all Options Data Records decoded from this packet.
+func (p *Packet) OptionsDataRecords() []OptionsDataRecord {
+	var odrs []OptionsDataRecord
+	for _, dfs := range p.DataFlowSets {
+		odrs = append(odrs, dfs.OptionsRecords...)
 	}
-	if err := read.Uint16(&h.Count, r); err != nil {
-		return err
+	return odrs
+}
+
+// DataRecords returns all Data Records decoded from this packet. Data FlowSets
+// for which no template was known are not included.
+func (p *Packet) DataRecords() []DataRecord {
+	var drs []DataRecord
+	for _, dfs := range p.DataFlowSets {
+		drs = append(drs, dfs.Records...)
 	}
-	if err := read.Uint32(&h.SysUpTime, r); err != nil {
-		return err
+	return drs
+}
+
+// ShapedRecords returns all records decoded by a registered shape from this
+// packet, see RegisterShape.
+func (p *Packet) ShapedRecords() []interface{} {
+	var rs []interface{}
+	for _, dfs := range p.DataFlowSets {
+		rs = append(rs, dfs.Shaped...)
 	}
-	if err := read.Uint32(&h.UnixSecs, r); err != nil {
-		return err
+	return rs
+}
+
+// DataRecordsByTemplate returns the Data Records decoded from this packet,
+// grouped by their template ID.
+func (p *Packet) DataRecordsByTemplate() map[uint16][]DataRecord {
+	drs := make(map[uint16][]DataRecord)
+	for _, dfs := range p.DataFlowSets {
+		for _, dr := range dfs.Records {
+			drs[dr.TemplateID] = append(drs[dr.TemplateID], dr)
+		}
 	}
-	if err := read.Uint32(&h.SequenceNumber, r); err != nil {
-		return err
+	return drs
+}
+
+// SkipUnresolved removes the Data FlowSets for which no template was known
+// from the packet, and returns the number of removed FlowSets.
+func (p *Packet) SkipUnresolved() int {
+	var (
+		dfss    = p.DataFlowSets[:0]
+		skipped int
+	)
+	for _, dfs := range p.DataFlowSets {
+		if dfs.Bytes != nil {
+			skipped++
+			continue
+		}
+		dfss = append(dfss, dfs)
 	}
-	if err := read.Uint32(&h.SourceID, r); err != nil {
+	p.DataFlowSets = dfss
+	return skipped
+}
+
+func (h PacketHeader) Len() int {
+	return HeaderLen
+}
+
+// Reset sets all fields of the header to their zero value.
+func (h *PacketHeader) Reset() {
+	*h = PacketHeader{}
+}
+
+// ProtocolVersion returns the version announced in the header.
+func (h PacketHeader) ProtocolVersion() uint16 {
+	return h.Version
+}
+
+// Sequence returns the packet sequence number.
+func (h PacketHeader) Sequence() uint32 {
+	return h.SequenceNumber
+}
+
+// Domain returns the SourceID, which scopes the templates of an exporter.
+func (h PacketHeader) Domain() uint32 {
+	return h.SourceID
+}
+
+// ExportTime returns the time the packet was exported, v9 only has second
+// precision.
+func (h PacketHeader) ExportTime() time.Time {
+	return time.Unix(int64(h.UnixSecs), 0)
+}
+
+// Uptime returns the uptime of the exporter.
+func (h PacketHeader) Uptime() time.Duration {
+	return time.Duration(h.SysUpTime) * time.Millisecond
+}
+
+func (h PacketHeader) String() string {
+	return fmt.Sprintf("version=%d, count=%d, uptime=%d, time=%d, seq=%d, source id=%d",
+		h.Version, h.Count, h.SysUpTime, h.UnixSecs, h.SequenceNumber, h.SourceID)
+}
+
+// Unmarshal reads the header from r. The header is read as a whole, if the
+// read fails the header is left unchanged.
+func (h *PacketHeader) Unmarshal(r io.Reader) error {
+	var b [HeaderLen]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
 		return err
 	}
-
+	h.Version = binary.BigEndian.Uint16(b[0:])
+	h.Count = binary.BigEndian.Uint16(b[2:])
+	h.SysUpTime = binary.BigEndian.Uint32(b[4:])
+	h.UnixSecs = binary.BigEndian.Uint32(b[8:])
+	h.SequenceNumber = binary.BigEndian.Uint32(b[12:])
+	h.SourceID = binary.BigEndian.Uint32(b[16:])
 	return nil
 }
 
+// AppendBytes appends the wire format of the header to b and returns the
+// extended slice.
+func (h *PacketHeader) AppendBytes(b []byte) []byte {
+	n := len(b)
+	b = append(b, make([]byte, HeaderLen)...)
+	p := b[n:]
+	binary.BigEndian.PutUint16(p[0:], h.Version)
+	binary.BigEndian.PutUint16(p[2:], h.Count)
+	binary.BigEndian.PutUint32(p[4:], h.SysUpTime)
+	binary.BigEndian.PutUint32(p[8:], h.UnixSecs)
+	binary.BigEndian.PutUint32(p[12:], h.SequenceNumber)
+	binary.BigEndian.PutUint32(p[16:], h.SourceID)
+	return b
+}
+
 type FlowSetHeader struct {
 	ID     uint16
 	Length uint16
@@ -202,14 +439,15 @@ func (h *FlowSetHeader) Len() int {
 	return 4
 }
 
+// Unmarshal reads the header from r. If the read fails, the header is left
+// unchanged.
 func (h *FlowSetHeader) Unmarshal(r io.Reader) error {
-	if err := read.Uint16(&h.ID, r); err != nil {
-		return err
-	}
-	if err := read.Uint16(&h.Length, r); err != nil {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
 		return err
 	}
-
+	h.ID = binary.BigEndian.Uint16(b[0:])
+	h.Length = binary.BigEndian.Uint16(b[2:])
 	return nil
 }
 
@@ -303,6 +541,8 @@ func (tr TemplateRecord) String() string {
 	return fmt.Sprintf("id=%d fields=%d (%s)", tr.TemplateID, tr.FieldCount, tr.Fields)
 }
 
+// Size returns the size of a Data Record described by this template. The size
+// is meaningless if the template has variable length fields.
 func (tr TemplateRecord) Size() int {
 	var size int
 	for _, f := range tr.Fields {
@@ -327,11 +567,26 @@ func (tr *TemplateRecord) Unmarshal(r io.Reader) error {
 	return nil
 }
 
+// HasVariableLength checks if any of the fields is of variable length.
+func (tr TemplateRecord) HasVariableLength() bool {
+	for _, f := range tr.Fields {
+		if f.IsVariableLength() {
+			return true
+		}
+	}
+	return false
+}
+
 type FieldSpecifier struct {
 	Type   uint16
 	Length uint16
 }
 
+// IsVariableLength checks if the field is encoded using a variable length.
+func (f FieldSpecifier) IsVariableLength() bool {
+	return f.Length == VariableLength
+}
+
 func (fs *FieldSpecifier) String() string {
 	return fmt.Sprintf("type=%d length=%d", fs.Type, fs.Length)
 }
@@ -394,29 +649,227 @@ func (fs *FieldSpecifiers) Unmarshal(r io.Reader) error {
 //   |     Option M Field Length     |           Padding             |
 //   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
 type OptionsTemplateFlowSet struct {
-	Header FlowSetHeader
+	Header  FlowSetHeader
+	Records []OptionsTemplateRecord
+}
+
+func (ofs OptionsTemplateFlowSet) String() string {
+	return fmt.Sprintf("id=%d length=%d (%d records)", ofs.Header.ID, ofs.Header.Length, len(ofs.Records))
+}
+
+func (ofs *OptionsTemplateFlowSet) UnmarshalRecords(r io.Reader) error {
+	buffer := new(bytes.Buffer)
+	if _, err := buffer.ReadFrom(r); err != nil {
+		return err
+	}
+
+	// As long as there are enough bytes in the buffer for a record header, we
+	// parse the next OptionsTemplateRecord, otherwise it's padding.
+	ofs.Records = make([]OptionsTemplateRecord, 0)
+	for buffer.Len() >= 6 {
+		record := OptionsTemplateRecord{}
+		if err := record.Unmarshal(buffer); err != nil {
+			return err
+		}
+
+		ofs.Records = append(ofs.Records, record)
+	}
+
+	return nil
+}
+
+// OptionsTemplateRecord is an Options Template Record as per RFC 3954 section 6.1
+type OptionsTemplateRecord struct {
+	TemplateID uint16
+
+	// OptionScopeLength is the length in bytes of the Scope Field Specifiers,
+	// OptionLength the length in bytes of the Option Field Specifiers.
+	OptionScopeLength uint16
+	OptionLength      uint16
+
+	ScopeFields FieldSpecifiers
+	Fields      FieldSpecifiers
+}
+
+// Scope field types of an Options Template Record, as per RFC 3954 section
+// 6.1. Scope field types have their own namespace, separate from the field
+// types of the option fields.
+const (
+	ScopeSystem    uint16 = 1
+	ScopeInterface uint16 = 2
+	ScopeLineCard  uint16 = 3
+	ScopeCache     uint16 = 4
+	ScopeTemplate  uint16 = 5
+)
+
+// ScopeName returns the name of the scope field type, or its number if the
+// type is unknown.
+func ScopeName(scopeType uint16) string {
+	switch scopeType {
+	case ScopeSystem:
+		return "System"
+	case ScopeInterface:
+		return "Interface"
+	case ScopeLineCard:
+		return "Line Card"
+	case ScopeCache:
+		return "Cache"
+	case ScopeTemplate:
+		return "Template"
+	default:
+		return strconv.Itoa(int(scopeType))
+	}
+}
+
+func (otr OptionsTemplateRecord) register(s session.Session) {
+	if s == nil {
+		return
+	}
+	if debug {
+		debugLog.Println("register options template:", otr)
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.AddTemplate(otr)
+}
+
+func (otr OptionsTemplateRecord) ID() uint16 {
+	return otr.TemplateID
+}
+
+func (otr OptionsTemplateRecord) String() string {
+	return fmt.Sprintf("id=%d scope fields=%d (%s) fields=%d (%s)",
+		otr.TemplateID, len(otr.ScopeFields), otr.ScopeFields, len(otr.Fields), otr.Fields)
+}
+
+// Size returns the size of an Options Data Record described by this template.
+func (otr OptionsTemplateRecord) Size() int {
+	var size int
+	for _, f := range otr.ScopeFields {
+		size += int(f.Length)
+	}
+	for _, f := range otr.Fields {
+		size += int(f.Length)
+	}
+	return size
+}
+
+func (otr *OptionsTemplateRecord) Unmarshal(r io.Reader) error {
+	if err := read.Uint16(&otr.TemplateID, r); err != nil {
+		return err
+	}
+	if err := read.Uint16(&otr.OptionScopeLength, r); err != nil {
+		return err
+	}
+	if err := read.Uint16(&otr.OptionLength, r); err != nil {
+		return err
+	}
+
+	// The lengths are in bytes, each Field Specifier takes 4 bytes.
+	if otr.OptionScopeLength%4 != 0 || otr.OptionLength%4 != 0 {
+		return errProtocol("options template %d: scope length %d or option length %d not a multiple of 4",
+			otr.TemplateID, otr.OptionScopeLength, otr.OptionLength)
+	}
+
+	otr.ScopeFields = make(FieldSpecifiers, otr.OptionScopeLength/4)
+	if err := otr.ScopeFields.Unmarshal(r); err != nil {
+		return err
+	}
+
+	otr.Fields = make(FieldSpecifiers, otr.OptionLength/4)
+	if err := otr.Fields.Unmarshal(r); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 type DataFlowSet struct {
-	Header  FlowSetHeader
-	Records []DataRecord
-	Bytes   []byte
+	Header         FlowSetHeader
+	Records        []DataRecord
+	OptionsRecords []OptionsDataRecord
+	// Shaped are the records decoded by the shape registered for the
+	// template, see RegisterShape.
+	Shaped []interface{}
+	Bytes  []byte
+}
+
+// UnmarshalOptions decodes the Options Data Records described by an Options
+// Template Record.
+func (dfs *DataFlowSet) UnmarshalOptions(r io.Reader, otr OptionsTemplateRecord, t *Translate) error {
+	buffer := new(bytes.Buffer)
+	buffer.ReadFrom(r)
+
+	size := otr.Size()
+	if size == 0 {
+		return errProtocol("options template %d has no fields", otr.TemplateID)
+	}
+
+	dfs.OptionsRecords = make([]OptionsDataRecord, 0)
+	for buffer.Len() >= size { // Continue until only padding alignment bytes left
+		var odr = OptionsDataRecord{}
+		odr.TemplateID = otr.TemplateID
+		if err := odr.Unmarshal(bytes.NewBuffer(buffer.Next(size)), otr, t); err != nil {
+			return err
+		}
+		dfs.OptionsRecords = append(dfs.OptionsRecords, odr)
+	}
+
+	return dfs.checkPadding(buffer.Len(), size)
 }
 
 func (dfs *DataFlowSet) Unmarshal(r io.Reader, tr TemplateRecord, t *Translate) error {
 	buffer := new(bytes.Buffer)
 	buffer.ReadFrom(r)
 
+	if fn, ok := lookupShape(tr.Fields); ok {
+		size := tr.Size()
+		for size > 0 && buffer.Len() >= size {
+			dfs.Shaped = append(dfs.Shaped, fn(buffer.Next(size)))
+		}
+		return dfs.checkPadding(buffer.Len(), size)
+	}
+
+	// Records with variable length fields have to be read field by field,
+	// otherwise we can slice the buffer per record.
+	variable := tr.HasVariableLength()
+	if !variable && tr.Size() == 0 && buffer.Len() > 0 {
+		// The records take no bytes, the walk below would never end.
+		return errInvalidLength("template %d describes records of 0 bytes", tr.TemplateID)
+	}
+
 	dfs.Records = make([]DataRecord, 0)
 	for buffer.Len() >= 4 { // Continue until only padding alignment bytes left
+		if !variable && buffer.Len() < tr.Size() {
+			break
+		}
 		var dr = DataRecord{}
 		dr.TemplateID = tr.TemplateID
-		if err := dr.Unmarshal(bytes.NewBuffer(buffer.Next(tr.Size())), tr.Fields, t); err != nil {
+		var rr io.Reader = buffer
+		if !variable {
+			rr = bytes.NewBuffer(buffer.Next(tr.Size()))
+		}
+		if err := dr.Unmarshal(rr, tr.Fields, t); err != nil {
 			return err
 		}
 		dfs.Records = append(dfs.Records, dr)
 	}
 
+	if variable {
+		return nil
+	}
+	return dfs.checkPadding(buffer.Len(), tr.Size())
+}
+
+// checkPadding verifies the n bytes left after the last record of the FlowSet
+// can be padding, which is less than 4 bytes as per RFC 3954 section 5.3. A
+// FlowSet Length that does not match the records is reported as an error, as
+// the records can not be trusted.
+func (dfs *DataFlowSet) checkPadding(n, size int) error {
+	if n >= 4 {
+		return errInvalidLength("data flowset %d length %d leaves %d bytes, not a multiple of the record size %d",
+			dfs.Header.ID, dfs.Header.Length, n, size)
+	}
 	return nil
 }
 
@@ -425,26 +878,31 @@ type DataRecord struct {
 	Fields     Fields
 }
 
+// Unmarshal reads the fields described by the template from r. If reading
+// fails, for example because of a timeout, the record is left unchanged.
 func (dr *DataRecord) Unmarshal(r io.Reader, fss FieldSpecifiers, t *Translate) error {
-	// We don't know how many records there are in a Data Set, so we'll keep
-	// reading until we exhausted the buffer.
-	buffer := new(bytes.Buffer)
-	if _, err := buffer.ReadFrom(r); err != nil {
-		return err
-	}
-
-	dr.Fields = make(Fields, 0)
+	// Keep reading fields until we read all fields described by the template,
+	// or until we exhausted the reader. A truncated field is left out.
+	fields := make(Fields, 0)
 	var err error
-	for i := 0; buffer.Len() > 0 && i < len(fss); i++ {
+	for i := 0; i < len(fss); i++ {
 		f := Field{
 			Type:   fss[i].Type,
 			Length: fss[i].Length,
 		}
-		if err = f.Unmarshal(buffer); err != nil {
+		if t != nil && !t.Wants(translate.Key{FieldID: f.Type}) {
+			err = f.skip(r)
+		} else if err = f.Unmarshal(r); err == nil {
+			fields = append(fields, f)
+		}
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
 			return err
 		}
-		dr.Fields = append(dr.Fields, f)
 	}
+	dr.Fields = fields
 
 	if t != nil && len(dr.Fields) > 0 {
 		if err := t.Record(dr); err != nil {
@@ -455,6 +913,165 @@ func (dr *DataRecord) Unmarshal(r io.Reader, fss FieldSpecifiers, t *Translate)
 	return nil
 }
 
+// OptionsDataRecord is a Data Record described by an Options Template Record,
+// containing the scope fields followed by the option fields.
+type OptionsDataRecord struct {
+	TemplateID   uint16
+	ScopeFields  Fields
+	OptionFields Fields
+}
+
+// Unmarshal reads the scope and option fields described by the template from
+// r. If reading fails, the record is left unchanged.
+func (odr *OptionsDataRecord) Unmarshal(r io.Reader, otr OptionsTemplateRecord, t *Translate) error {
+	scope := make(Fields, len(otr.ScopeFields))
+	for i, fs := range otr.ScopeFields {
+		scope[i] = Field{Type: fs.Type, Length: fs.Length}
+		if err := scope[i].Unmarshal(r); err != nil {
+			return err
+		}
+	}
+
+	options := make(Fields, len(otr.Fields))
+	for i, fs := range otr.Fields {
+		options[i] = Field{Type: fs.Type, Length: fs.Length}
+		if err := options[i].Unmarshal(r); err != nil {
+			return err
+		}
+	}
+	odr.ScopeFields, odr.OptionFields = scope, options
+
+	// Scope field types have their own namespace, only the option fields are
+	// translated.
+	if t != nil {
+		t.Fields(odr.OptionFields)
+	}
+
+	return nil
+}
+
+// Values returns the option field values keyed by field type. Translated
+// fields have their native value, other fields contain the raw bytes.
+func (odr OptionsDataRecord) Values() map[uint16]interface{} {
+	values := make(map[uint16]interface{}, len(odr.OptionFields))
+	for _, f := range odr.OptionFields {
+		if f.Translated != nil && f.Translated.Value != nil {
+			values[f.Type] = f.Translated.Value
+		} else {
+			values[f.Type] = f.Bytes
+		}
+	}
+	return values
+}
+
+// SamplingInterval returns the sampling interval from the samplingInterval
+// (34) or samplingPacketInterval (305) option fields.
+func (odr OptionsDataRecord) SamplingInterval() (uint32, bool) {
+	for _, f := range odr.OptionFields {
+		if f.Type == 34 || f.Type == 305 {
+			return uint32(f.Uint()), true
+		}
+	}
+	return 0, false
+}
+
+// Has reports whether the record includes the field type, so an absent field
+// can be told apart from a field with a zero value.
+func (dr DataRecord) Has(fieldType uint16) bool {
+	for _, f := range dr.Fields {
+		if f.Type == fieldType {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone returns a deep copy of the record, sharing no memory with dr, so it
+// can be kept after the buffers it was decoded from are reused.
+func (dr DataRecord) Clone() DataRecord {
+	c := DataRecord{TemplateID: dr.TemplateID, Fields: make(Fields, len(dr.Fields))}
+	for i, f := range dr.Fields {
+		c.Fields[i] = f.Clone()
+	}
+	return c
+}
+
+// AbsoluteTimes returns the wall clock start and end time of the flow. The
+// flowStartMilliseconds (152) and flowEndMilliseconds (153) fields are used if
+// present, otherwise the first (22) and last (21) switched SysUptime values
+// are converted using the export time and SysUptime of the packet header. If
+// the record has neither, ok is false.
+func (dr DataRecord) AbsoluteTimes(h *PacketHeader) (start, end time.Time, ok bool) {
+	var (
+		hasStart, hasEnd     bool
+		hasFirst, hasLast    bool
+		first, last          uint32
+		startMsecs, endMsecs uint64
+	)
+	for _, f := range dr.Fields {
+		switch f.Type {
+		case 152:
+			startMsecs, hasStart = f.Uint(), true
+		case 153:
+			endMsecs, hasEnd = f.Uint(), true
+		case 22:
+			first, hasFirst = uint32(f.Uint()), true
+		case 21:
+			last, hasLast = uint32(f.Uint()), true
+		}
+	}
+
+	switch {
+	case hasStart && hasEnd:
+		return msecsTime(startMsecs), msecsTime(endMsecs), true
+	case hasFirst && hasLast:
+		uptime := h.Uptime()
+		boot := h.ExportTime().Add(-uptime)
+		start = boot.Add(uptimeOffset(first, uptime))
+		end = boot.Add(uptimeOffset(last, uptime))
+		if end.Before(start) {
+			end = start
+		}
+		return start, end, true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// msecsTime converts milliseconds since the UNIX epoch to a time.
+func msecsTime(v uint64) time.Time {
+	return time.Unix(int64(v/1000), int64(v%1000)*int64(time.Millisecond))
+}
+
+// uptimeOffset returns the offset of a SysUptime value relative to boot time,
+// a value exceeding the uptime is assumed to predate a roll over of the 32 bit
+// counter.
+func uptimeOffset(v uint32, uptime time.Duration) time.Duration {
+	d := time.Duration(v) * time.Millisecond
+	if d > uptime {
+		d -= time.Duration(1<<32) * time.Millisecond
+	}
+	return d
+}
+
+// Scaled returns the packet (2) and byte (1) delta counts of the record
+// multiplied by the sampling rate, the rate is usually learned from an
+// Options Data Record. A rate of 0 means the flow was not sampled, and is
+// treated as a rate of 1.
+func (dr DataRecord) Scaled(rate uint32) (packets, octets uint64) {
+	if rate == 0 {
+		rate = 1
+	}
+	for _, f := range dr.Fields {
+		switch f.Type {
+		case 1:
+			octets = f.Uint() * uint64(rate)
+		case 2:
+			packets = f.Uint() * uint64(rate)
+		}
+	}
+	return
+}
+
 type Field struct {
 	Type       uint16
 	Length     uint16
@@ -463,12 +1080,49 @@ type Field struct {
 }
 
 func (f *Field) Unmarshal(r io.Reader) error {
+	if f.Length == VariableLength {
+		var err error
+		f.Bytes, err = read.VariableLength(f.Bytes, r)
+		return err
+	}
+
 	f.Bytes = make([]byte, f.Length)
-	if _, err := r.Read(f.Bytes); err != nil {
+	if _, err := io.ReadFull(r, f.Bytes); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// skip discards the bytes of the field from the reader.
+func (f *Field) skip(r io.Reader) error {
+	if f.Length == VariableLength {
+		return read.SkipVariableLength(r)
+	}
+	return read.Skip(r, int(f.Length))
+}
+
+// Uint returns the raw bytes of the field as a big endian unsigned integer,
+// fields wider than 8 bytes are truncated to their lowest 8 bytes.
+func (f Field) Uint() uint64 {
+	var v uint64
+	for _, b := range f.Bytes {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// Clone returns a copy of the field and its translated value, sharing no
+// memory with f.
+func (f Field) Clone() Field {
+	f.Bytes = append([]byte(nil), f.Bytes...)
+	if f.Translated != nil {
+		tf := *f.Translated
+		tf.Bytes = append([]byte(nil), tf.Bytes...)
+		tf.Value = translate.CloneValue(tf.Value)
+		f.Translated = &tf
+	}
+	return f
+}
+
 type Fields []Field
@@ -0,0 +1,576 @@
+package netflow9
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/tehmaze/netflow/read"
+	"github.com/tehmaze/netflow/session"
+)
+
+// testPacket builds a NetFlow v9 packet from the provided FlowSets.
+func testPacket(count uint16, flowSets ...[]byte) []byte {
+	b := make([]byte, 20)
+	binary.BigEndian.PutUint16(b[0:], Version)
+	binary.BigEndian.PutUint16(b[2:], count)
+	binary.BigEndian.PutUint32(b[4:], 100000)     // SysUpTime
+	binary.BigEndian.PutUint32(b[8:], 1577836800) // UnixSecs
+	binary.BigEndian.PutUint32(b[12:], 1)         // SequenceNumber
+	binary.BigEndian.PutUint32(b[16:], 1)         // SourceID
+	for _, fs := range flowSets {
+		b = append(b, fs...)
+	}
+	return b
+}
+
+// testFlowSet builds a FlowSet, padding is added to align to 4 bytes.
+func testFlowSet(id uint16, data ...byte) []byte {
+	for len(data)%4 != 0 {
+		data = append(data, 0x00)
+	}
+	b := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[2:], uint16(4+len(data)))
+	return append(b, data...)
+}
+
+// testTemplateFlowSet is a template with sourceIPv4Address,
+// sourceTransportPort and octetDeltaCount.
+var testTemplateFlowSet = testFlowSet(0,
+	0x01, 0x00, 0x00, 0x03, // template id 256, 3 fields
+	0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+	0x00, 0x07, 0x00, 0x02, // sourceTransportPort
+	0x00, 0x01, 0x00, 0x04, // octetDeltaCount
+)
+
+func TestPacketFlowSets(t *testing.T) {
+	data := testPacket(2, testTemplateFlowSet, testFlowSet(256,
+		0xc0, 0x00, 0x02, 0x01, 0x00, 0x50, 0x00, 0x00, 0x05, 0xdc,
+	))
+
+	c := session.NewTemplateCache()
+	addr := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+
+	p, err := Read(bytes.NewReader(data), c.Session(addr), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trs := p.Templates()
+	if len(trs) != 1 || trs[0].TemplateID != 256 {
+		t.Fatalf("expected template 256 to be learned, got %v", trs)
+	}
+	if _, ok := c.Lookup(addr, 1, 256); !ok {
+		t.Fatal("expected template 256 to be registered in the cache for Source ID 1")
+	}
+
+	drs := p.DataRecords()
+	if len(drs) != 1 {
+		t.Fatalf("expected 1 data record, got %d", len(drs))
+	}
+	var want = []struct {
+		Name  string
+		Value string
+	}{
+		{"sourceIPv4Address", "192.0.2.1"},
+		{"sourceTransportPort", "80"},
+		{"octetDeltaCount", "1500"},
+	}
+	for i, f := range drs[0].Fields {
+		if f.Translated == nil {
+			t.Fatalf("field %d: not translated", i)
+		}
+		if f.Translated.Name != want[i].Name {
+			t.Errorf("field %d: expected %s, got %s", i, want[i].Name, f.Translated.Name)
+		}
+		if v := fmt.Sprint(f.Translated.Value); v != want[i].Value {
+			t.Errorf("field %d: expected %s, got %s", i, want[i].Value, v)
+		}
+	}
+}
+
+func TestPacketUnknownTemplate(t *testing.T) {
+	data := testPacket(1, testFlowSet(257, 0x01, 0x02, 0x03, 0x04))
+
+	p, err := Read(bytes.NewReader(data), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.DataFlowSets) != 1 || len(p.DataFlowSets[0].Bytes) != 4 {
+		t.Fatalf("expected data flow set with raw bytes, got %+v", p.DataFlowSets)
+	}
+}
+
+func TestDataFlowSetVariableLength(t *testing.T) {
+	tr := TemplateRecord{
+		TemplateID: 256,
+		FieldCount: 2,
+		Fields: FieldSpecifiers{
+			{Type: 7, Length: 2},               // sourceTransportPort
+			{Type: 82, Length: VariableLength}, // interfaceName
+		},
+	}
+	data := []byte{
+		0x00, 0x50, 0x05, 'e', 't', 'h', '0', '1',
+		0x01, 0xbb, 0x00,
+		0x1f, 0x90, 0xff, 0x00, 0x03, 'l', 'o', '0',
+		0x00, // padding
+	}
+
+	dfs := DataFlowSet{}
+	if err := dfs.Unmarshal(bytes.NewBuffer(data), tr, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(dfs.Records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(dfs.Records))
+	}
+
+	for i, want := range []string{"eth01", "", "lo0"} {
+		fields := dfs.Records[i].Fields
+		if len(fields) != 2 {
+			t.Fatalf("record %d: expected 2 fields, got %d", i, len(fields))
+		}
+		if got := string(fields[1].Bytes); got != want {
+			t.Errorf("record %d: expected %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestPacketOptions(t *testing.T) {
+	data := testPacket(2,
+		testFlowSet(1,
+			0x01, 0x01, // template id 257
+			0x00, 0x04, // option scope length
+			0x00, 0x08, // option length
+			0x00, 0x01, 0x00, 0x04, // scope system
+			0x00, 0x22, 0x00, 0x04, // samplingInterval
+			0x00, 0x23, 0x00, 0x01, // samplingAlgorithm
+		),
+		testFlowSet(257,
+			0xc0, 0x00, 0x02, 0x01, // system
+			0x00, 0x00, 0x03, 0xe8, // samplingInterval 1000
+			0x02, // samplingAlgorithm random
+		),
+	)
+
+	p, err := Read(bytes.NewReader(data), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.OptionsTemplateFlowSets) != 1 || len(p.OptionsTemplateFlowSets[0].Records) != 1 {
+		t.Fatalf("expected 1 options template, got %+v", p.OptionsTemplateFlowSets)
+	}
+	otr := p.OptionsTemplateFlowSets[0].Records[0]
+	if otr.OptionScopeLength != 4 || otr.OptionLength != 8 {
+		t.Errorf("expected scope length 4 and option length 8, got %d and %d", otr.OptionScopeLength, otr.OptionLength)
+	}
+	if len(otr.ScopeFields) != 1 || len(otr.Fields) != 2 {
+		t.Fatalf("expected 1 scope and 2 option fields, got %s", otr)
+	}
+	if otr.ScopeFields[0].Type != ScopeSystem {
+		t.Errorf("expected scope %s, got %s", ScopeName(ScopeSystem), ScopeName(otr.ScopeFields[0].Type))
+	}
+	if otr.Fields[0].Type != 34 || otr.Fields[1].Type != 35 {
+		t.Errorf("expected option fields 34 and 35, got %s", otr.Fields)
+	}
+
+	odrs := p.OptionsDataRecords()
+	if len(odrs) != 1 {
+		t.Fatalf("expected 1 options data record, got %d", len(odrs))
+	}
+	if scope := odrs[0].ScopeFields; len(scope) != 1 || !bytes.Equal(scope[0].Bytes, []byte{0xc0, 0x00, 0x02, 0x01}) {
+		t.Errorf("unexpected scope fields %v", scope)
+	}
+	values := odrs[0].Values()
+	if v, ok := values[34].(uint32); !ok || v != 1000 {
+		t.Errorf("expected samplingInterval 1000, got %v (%T)", values[34], values[34])
+	}
+	if v, ok := values[35].(uint8); !ok || v != 2 {
+		t.Errorf("expected samplingAlgorithm 2, got %v (%T)", values[35], values[35])
+	}
+
+	rate, ok := odrs[0].SamplingInterval()
+	if !ok || rate != 1000 {
+		t.Fatalf("expected sampling interval 1000, got %d (%t)", rate, ok)
+	}
+	dr := DataRecord{Fields: Fields{
+		{Type: 1, Length: 4, Bytes: []byte{0xff, 0xff, 0xff, 0xff}},
+		{Type: 2, Length: 2, Bytes: []byte{0x00, 0x0a}},
+	}}
+	if packets, octets := dr.Scaled(rate); packets != 10000 || octets != 0xffffffff*1000 {
+		t.Errorf("expected 10000 packets and %d bytes, got %d and %d", uint64(0xffffffff*1000), packets, octets)
+	}
+}
+
+func TestPacketOptionsScopes(t *testing.T) {
+	// More scope than option fields, the scopes precede the options in the
+	// data record.
+	data := testPacket(2,
+		testFlowSet(1,
+			0x01, 0x02, // template id 258
+			0x00, 0x08, // option scope length
+			0x00, 0x04, // option length
+			0x00, 0x02, 0x00, 0x02, // scope interface
+			0x00, 0x05, 0x00, 0x02, // scope template
+			0x00, 0x22, 0x00, 0x04, // samplingInterval
+		),
+		testFlowSet(258,
+			0x00, 0x03, // interface 3
+			0x01, 0x00, // template 256
+			0x00, 0x00, 0x00, 0x64, // samplingInterval 100
+		),
+	)
+
+	p, err := Read(bytes.NewReader(data), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	odrs := p.OptionsDataRecords()
+	if len(odrs) != 1 {
+		t.Fatalf("expected 1 options data record, got %d", len(odrs))
+	}
+	scope := odrs[0].ScopeFields
+	if len(scope) != 2 || scope[0].Type != ScopeInterface || scope[0].Uint() != 3 ||
+		scope[1].Type != ScopeTemplate || scope[1].Uint() != 256 {
+		t.Errorf("unexpected scope fields %v", scope)
+	}
+	if rate, ok := odrs[0].SamplingInterval(); !ok || rate != 100 {
+		t.Errorf("expected sampling interval 100, got %d (%t)", rate, ok)
+	}
+}
+
+func TestScopeName(t *testing.T) {
+	for scopeType, want := range map[uint16]string{
+		ScopeSystem:    "System",
+		ScopeInterface: "Interface",
+		ScopeLineCard:  "Line Card",
+		ScopeCache:     "Cache",
+		ScopeTemplate:  "Template",
+		6:              "6",
+	} {
+		if got := ScopeName(scopeType); got != want {
+			t.Errorf("scope %d: expected %q, got %q", scopeType, want, got)
+		}
+	}
+}
+
+func TestDataRecordHas(t *testing.T) {
+	// A template without srcAS (16) and dstAS (17).
+	data := testPacket(2, testTemplateFlowSet, testFlowSet(256,
+		0xc0, 0x00, 0x02, 0x01, 0x00, 0x50, 0x00, 0x00, 0x00, 0x00,
+	))
+
+	p, err := Read(bytes.NewReader(data), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drs := p.DataRecords()
+	if len(drs) != 1 {
+		t.Fatalf("expected 1 data record, got %d", len(drs))
+	}
+	if !drs[0].Has(1) {
+		t.Error("expected octetDeltaCount to be present, even though it is zero")
+	}
+	if drs[0].Has(16) || drs[0].Has(17) {
+		t.Error("expected srcAS and dstAS to be absent")
+	}
+}
+
+func TestDataRecordClone(t *testing.T) {
+	data := testPacket(2, testTemplateFlowSet, testFlowSet(256,
+		0xc0, 0x00, 0x02, 0x01, 0x00, 0x50, 0x00, 0x00, 0x05, 0xdc,
+	))
+
+	p, err := Read(bytes.NewReader(data), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dr := p.DataRecords()[0]
+	c := dr.Clone()
+
+	// The translated address shares its bytes with the field.
+	dr.Fields[0].Bytes[3] = 0xff
+	dr.Fields[1].Translated.Name = "changed"
+	dr.Fields[2] = Field{}
+
+	if s := fmt.Sprint(c.Fields[0].Translated.Value); s != "192.0.2.1" || c.Fields[0].Bytes[3] != 0x01 {
+		t.Errorf("expected cloned address 192.0.2.1, got %s", s)
+	}
+	if c.Fields[1].Translated.Name != "sourceTransportPort" {
+		t.Errorf("expected cloned name sourceTransportPort, got %s", c.Fields[1].Translated.Name)
+	}
+	if c.TemplateID != 256 || c.Fields[2].Uint() != 1500 {
+		t.Errorf("expected cloned octetDeltaCount 1500, got %+v", c.Fields[2])
+	}
+}
+
+func TestDataRecordUnmarshalTimeout(t *testing.T) {
+	fss := FieldSpecifiers{{Type: 8, Length: 4}, {Type: 7, Length: 2}, {Type: 1, Length: 4}}
+
+	// The stream stalls in the middle of the second field.
+	r := io.MultiReader(bytes.NewReader([]byte{0xc0, 0x00, 0x02, 0x01, 0x00}), iotest.ErrReader(os.ErrDeadlineExceeded))
+	dr := DataRecord{TemplateID: 256, Fields: Fields{{Type: 2, Length: 4}}}
+	if err := dr.Unmarshal(r, fss, nil); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if len(dr.Fields) != 1 || dr.Fields[0].Type != 2 {
+		t.Errorf("expected record to be left unchanged, got %+v", dr.Fields)
+	}
+
+	var h PacketHeader
+	r = io.MultiReader(bytes.NewReader(testPacket(1)[:6]), iotest.ErrReader(os.ErrDeadlineExceeded))
+	if err := h.Unmarshal(r); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if h != (PacketHeader{}) {
+		t.Errorf("expected header to be left unchanged, got %+v", h)
+	}
+}
+
+func TestFieldUnmarshalShortRead(t *testing.T) {
+	f := Field{Type: 8, Length: 4}
+	if err := f.Unmarshal(iotest.OneByteReader(bytes.NewReader([]byte{0xc0, 0x00, 0x02, 0x01}))); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Bytes, []byte{0xc0, 0x00, 0x02, 0x01}) {
+		t.Fatalf("expected all bytes of the field, got %x", f.Bytes)
+	}
+
+	f = Field{Type: 8, Length: 4}
+	if err := f.Unmarshal(bytes.NewReader([]byte{0xc0, 0x00})); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestDecodeAll(t *testing.T) {
+	data := testPacket(6, // 2 template records and 4 data records
+		testFlowSet(0,
+			0x01, 0x00, 0x00, 0x02, // template id 256, 2 fields
+			0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+			0x00, 0x07, 0x00, 0x02, // sourceTransportPort
+			0x01, 0x01, 0x00, 0x02, // template id 257, 2 fields
+			0x00, 0xe1, 0x00, 0x04, // postNATSourceIPv4Address
+			0x00, 0xe6, 0x00, 0x01, // natEvent
+		),
+		testFlowSet(256, 0xc0, 0x00, 0x02, 0x01, 0x00, 0x50),
+		testFlowSet(257, 0xcb, 0x00, 0x71, 0x01, 0x01, 0xcb, 0x00, 0x71, 0x02, 0x02),
+		testFlowSet(256, 0xc0, 0x00, 0x02, 0x02, 0x01, 0xbb),
+	)
+
+	drs, trs, err := DecodeAll(bytes.NewReader(data), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trs) != 2 || trs[0].TemplateID != 256 || trs[1].TemplateID != 257 {
+		t.Fatalf("expected templates 256 and 257 to be learned, got %v", trs)
+	}
+	if len(drs) != 2 {
+		t.Fatalf("expected records for 2 templates, got %d", len(drs))
+	}
+	if len(drs[256]) != 2 {
+		t.Fatalf("expected 2 records for template 256, got %d", len(drs[256]))
+	}
+	for i, port := range []uint64{80, 443} {
+		if v := drs[256][i].Fields[1].Uint(); v != port {
+			t.Errorf("template 256 record %d: expected port %d, got %d", i, port, v)
+		}
+	}
+	if len(drs[257]) != 2 {
+		t.Fatalf("expected 2 records for template 257, got %d", len(drs[257]))
+	}
+	for i, dr := range drs[257] {
+		if dr.TemplateID != 257 || dr.Fields[1].Uint() != uint64(i+1) {
+			t.Errorf("template 257 record %d: unexpected %v", i, dr.Fields)
+		}
+	}
+}
+
+func TestPacketHeaderUnmarshal(t *testing.T) {
+	data := []byte{
+		0x00, 0x09, 0x00, 0x05, // version 9, count 5
+		0x00, 0x01, 0x86, 0xa0, // SysUpTime
+		0x5e, 0x0b, 0xe1, 0x00, // UnixSecs
+		0x00, 0x00, 0x04, 0xd2, // SequenceNumber
+		0x00, 0x01, 0x00, 0x02, // SourceID
+	}
+
+	var h PacketHeader
+	if err := h.Unmarshal(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	want := PacketHeader{
+		Version:        Version,
+		Count:          5,
+		SysUpTime:      100000,
+		UnixSecs:       1577836800,
+		SequenceNumber: 1234,
+		SourceID:       0x00010002,
+	}
+	if h != want {
+		t.Fatalf("expected %+v, got %+v", want, h)
+	}
+	if h.Domain() != 0x00010002 {
+		t.Errorf("expected domain %#x, got %#x", 0x00010002, h.Domain())
+	}
+	if b := h.AppendBytes(nil); !bytes.Equal(b, data) {
+		t.Errorf("expected %x, got %x", data, b)
+	}
+}
+
+func TestPacketSourceIDScope(t *testing.T) {
+	var (
+		c    = session.NewTemplateCache()
+		addr = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+	)
+
+	// Both Source IDs use template 256, with a different layout.
+	a := testPacket(2, testFlowSet(0,
+		0x01, 0x00, 0x00, 0x01, // template id 256, 1 field
+		0x00, 0x07, 0x00, 0x02, // sourceTransportPort
+	), testFlowSet(256, 0x00, 0x50))
+	binary.BigEndian.PutUint32(a[16:], 1)
+	b := testPacket(2, testFlowSet(0,
+		0x01, 0x00, 0x00, 0x01, // template id 256, 1 field
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+	), testFlowSet(256, 0xc0, 0x00, 0x02, 0x01))
+	binary.BigEndian.PutUint32(b[16:], 2)
+
+	for _, data := range [][]byte{a, b} {
+		if _, err := Read(bytes.NewReader(data), c.Session(addr), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Data for Source ID 1 must still decode with its own template.
+	a = testPacket(1, testFlowSet(256, 0x01, 0xbb))
+	binary.BigEndian.PutUint32(a[16:], 1)
+	p, err := Read(bytes.NewReader(a), c.Session(addr), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drs := p.DataRecords()
+	if len(drs) != 1 || len(drs[0].Fields) != 1 {
+		t.Fatalf("expected 1 data record with 1 field, got %v", drs)
+	}
+	if f := drs[0].Fields[0]; f.Translated == nil || f.Translated.Name != "sourceTransportPort" || f.Uint() != 443 {
+		t.Fatalf("expected sourceTransportPort 443, got %v", f.Translated)
+	}
+
+	b = testPacket(1, testFlowSet(256, 0xc0, 0x00, 0x02, 0x02))
+	binary.BigEndian.PutUint32(b[16:], 2)
+	if p, err = Read(bytes.NewReader(b), c.Session(addr), nil); err != nil {
+		t.Fatal(err)
+	}
+	if v := fmt.Sprint(p.DataRecords()[0].Fields[0].Translated.Value); v != "192.0.2.2" {
+		t.Fatalf("expected sourceIPv4Address 192.0.2.2, got %s", v)
+	}
+}
+
+func TestDataFlowSetZeroSize(t *testing.T) {
+	data := testPacket(2, testFlowSet(0,
+		0x01, 0x00, 0x00, 0x01, // template id 256, 1 field
+		0x00, 0x08, 0x00, 0x00, // sourceIPv4Address of zero length
+	), testFlowSet(256, 0xc0, 0x00, 0x02, 0x01))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ReadBytes(data, session.New(), nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, read.ErrInvalidLength) {
+			t.Fatalf("expected invalid length error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout decoding records of a zero size template")
+	}
+}
+
+func TestPacketFlowSetPadding(t *testing.T) {
+	template := testFlowSet(0,
+		0x01, 0x00, 0x00, 0x02, // template id 256, 2 fields
+		0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+		0x00, 0x07, 0x00, 0x02, // sourceTransportPort
+	)
+	// A 6 byte record, the FlowSet Length includes 2 padding bytes.
+	data := testFlowSet(256, 0xc0, 0x00, 0x02, 0x01, 0x00, 0x50)
+	if len(data) != 12 {
+		t.Fatalf("expected 2 padding bytes, got flowset %x", data)
+	}
+
+	p, err := Read(bytes.NewReader(testPacket(3, template, data, data)), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.DataFlowSets) != 2 {
+		t.Fatalf("expected 2 data flowsets, got %d", len(p.DataFlowSets))
+	}
+	for _, dfs := range p.DataFlowSets {
+		if len(dfs.Records) != 1 {
+			t.Fatalf("expected 1 record, got %d", len(dfs.Records))
+		}
+		if f := dfs.Records[0].Fields[1]; f.Uint() != 80 {
+			t.Errorf("expected sourceTransportPort 80, got %d", f.Uint())
+		}
+	}
+
+	// A Length leaving more bytes than padding is inconsistent.
+	data = []byte{
+		0x01, 0x00, 0x00, 0x0e, // flowset id 256, length 14
+		0xc0, 0x00, 0x02, 0x01, 0x00, 0x50,
+		0xc0, 0x00, 0x02, 0x02,
+	}
+	if _, err = Read(bytes.NewReader(testPacket(3, template, data)), session.New(), nil); err == nil {
+		t.Fatal("expected error for inconsistent flowset length")
+	}
+}
+
+func TestDataRecordAbsoluteTimes(t *testing.T) {
+	h := &PacketHeader{SysUpTime: 100000, UnixSecs: 1577836800}
+	field := func(typ uint16, b ...byte) Field {
+		return Field{Type: typ, Length: uint16(len(b)), Bytes: b}
+	}
+
+	var tests = []struct {
+		Name       string
+		Fields     Fields
+		Start, End string
+	}{
+		{"milliseconds", Fields{
+			field(152, 0, 0, 0x01, 0x6f, 0x5e, 0x66, 0xe8, 0x00),
+			field(153, 0, 0, 0x01, 0x6f, 0x5e, 0x66, 0xec, 0xe8),
+			field(22, 0, 0, 0, 0), // ignored, the absolute times win
+			field(21, 0, 0, 0, 0),
+		}, "2020-01-01T00:00:00Z", "2020-01-01T00:00:01.256Z"},
+		{"uptime", Fields{
+			field(22, 0x00, 0x01, 0x5f, 0x90), // 90000
+			field(21, 0x00, 0x01, 0x86, 0xa0), // 100000
+		}, "2019-12-31T23:59:50Z", "2020-01-01T00:00:00Z"},
+	}
+	for _, test := range tests {
+		start, end, ok := DataRecord{Fields: test.Fields}.AbsoluteTimes(h)
+		if !ok {
+			t.Fatalf("%s: expected times", test.Name)
+		}
+		if s := start.UTC().Format(time.RFC3339Nano); s != test.Start {
+			t.Errorf("%s: expected start %s, got %s", test.Name, test.Start, s)
+		}
+		if e := end.UTC().Format(time.RFC3339Nano); e != test.End {
+			t.Errorf("%s: expected end %s, got %s", test.Name, test.End, e)
+		}
+	}
+
+	if _, _, ok := (DataRecord{Fields: Fields{field(7, 0x00, 0x50)}}).AbsoluteTimes(h); ok {
+		t.Error("expected no times for a record without timestamps")
+	}
+}
@@ -0,0 +1,54 @@
+package netflow9
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// ShapeFunc decodes the wire bytes of a Data Record into a value, typically a
+// pointer to a struct with a field per template field.
+type ShapeFunc func(b []byte) interface{}
+
+// Registered template shapes, keyed by shapeKey.
+var (
+	shapes      = make(map[string]ShapeFunc)
+	shapesMutex sync.RWMutex
+)
+
+// RegisterShape adds or replaces the decoder for templates with exactly the
+// field types and lengths of fss, in that order. The Data Records of matching
+// templates are decoded by fn into DataFlowSet.Shaped, in stead of into
+// DataFlowSet.Records, and are not translated. Shapes are available to all
+// decoders. A shape with variable length fields can not be registered, as fn
+// decodes records of a fixed size.
+func RegisterShape(fss FieldSpecifiers, fn ShapeFunc) {
+	for _, fs := range fss {
+		if fs.IsVariableLength() {
+			panic("netflow9: shape has a variable length field")
+		}
+	}
+	shapesMutex.Lock()
+	defer shapesMutex.Unlock()
+	shapes[shapeKey(fss)] = fn
+}
+
+// lookupShape returns the decoder registered for the template fields.
+func lookupShape(fss FieldSpecifiers) (ShapeFunc, bool) {
+	shapesMutex.RLock()
+	defer shapesMutex.RUnlock()
+	if len(shapes) == 0 {
+		return nil, false
+	}
+	fn, ok := shapes[shapeKey(fss)]
+	return fn, ok
+}
+
+// shapeKey is the wire format of the field specifiers.
+func shapeKey(fss FieldSpecifiers) string {
+	b := make([]byte, 4*len(fss))
+	for i, fs := range fss {
+		binary.BigEndian.PutUint16(b[4*i:], fs.Type)
+		binary.BigEndian.PutUint16(b[4*i+2:], fs.Length)
+	}
+	return string(b)
+}
@@ -0,0 +1,87 @@
+package netflow9
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// testFiveTuple is the shape of a 5-tuple template with a byte counter.
+type testFiveTuple struct {
+	SrcAddr, DstAddr net.IP
+	SrcPort, DstPort uint16
+	Protocol         uint8
+	Octets           uint32
+}
+
+var testFiveTupleShape = FieldSpecifiers{
+	{Type: 8, Length: 4},  // sourceIPv4Address
+	{Type: 12, Length: 4}, // destinationIPv4Address
+	{Type: 7, Length: 2},  // sourceTransportPort
+	{Type: 11, Length: 2}, // destinationTransportPort
+	{Type: 4, Length: 1},  // protocolIdentifier
+	{Type: 1, Length: 4},  // octetDeltaCount
+}
+
+func TestRegisterShape(t *testing.T) {
+	RegisterShape(testFiveTupleShape, func(b []byte) interface{} {
+		return &testFiveTuple{
+			SrcAddr:  net.IP(b[0:4]),
+			DstAddr:  net.IP(b[4:8]),
+			SrcPort:  binary.BigEndian.Uint16(b[8:]),
+			DstPort:  binary.BigEndian.Uint16(b[10:]),
+			Protocol: b[12],
+			Octets:   binary.BigEndian.Uint32(b[13:]),
+		}
+	})
+	defer func() {
+		shapesMutex.Lock()
+		delete(shapes, shapeKey(testFiveTupleShape))
+		shapesMutex.Unlock()
+	}()
+
+	tmpl := []byte{
+		0x01, 0x00, 0x00, 0x06, // template id 256, 6 fields
+		0x00, 0x08, 0x00, 0x04,
+		0x00, 0x0c, 0x00, 0x04,
+		0x00, 0x07, 0x00, 0x02,
+		0x00, 0x0b, 0x00, 0x02,
+		0x00, 0x04, 0x00, 0x01,
+		0x00, 0x01, 0x00, 0x04,
+	}
+	p, err := Read(bytes.NewReader(testPacket(3, testFlowSet(0, tmpl...), testFlowSet(256,
+		0xc0, 0x00, 0x02, 0x01, 0x0a, 0x00, 0x00, 0x01, 0x00, 0x50, 0xc7, 0x38, 0x06, 0x00, 0x00, 0x05, 0xdc,
+		0xc0, 0x00, 0x02, 0x02, 0x0a, 0x00, 0x00, 0x01, 0x01, 0xbb, 0xc7, 0x39, 0x11, 0x00, 0x00, 0x00, 0x40,
+	))), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := p.ShapedRecords()
+	if len(rs) != 2 || len(p.DataRecords()) != 0 {
+		t.Fatalf("expected 2 shaped records and no data records, got %d and %d", len(rs), len(p.DataRecords()))
+	}
+	r, ok := rs[1].(*testFiveTuple)
+	if !ok {
+		t.Fatalf("expected *testFiveTuple, got %T", rs[1])
+	}
+	if !r.SrcAddr.Equal(net.IPv4(192, 0, 2, 2)) || r.SrcPort != 443 || r.DstPort != 51001 || r.Protocol != 17 || r.Octets != 64 {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if n := p.RecordCount(); n != 3 {
+		t.Errorf("expected 3 records, got %d", n)
+	}
+
+	// Other templates still decode into data records.
+	p, err = Read(bytes.NewReader(testPacket(2, testTemplateFlowSet, testFlowSet(256,
+		0xc0, 0x00, 0x02, 0x01, 0x00, 0x50, 0x00, 0x00, 0x05, 0xdc,
+	))), session.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.ShapedRecords()) != 0 || len(p.DataRecords()) != 1 {
+		t.Errorf("expected 1 data record, got %d shaped and %d data records", len(p.ShapedRecords()), len(p.DataRecords()))
+	}
+}
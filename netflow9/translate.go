@@ -58,21 +58,28 @@ func (t *Translate) Record(dr *DataRecord) error {
 		debugLog.Printf("translating %d/%d fields\n", len(dr.Fields), len(tr.Fields))
 	}
 
-	for i, field := range tr.Fields {
-		if i >= len(dr.Fields) {
-			break
-		}
-		f := &dr.Fields[i]
-		f.Translated = &TranslatedField{}
-		f.Translated.Type = field.Type
+	// Fields are translated using their own type, as fields may have been
+	// left out by a field filter.
+	t.Fields(dr.Fields)
 
-		if element, ok := t.Translate.Key(translate.Key{0, field.Type}); ok {
-			f.Translated.Name = element.Name
-			f.Translated.Value = translate.Bytes(dr.Fields[i].Bytes, element.Type)
-		} else if debug {
-			debugLog.Printf("no translator element for {0, %d}\n", field.Type)
-		}
+	return nil
+}
+
+// Fields translates fields using their own field type.
+func (t *Translate) Fields(fs Fields) {
+	for i := range fs {
+		t.field(&fs[i], fs[i].Type)
 	}
+}
 
-	return nil
+func (t *Translate) field(f *Field, fieldType uint16) {
+	f.Translated = &TranslatedField{}
+	f.Translated.Type = fieldType
+
+	if element, ok := t.Translate.Key(translate.Key{FieldID: fieldType}); ok {
+		f.Translated.Name = element.Name
+		f.Translated.Value = element.Value(f.Bytes)
+	} else if debug {
+		debugLog.Printf("no translator element for {0, %d}\n", fieldType)
+	}
 }
@@ -0,0 +1,206 @@
+package netflow
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+	"github.com/tehmaze/netflow/netflow9"
+)
+
+// Header is implemented by the packet headers of all supported versions.
+type Header interface {
+	// Len returns the length of the header on the wire in bytes.
+	Len() int
+	// ProtocolVersion returns the version announced in the header.
+	ProtocolVersion() uint16
+	// Sequence returns the sequence number, or zero if the version has none.
+	Sequence() uint32
+	// Uptime returns the uptime of the exporter, or zero if the version does
+	// not include it.
+	Uptime() time.Duration
+	String() string
+	Unmarshal(io.Reader) error
+}
+
+// Test if the packet headers are compliant
+var (
+	_ Header = (*netflow1.PacketHeader)(nil)
+	_ Header = (*netflow5.PacketHeader)(nil)
+	_ Header = (*netflow6.PacketHeader)(nil)
+	_ Header = (*netflow7.PacketHeader)(nil)
+	_ Header = (*netflow8.PacketHeader)(nil)
+	_ Header = (*netflow9.PacketHeader)(nil)
+	_ Header = (*ipfix.MessageHeader)(nil)
+)
+
+// Packet is a single decoded NetFlow packet.
+type Packet struct {
+	// Header is the version specific packet header.
+	Header Header
+	// Records are the flow records of the packet, only available for the
+	// fixed layout versions (1, 5, 6, 7 and 8).
+	Records []FlowRecord
+	// Message is the version specific decoded packet, such as a
+	// *netflow5.Packet or *ipfix.Message.
+	Message Message
+	// Skipped is the number of sets dropped because their template was not
+	// known, see WithSkipUnknownTemplates.
+	Skipped int
+	// Filtered is the number of records left out of Records by the record
+	// filter, see WithRecordFilter.
+	Filtered int
+	// Errors are the errors of the NetFlow v9 FlowSets that could not be
+	// decoded, each a *netflow9.FlowSetError. It is only set if the Decoder
+	// was configured using WithBestEffort.
+	Errors []error
+	// Missed is the number of records, or packets for NetFlow v9, lost since
+	// the previous packet from the same source. It is only set by
+	// Session.DecodePacket.
+	Missed int64
+	// Meta describes the datagram the packet was received in. It is only
+	// set for packets handed out by a Server.
+	Meta PacketMeta
+	// Raw are the wire bytes of the packet, excluding any trailing bytes.
+	// It is only set if the Decoder was configured using WithRawBytes.
+	Raw []byte
+	// RawRecords are the wire bytes of each of the Records, sharing their
+	// backing array with Raw. It is only set if the Decoder was configured
+	// using WithRawBytes.
+	RawRecords [][]byte
+
+	trailing []byte
+}
+
+// PacketMeta is the exporter and arrival information of a received packet.
+type PacketMeta struct {
+	// SourceAddr is the address of the exporter.
+	SourceAddr net.Addr
+	// ReceivedAt is the time the datagram was read from the socket.
+	ReceivedAt time.Time
+	// DatagramLen is the size of the datagram in bytes, including any
+	// trailing bytes.
+	DatagramLen int
+}
+
+// Trailing returns the bytes following the last record of the packet, as
+// padded by some exporters. It is only set by Decoder.DecodeBytes.
+func (p *Packet) Trailing() []byte {
+	return p.trailing
+}
+
+// setRaw stores the wire bytes b of the packet, and slices the bytes of the
+// individual fixed layout records from it.
+func (p *Packet) setRaw(b []byte) {
+	p.Raw = b
+	if p.Header == nil || len(p.Records) == 0 {
+		return
+	}
+	p.RawRecords = make([][]byte, len(p.Records))
+	o := p.Header.Len()
+	for i, r := range p.Records {
+		n := r.Len()
+		p.RawRecords[i] = b[o : o+n : o+n]
+		o += n
+	}
+}
+
+// filterRecords removes the records not kept by the filter from Records and
+// RawRecords.
+func (p *Packet) filterRecords(keep func(FlowRecord) bool) {
+	var n int
+	for i, r := range p.Records {
+		if !keep(r) {
+			continue
+		}
+		p.Records[n] = r
+		if p.RawRecords != nil {
+			p.RawRecords[n] = p.RawRecords[i]
+		}
+		n++
+	}
+	p.Filtered = len(p.Records) - n
+	p.Records = p.Records[:n]
+	if p.RawRecords != nil {
+		p.RawRecords = p.RawRecords[:n]
+	}
+}
+
+// String returns a one line summary of the packet.
+func (p *Packet) String() string {
+	if p.Header == nil {
+		return fmt.Sprintf("unknown records=%d", len(p.Records))
+	}
+	return fmt.Sprintf("v%d seq=%d records=%d uptime=%s",
+		p.Header.ProtocolVersion(), p.Header.Sequence(), len(p.Records), p.Header.Uptime())
+}
+
+func newPacket(m Message) *Packet {
+	p := &Packet{Message: m}
+	switch m := m.(type) {
+	case *netflow1.Packet:
+		p.Header = &m.Header
+		p.Records = make([]FlowRecord, len(m.Records))
+		for i, r := range m.Records {
+			p.Records[i] = r
+		}
+
+	case *netflow5.Packet:
+		p.Header = &m.Header
+		p.Records = make([]FlowRecord, len(m.Records))
+		for i, r := range m.Records {
+			p.Records[i] = r
+		}
+
+	case *netflow6.Packet:
+		p.Header = &m.Header
+		p.Records = make([]FlowRecord, len(m.Records))
+		for i, r := range m.Records {
+			p.Records[i] = r
+		}
+
+	case *netflow7.Packet:
+		p.Header = &m.Header
+		p.Records = make([]FlowRecord, len(m.Records))
+		for i, r := range m.Records {
+			p.Records[i] = r
+		}
+
+	case *netflow8.Packet:
+		p.Header = &m.Header
+		p.Records = make([]FlowRecord, len(m.Records))
+		for i, r := range m.Records {
+			p.Records[i] = r
+		}
+
+	case *netflow9.Packet:
+		p.Header = &m.Header
+
+	case *ipfix.Message:
+		p.Header = &m.Header
+	}
+	return p
+}
+
+// recordCount returns the number of flow records in the packet, including the
+// data records of NetFlow v9 and IPFIX and the records filtered out.
+func recordCount(p *Packet) int {
+	switch m := p.Message.(type) {
+	case *netflow9.Packet:
+		var n int
+		for _, dfs := range m.DataFlowSets {
+			n += len(dfs.Records) + len(dfs.Shaped)
+		}
+		return n
+	case *ipfix.Message:
+		return ipfixRecordCount(m)
+	}
+	return len(p.Records) + p.Filtered
+}
@@ -0,0 +1,72 @@
+package netflow
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// maxRateLimitSources is the number of sources a Server keeps a rate limit
+// for, the least recently seen source is forgotten first.
+const maxRateLimitSources = 4096
+
+// rateLimiter is a token bucket per source, kept in an LRU of bounded size.
+type rateLimiter struct {
+	mutex   sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64 // maximum number of tokens
+	size    int
+	buckets map[string]*list.Element
+	lru     *list.List // of *bucket, most recently seen first
+}
+
+type bucket struct {
+	source string
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(pps, size int) *rateLimiter {
+	return &rateLimiter{
+		rate:    float64(pps),
+		burst:   float64(pps),
+		size:    size,
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// allow takes a token from the bucket of the source, it reports false if the
+// bucket is empty. A source that is seen for the first time, or again after
+// its bucket was evicted, starts with a full bucket.
+func (l *rateLimiter) allow(source string, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var b *bucket
+	if e, ok := l.buckets[source]; ok {
+		l.lru.MoveToFront(e)
+		b = e.Value.(*bucket)
+		if elapsed := now.Sub(b.last); elapsed > 0 {
+			b.tokens += elapsed.Seconds() * l.rate
+			if b.tokens > l.burst {
+				b.tokens = l.burst
+			}
+		}
+		b.last = now
+	} else {
+		if l.lru.Len() >= l.size {
+			oldest := l.lru.Back()
+			l.lru.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*bucket).source)
+		}
+		b = &bucket{source: source, tokens: l.burst, last: now}
+		l.buckets[source] = l.lru.PushFront(b)
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
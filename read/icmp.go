@@ -0,0 +1,33 @@
+package read
+
+import "strconv"
+
+// Common ICMP (for IPv4) message types, see
+// http://www.iana.org/assignments/icmp-parameters/icmp-parameters.xhtml
+var icmpTypeNames = map[uint8]string{
+	0:  "echo-reply",
+	3:  "destination-unreachable",
+	4:  "source-quench",
+	5:  "redirect",
+	8:  "echo-request",
+	9:  "router-advertisement",
+	10: "router-solicitation",
+	11: "time-exceeded",
+	12: "parameter-problem",
+	13: "timestamp-request",
+	14: "timestamp-reply",
+}
+
+// ICMPName returns the lowercase name of an ICMP message type, followed by
+// "/<code>" if the code is not zero. Unknown types are rendered as
+// "icmp-<type>/<code>".
+func ICMPName(icmpType, code uint8) string {
+	name, ok := icmpTypeNames[icmpType]
+	if !ok {
+		return "icmp-" + strconv.Itoa(int(icmpType)) + "/" + strconv.Itoa(int(code))
+	}
+	if code != 0 {
+		return name + "/" + strconv.Itoa(int(code))
+	}
+	return name
+}
@@ -0,0 +1,85 @@
+package read
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Common IANA assigned protocol numbers, see
+// http://www.iana.org/assignments/protocol-numbers/protocol-numbers.xhtml
+var protocolNames = map[uint8]string{
+	0:   "hopopt",
+	1:   "icmp",
+	2:   "igmp",
+	3:   "ggp",
+	4:   "ipv4",
+	5:   "st",
+	6:   "tcp",
+	8:   "egp",
+	9:   "igp",
+	12:  "pup",
+	17:  "udp",
+	20:  "hmp",
+	22:  "xns-idp",
+	27:  "rdp",
+	29:  "iso-tp4",
+	33:  "dccp",
+	36:  "xtp",
+	37:  "ddp",
+	41:  "ipv6",
+	43:  "ipv6-route",
+	44:  "ipv6-frag",
+	46:  "rsvp",
+	47:  "gre",
+	50:  "esp",
+	51:  "ah",
+	58:  "ipv6-icmp",
+	59:  "ipv6-nonxt",
+	60:  "ipv6-opts",
+	88:  "eigrp",
+	89:  "ospf",
+	94:  "ipip",
+	97:  "etherip",
+	98:  "encap",
+	103: "pim",
+	108: "ipcomp",
+	112: "vrrp",
+	115: "l2tp",
+	132: "sctp",
+	133: "fc",
+	135: "mobility-header",
+	136: "udplite",
+	137: "mpls-in-ip",
+}
+
+var protocolNumbers = map[string]uint8{}
+
+func init() {
+	for n, name := range protocolNames {
+		protocolNumbers[name] = n
+	}
+}
+
+// ProtocolName returns the canonical lowercase name of an IP protocol number,
+// or "proto-<n>" if the protocol is unknown.
+func ProtocolName(p uint8) string {
+	if name, ok := protocolNames[p]; ok {
+		return name
+	}
+	return "proto-" + strconv.Itoa(int(p))
+}
+
+// ProtocolNumber returns the IP protocol number for a protocol name, as
+// returned by ProtocolName.
+func ProtocolNumber(name string) (uint8, bool) {
+	name = strings.ToLower(name)
+	if p, ok := protocolNumbers[name]; ok {
+		return p, true
+	}
+	if strings.HasPrefix(name, "proto-") {
+		if n, err := strconv.Atoi(name[6:]); err == nil && n >= 0 && n <= 0xff {
+			return uint8(n), true
+		}
+	}
+	return 0, false
+}
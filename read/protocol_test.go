@@ -0,0 +1,53 @@
+package read
+
+import "testing"
+
+func TestProtocolName(t *testing.T) {
+	var tests = []struct {
+		Number uint8
+		Name   string
+	}{
+		{1, "icmp"},
+		{6, "tcp"},
+		{17, "udp"},
+		{47, "gre"},
+		{50, "esp"},
+		{58, "ipv6-icmp"},
+		{132, "sctp"},
+		{253, "proto-253"},
+	}
+	for _, test := range tests {
+		if name := ProtocolName(test.Number); name != test.Name {
+			t.Errorf("ProtocolName(%d): expected %q, got %q", test.Number, test.Name, name)
+		}
+		if n, ok := ProtocolNumber(test.Name); !ok || n != test.Number {
+			t.Errorf("ProtocolNumber(%q): expected %d, got %d (%t)", test.Name, test.Number, n, ok)
+		}
+	}
+
+	if len(protocolNames) < 30 {
+		t.Errorf("expected at least 30 known protocols, got %d", len(protocolNames))
+	}
+	for _, name := range []string{"", "bogus", "proto-256", "proto-x"} {
+		if n, ok := ProtocolNumber(name); ok {
+			t.Errorf("ProtocolNumber(%q): expected unknown, got %d", name, n)
+		}
+	}
+}
+
+func TestICMPName(t *testing.T) {
+	var tests = []struct {
+		Type, Code uint8
+		Name       string
+	}{
+		{8, 0, "echo-request"},
+		{0, 0, "echo-reply"},
+		{3, 3, "destination-unreachable/3"},
+		{200, 1, "icmp-200/1"},
+	}
+	for _, test := range tests {
+		if name := ICMPName(test.Type, test.Code); name != test.Name {
+			t.Errorf("ICMPName(%d, %d): expected %q, got %q", test.Type, test.Code, test.Name, name)
+		}
+	}
+}
@@ -3,34 +3,115 @@ package read
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 )
 
+// ErrShortPacket is returned if a packet holds less data than announced by
+// its header.
+var ErrShortPacket = errors.New("short packet")
+
+// ErrInvalidLength is returned if a length field in a packet is inconsistent
+// with the structure it describes.
+var ErrInvalidLength = errors.New("invalid length")
+
+// ErrUnknownTemplate is returned if a data set references a template that has
+// not been announced.
+var ErrUnknownTemplate = errors.New("unknown template")
+
+// Full reads exactly len(p) bytes, if less bytes are available, the returned
+// error wraps ErrShortPacket.
+func Full(p []byte, r io.Reader) error {
+	_, err := FullN(p, r)
+	return err
+}
+
+// FullN is like Full, but also returns the number of bytes read.
+func FullN(p []byte, r io.Reader) (int, error) {
+	n, err := io.ReadFull(r, p)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return n, errShort(len(p), n)
+	}
+	return n, err
+}
+
+// Need checks if b holds at least n bytes, if not, the returned error wraps
+// ErrShortPacket.
+func Need(b []byte, n int) error {
+	if len(b) < n {
+		return errShort(n, len(b))
+	}
+	return nil
+}
+
+// ShortReadError is returned if less bytes are available than needed. It
+// matches ErrShortPacket with errors.Is.
+type ShortReadError struct {
+	// Expected is the number of bytes needed.
+	Expected int
+	// Available is the number of bytes that were available.
+	Available int
+	// Offset is where the data ran out. It is relative to the start of the
+	// read, unless the decoder knows the position in the packet.
+	Offset int
+	// Field describes the field at Offset, if known.
+	Field string
+}
+
+// Missing returns the number of bytes that were missing.
+func (e *ShortReadError) Missing() int {
+	return e.Expected - e.Available
+}
+
+func (e *ShortReadError) Error() string {
+	s := fmt.Sprintf("%v: expected %d bytes, got %d", ErrShortPacket, e.Expected, e.Available)
+	if e.Field != "" {
+		s += fmt.Sprintf(" (ran out at offset %d in %s)", e.Offset, e.Field)
+	}
+	return s
+}
+
+// Is reports whether target is ErrShortPacket.
+func (e *ShortReadError) Is(target error) bool {
+	return target == ErrShortPacket
+}
+
+func errShort(expected, got int) error {
+	return &ShortReadError{Expected: expected, Available: got, Offset: got}
+}
+
 // Uint8 reads a single byte
 func Uint8(v *uint8, r io.Reader) error {
 	var b [1]byte
-	_, err := io.ReadFull(r, b[:])
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return err
+	}
 	*v = b[0]
-	return err
+	return nil
 }
 
 // Uint16 reads an unsigned word
 func Uint16(v *uint16, r io.Reader) error {
 	var b [2]byte
-	_, err := io.ReadFull(r, b[:])
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return err
+	}
 	*v = binary.BigEndian.Uint16(b[:])
-	return err
+	return nil
 }
 
 // Uint32 reads an unsigned dword
 func Uint32(v *uint32, r io.Reader) error {
 	var b [4]byte
-	_, err := io.ReadFull(r, b[:])
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return err
+	}
 	*v = binary.BigEndian.Uint32(b[:])
-	return err
+	return nil
 }
 
-// Uint32IPv4 reads an unsigned dword as IP address
+// Uint32IPv4 reads a big endian unsigned dword as IP address
 func Uint32IPv4(v *LongIPv4, r io.Reader) error {
 	var u uint32
 	if err := Uint32(&u, r); err != nil {
@@ -40,46 +121,84 @@ func Uint32IPv4(v *LongIPv4, r io.Reader) error {
 	return nil
 }
 
+// IPv6 reads 16 bytes as IP address
+func IPv6(v *LongIPv6, r io.Reader) error {
+	var b LongIPv6
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return err
+	}
+	*v = b
+	return nil
+}
+
 // Uint64 reads an unsigned quad word
 func Uint64(v *uint64, r io.Reader) error {
 	var b [8]byte
-	_, err := io.ReadFull(r, b[:])
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return err
+	}
 	*v = binary.BigEndian.Uint64(b[:])
-	return err
+	return nil
 }
 
 // VariableLength reads a variable length byte stream as per RFC 7011 section 7.
 func VariableLength(p []byte, r io.Reader) ([]byte, error) {
-	var l0 uint8
-	if err := Uint8(&l0, r); err != nil {
+	l, err := variableLengthPrefix(r)
+	if err != nil {
 		return nil, err
 	}
 
-	var l int
-	if l0 < 0xff {
-		l = int(l0)
-	} else {
-		var l1 uint16
-		if err := Uint16(&l1, r); err != nil {
-			return nil, err
-		}
-		l = int(l1)
-	}
-
 	var b = p
 	if cap(b) < l {
 		// Allocate new slice for p if there it not enough capacity
 		b = make([]byte, l)
-	} else if len(b) < l {
-		// Grow the passed slice using current capacity
-		for i := len(b); i < l; i++ {
-			b = append(b, 0x00)
-		}
+	} else {
+		// Reuse the passed slice using current capacity
+		b = b[:l]
 	}
 
-	if _, err := r.Read(b); err != nil {
+	if _, err := io.ReadFull(r, b); err != nil {
 		return b, err
 	}
 
 	return b, nil
 }
+
+// variableLengthPrefix reads the length prefix of a variable length field.
+func variableLengthPrefix(r io.Reader) (int, error) {
+	var l0 uint8
+	if err := Uint8(&l0, r); err != nil {
+		return 0, err
+	}
+	if l0 < 0xff {
+		return int(l0), nil
+	}
+	var l1 uint16
+	if err := Uint16(&l1, r); err != nil {
+		return 0, err
+	}
+	return int(l1), nil
+}
+
+// Skip discards exactly n bytes from the reader. If the reader has less than
+// n bytes, io.ErrUnexpectedEOF is returned, or io.EOF if it had none.
+func Skip(r io.Reader, n int) error {
+	c, err := io.CopyN(io.Discard, r, int64(n))
+	if err == io.EOF && c > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// SkipVariableLength discards a variable length byte stream as per RFC 7011
+// section 7.
+func SkipVariableLength(r io.Reader) error {
+	l, err := variableLengthPrefix(r)
+	if err != nil {
+		return err
+	}
+	if err = Skip(r, l); err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package read
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestVariableLength(t *testing.T) {
+	long := bytes.Repeat([]byte{'x'}, 300)
+
+	var tests = []struct {
+		Name  string
+		Input []byte
+		Want  []byte
+	}{
+		{"short", []byte{0x04, 'h', 'o', 's', 't'}, []byte("host")},
+		{"escaped", append([]byte{0xff, 0x01, 0x2c}, long...), long},
+		{"zero", []byte{0x00}, []byte{}},
+	}
+	for _, test := range tests {
+		r := bytes.NewReader(append(test.Input, 0xaa))
+		b, err := VariableLength(nil, r)
+		if err != nil {
+			t.Errorf("%s: %v", test.Name, err)
+			continue
+		}
+		if !bytes.Equal(b, test.Want) {
+			t.Errorf("%s: expected %q, got %q", test.Name, test.Want, b)
+		}
+		// The trailing byte must not have been consumed
+		if r.Len() != 1 {
+			t.Errorf("%s: expected 1 byte left in reader, got %d", test.Name, r.Len())
+		}
+	}
+}
+
+func TestVariableLengthReuse(t *testing.T) {
+	p := make([]byte, 16)
+	b, err := VariableLength(p, bytes.NewReader([]byte{0x02, 'o', 'k'}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "ok" {
+		t.Fatalf("expected %q, got %q", "ok", b)
+	}
+}
+
+func TestVariableLengthShort(t *testing.T) {
+	if _, err := VariableLength(nil, bytes.NewReader([]byte{0x04, 'h', 'o'})); err == nil {
+		t.Fatal("expected error reading truncated field")
+	}
+}
+
+func TestSkip(t *testing.T) {
+	r := bytes.NewReader([]byte{0x01, 0x02, 0x03, 0x02, 'o', 'k', 0xaa})
+	if err := Skip(r, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := SkipVariableLength(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 1 {
+		t.Fatalf("expected 1 byte left in reader, got %d", r.Len())
+	}
+	if err := Skip(r, 2); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if err := Skip(r, 1); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+	if err := SkipVariableLength(bytes.NewReader([]byte{0x04, 'h', 'o'})); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestNeed(t *testing.T) {
+	if err := Need(make([]byte, 4), 4); err != nil {
+		t.Fatal(err)
+	}
+	err := Need(make([]byte, 3), 4)
+	if !errors.Is(err, ErrShortPacket) {
+		t.Fatalf("expected short packet error, got %v", err)
+	}
+	if err.Error() != "short packet: expected 4 bytes, got 3" {
+		t.Fatalf("unexpected error %q", err)
+	}
+}
+
+var testFields = []byte{
+	0x12,       // uint8
+	0x12, 0x34, // uint16
+	0x12, 0x34, 0x56, 0x78, // uint32
+	0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, // uint64
+	0xc0, 0x00, 0x02, 0x01, // IPv4
+}
+
+func TestReadOneByte(t *testing.T) {
+	r := iotest.OneByteReader(bytes.NewReader(testFields))
+
+	var (
+		u8  uint8
+		u16 uint16
+		u32 uint32
+		u64 uint64
+		ip  LongIPv4
+	)
+	for _, err := range []error{
+		Uint8(&u8, r),
+		Uint16(&u16, r),
+		Uint32(&u32, r),
+		Uint64(&u64, r),
+		Uint32IPv4(&ip, r),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if u8 != 0x12 || u16 != 0x1234 || u32 != 0x12345678 || u64 != 0x0102030405060708 {
+		t.Errorf("unexpected values %#x %#x %#x %#x", u8, u16, u32, u64)
+	}
+	if ip.String() != "192.0.2.1" {
+		t.Errorf("expected 192.0.2.1, got %s", ip)
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	var tests = []struct {
+		Name string
+		Read func(io.Reader) error
+	}{
+		{"Uint16", func(r io.Reader) error { var v uint16; return Uint16(&v, r) }},
+		{"Uint32", func(r io.Reader) error { var v uint32; return Uint32(&v, r) }},
+		{"Uint64", func(r io.Reader) error { var v uint64; return Uint64(&v, r) }},
+		{"Uint32IPv4", func(r io.Reader) error { var v LongIPv4; return Uint32IPv4(&v, r) }},
+		{"IPv6", func(r io.Reader) error { var v LongIPv6; return IPv6(&v, r) }},
+	}
+	for _, test := range tests {
+		r := iotest.OneByteReader(bytes.NewReader([]byte{0x01}))
+		if err := test.Read(r); err != io.ErrUnexpectedEOF {
+			t.Errorf("%s: expected io.ErrUnexpectedEOF, got %v", test.Name, err)
+		}
+	}
+
+	v := uint32(42)
+	if err := Uint32(&v, bytes.NewReader([]byte{0x01, 0x02})); err == nil || v != 42 {
+		t.Errorf("expected value to be left alone on error, got %d (%v)", v, err)
+	}
+}
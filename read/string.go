@@ -2,6 +2,7 @@ package read
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -22,6 +23,8 @@ const (
 	tcpFlags = "NCEUAPRSF"
 )
 
+var tcpFlagNames = []string{"FIN", "SYN", "RST", "PSH", "ACK", "URG", "ECE", "CWR"}
+
 var protocol = map[uint8]string{}
 
 func init() {
@@ -38,7 +41,7 @@ func init() {
 			if len(fields) < 2 {
 				continue
 			}
-			if n, err := strconv.Atoi(fields[1]); err == nil {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n >= 0 && n <= 0xff {
 				protocol[uint8(n)] = fields[0]
 			}
 		}
@@ -63,3 +66,61 @@ func TCPFlags(f uint8) string {
 	}
 	return "[" + string(flags) + "]"
 }
+
+// TCPFlagNames returns the names of the set TCP flags, separated by a comma
+func TCPFlagNames(f uint8) string {
+	return DecodeTCPFlags(f).String()
+}
+
+// TCPFlagSet is a decoded set of TCP flags
+type TCPFlagSet uint8
+
+// DecodeTCPFlags decodes the TCP flags field of a flow record
+func DecodeTCPFlags(f uint8) TCPFlagSet {
+	return TCPFlagSet(f)
+}
+
+// ParseTCPFlags parses a comma separated list of TCP flag names, as returned
+// by TCPFlagSet.String
+func ParseTCPFlags(s string) (uint8, error) {
+	var f uint8
+	if s == "" {
+		return f, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		var found bool
+		name = strings.ToUpper(strings.TrimSpace(name))
+		for i, flag := range tcpFlagNames {
+			if name == flag {
+				f |= 1 << uint(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("read: unknown TCP flag %q", name)
+		}
+	}
+	return f, nil
+}
+
+func (f TCPFlagSet) FIN() bool { return f&tcpFIN > 0 }
+func (f TCPFlagSet) SYN() bool { return f&tcpSYN > 0 }
+func (f TCPFlagSet) RST() bool { return f&tcpRST > 0 }
+func (f TCPFlagSet) PSH() bool { return f&tcpPSH > 0 }
+func (f TCPFlagSet) ACK() bool { return f&tcpACK > 0 }
+func (f TCPFlagSet) URG() bool { return f&tcpURG > 0 }
+func (f TCPFlagSet) ECE() bool { return f&tcpECE > 0 }
+func (f TCPFlagSet) CWR() bool { return f&tcpCWR > 0 }
+
+// String returns the names of the set flags, in bit order and separated by a
+// comma, such as "SYN,ACK"
+func (f TCPFlagSet) String() string {
+	names := []string{}
+	for i, name := range tcpFlagNames {
+		if f&(1<<uint(i)) > 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,43 @@
+package read
+
+import "testing"
+
+func TestTCPFlagSet(t *testing.T) {
+	var tests = []struct {
+		Flags uint8
+		Names string
+	}{
+		{0x00, ""},
+		{0xff, "FIN,SYN,RST,PSH,ACK,URG,ECE,CWR"},
+		{0x12, "SYN,ACK"},
+		{0x11, "FIN,ACK"},
+		{0x18, "PSH,ACK"},
+	}
+	for _, test := range tests {
+		if s := DecodeTCPFlags(test.Flags).String(); s != test.Names {
+			t.Errorf("%#02x: expected %q, got %q", test.Flags, test.Names, s)
+		}
+		if f, err := ParseTCPFlags(test.Names); err != nil {
+			t.Errorf("%q: %v", test.Names, err)
+		} else if f != test.Flags {
+			t.Errorf("%q: expected %#02x, got %#02x", test.Names, test.Flags, f)
+		}
+	}
+
+	f := DecodeTCPFlags(0x12)
+	if !f.SYN() || !f.ACK() {
+		t.Error("expected SYN and ACK to be set")
+	}
+	if f.FIN() || f.RST() || f.PSH() || f.URG() || f.ECE() || f.CWR() {
+		t.Error("expected only SYN and ACK to be set")
+	}
+
+	all := DecodeTCPFlags(0xff)
+	if !(all.FIN() && all.SYN() && all.RST() && all.PSH() && all.ACK() && all.URG() && all.ECE() && all.CWR()) {
+		t.Error("expected all flags to be set")
+	}
+
+	if _, err := ParseTCPFlags("SYN,BOGUS"); err == nil {
+		t.Error("expected error parsing unknown flag")
+	}
+}
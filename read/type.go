@@ -1,15 +1,76 @@
 package read
 
-import "net"
+import (
+	"net"
+	"net/netip"
+)
 
-// LongIPv4 is a 32 bit packed IPv4 address.
+// LongIPv4 is a 32 bit packed IPv4 address, the most significant byte is the
+// first octet of the address, as in network byte order.
 type LongIPv4 uint32
 
-func (l LongIPv4) String() string {
+// To4 returns the address as a 4 byte net.IP
+func (l LongIPv4) To4() net.IP {
 	return net.IP{
 		uint8(l >> 24),
 		uint8(l >> 16),
 		uint8(l >> 8),
 		uint8(l),
-	}.String()
+	}
+}
+
+// Addr returns the address as netip.Addr
+func (l LongIPv4) Addr() netip.Addr {
+	return netip.AddrFrom4([4]byte{
+		uint8(l >> 24),
+		uint8(l >> 16),
+		uint8(l >> 8),
+		uint8(l),
+	})
+}
+
+func (l LongIPv4) String() string {
+	return l.To4().String()
+}
+
+// IPv4Prefix returns the network of the address with the given prefix length,
+// with the host bits masked off. Prefix lengths above 32 are treated as 32.
+func IPv4Prefix(ip net.IP, bits uint8) net.IPNet {
+	if bits > 32 {
+		bits = 32
+	}
+	mask := net.CIDRMask(int(bits), 32)
+	ip4 := ip.To4()
+	if ip4 == nil {
+		ip4 = net.IPv4zero.To4()
+	}
+	return net.IPNet{IP: ip4.Mask(mask), Mask: mask}
+}
+
+// Addr converts ip to netip.Addr. IPv4-mapped IPv6 addresses are returned as
+// plain IPv4 addresses, so both forms compare equal. An invalid ip returns the
+// zero Addr.
+func Addr(ip net.IP) netip.Addr {
+	a, _ := netip.AddrFromSlice(ip)
+	return a.Unmap()
+}
+
+// LongIPv6 is a 128 bit packed IPv6 address.
+type LongIPv6 [16]byte
+
+// IP returns the address as net.IP
+func (l LongIPv6) IP() net.IP {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, l[:])
+	return ip
+}
+
+// Addr returns the address as netip.Addr, IPv4-mapped addresses are returned
+// as plain IPv4 addresses.
+func (l LongIPv6) Addr() netip.Addr {
+	return netip.AddrFrom16(l).Unmap()
+}
+
+func (l LongIPv6) String() string {
+	return net.IP(l[:]).String()
 }
@@ -0,0 +1,101 @@
+package read
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestLongIPv4(t *testing.T) {
+	ip := LongIPv4(0xc0a80101)
+	if s := ip.String(); s != "192.168.1.1" {
+		t.Fatalf("expected 192.168.1.1, got %s", s)
+	}
+	if b := ip.To4(); len(b) != net.IPv4len || !b.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Fatalf("expected 4 byte 192.168.1.1, got %v", []byte(b))
+	}
+
+	// The on-wire representation is big endian
+	if err := Uint32IPv4(&ip, bytes.NewReader([]byte{10, 0, 0, 254})); err != nil {
+		t.Fatal(err)
+	}
+	if s := ip.String(); s != "10.0.0.254" {
+		t.Fatalf("expected 10.0.0.254, got %s", s)
+	}
+}
+
+func TestIPv4Prefix(t *testing.T) {
+	var tests = []struct {
+		IP   net.IP
+		Bits uint8
+		Want string
+	}{
+		{net.IPv4(10, 1, 2, 3), 24, "10.1.2.0/24"},
+		{net.IP{10, 1, 2, 3}, 0, "0.0.0.0/0"},
+		{net.IPv4(10, 1, 2, 3), 32, "10.1.2.3/32"},
+		{net.IPv4(10, 1, 2, 3), 40, "10.1.2.3/32"},
+		{net.IPv4(172, 16, 255, 1), 12, "172.16.0.0/12"},
+		{nil, 8, "0.0.0.0/8"},
+	}
+	for _, test := range tests {
+		n := IPv4Prefix(test.IP, test.Bits)
+		if s := n.String(); s != test.Want {
+			t.Errorf("%s/%d: expected %s, got %s", test.IP, test.Bits, test.Want, s)
+		}
+	}
+}
+
+func TestIPv6(t *testing.T) {
+	addr := net.ParseIP("2001:db8::8a2e:370:7334")
+
+	var ip LongIPv6
+	if err := IPv6(&ip, bytes.NewReader(addr)); err != nil {
+		t.Fatal(err)
+	}
+	if s := ip.String(); s != "2001:db8::8a2e:370:7334" {
+		t.Fatalf("expected 2001:db8::8a2e:370:7334, got %s", s)
+	}
+	if !ip.IP().Equal(addr) {
+		t.Fatalf("expected %s, got %s", addr, ip.IP())
+	}
+
+	if err := IPv6(&ip, bytes.NewReader(addr[:8])); err == nil {
+		t.Fatal("expected error reading truncated address")
+	}
+}
+
+func TestAddr(t *testing.T) {
+	want := netip.MustParseAddr("192.0.2.1")
+
+	if a := LongIPv4(0xc0000201).Addr(); a != want {
+		t.Errorf("LongIPv4: expected %s, got %s", want, a)
+	}
+
+	var mapped LongIPv6
+	copy(mapped[:], net.IPv4(192, 0, 2, 1))
+	if a := mapped.Addr(); a != want {
+		t.Errorf("IPv4-mapped LongIPv6: expected %s, got %s", want, a)
+	}
+
+	var v6 LongIPv6
+	copy(v6[:], net.ParseIP("2001:db8::1"))
+	if a := v6.Addr(); a != netip.MustParseAddr("2001:db8::1") {
+		t.Errorf("LongIPv6: expected 2001:db8::1, got %s", a)
+	}
+
+	var tests = []struct {
+		IP   net.IP
+		Want netip.Addr
+	}{
+		{net.IP{192, 0, 2, 1}, want},
+		{net.IPv4(192, 0, 2, 1), want},
+		{net.ParseIP("2001:db8::1"), netip.MustParseAddr("2001:db8::1")},
+		{nil, netip.Addr{}},
+	}
+	for _, test := range tests {
+		if a := Addr(test.IP); a != test.Want {
+			t.Errorf("Addr(%v): expected %s, got %s", []byte(test.IP), test.Want, a)
+		}
+	}
+}
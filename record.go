@@ -0,0 +1,34 @@
+package netflow
+
+import (
+	"io"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+)
+
+// FlowRecord is implemented by the flow records of all fixed layout NetFlow
+// versions (1, 5, 6, 7 and 8), allowing mixed version records to be handled
+// through a common path.
+type FlowRecord interface {
+	// Len returns the length of the record on the wire in bytes.
+	Len() int
+	String() string
+	Unmarshal(io.Reader) error
+	// AppendBytes appends the wire format of the record to b and returns
+	// the extended slice.
+	AppendBytes(b []byte) []byte
+}
+
+// Test if the fixed layout records are compliant
+var (
+	_ FlowRecord = (*netflow1.FlowRecord)(nil)
+	_ FlowRecord = (*netflow5.FlowRecord)(nil)
+	_ FlowRecord = (*netflow6.FlowRecord)(nil)
+	_ FlowRecord = (*netflow7.FlowRecord)(nil)
+	_ FlowRecord = (*netflow8.ASRecord)(nil)
+	_ FlowRecord = (*netflow8.ProtoPortRecord)(nil)
+)
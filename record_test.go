@@ -0,0 +1,154 @@
+package netflow
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/read"
+)
+
+func TestWireLen(t *testing.T) {
+	var tests = []struct {
+		Name   string
+		Want   int
+		Length int
+		Value  interface {
+			Len() int
+			Unmarshal(r io.Reader) error
+		}
+	}{
+		{"netflow1 header", 16, netflow1.HeaderLen, new(netflow1.PacketHeader)},
+		{"netflow1 record", 48, netflow1.RecordLen, new(netflow1.FlowRecord)},
+		{"netflow5 header", 24, netflow5.HeaderLen, new(netflow5.PacketHeader)},
+		{"netflow5 record", 48, netflow5.RecordLen, new(netflow5.FlowRecord)},
+		{"netflow6 header", 24, netflow6.HeaderLen, new(netflow6.PacketHeader)},
+		{"netflow6 record", 52, netflow6.RecordLen, new(netflow6.FlowRecord)},
+		{"netflow7 header", 24, netflow7.HeaderLen, new(netflow7.PacketHeader)},
+		{"netflow7 record", 52, netflow7.RecordLen, new(netflow7.FlowRecord)},
+		{"netflow9 header", 20, netflow9.HeaderLen, new(netflow9.PacketHeader)},
+	}
+
+	for _, test := range tests {
+		if test.Length != test.Want {
+			t.Errorf("%s: expected constant %d, got %d", test.Name, test.Want, test.Length)
+		}
+		if l := test.Value.Len(); l != test.Length {
+			t.Errorf("%s: expected Len() %d, got %d", test.Name, test.Length, l)
+		}
+
+		// Unmarshal must consume exactly the announced length. The header
+		// count, at the same offset in all versions, is set to one.
+		data := make([]byte, test.Length+1)
+		data[3] = 1
+		r := bytes.NewReader(data)
+		if err := test.Value.Unmarshal(r); err != nil {
+			t.Errorf("%s: %v", test.Name, err)
+		} else if r.Len() != 1 {
+			t.Errorf("%s: expected %d bytes to be consumed, got %d", test.Name, test.Length, test.Length+1-r.Len())
+		}
+	}
+}
+
+func TestAppendBytes(t *testing.T) {
+	var tests = []struct {
+		Name    string
+		Record  FlowRecord
+		Decoded FlowRecord
+	}{
+		{"netflow1", &netflow1.FlowRecord{
+			SrcAddr: net.IP{192, 0, 2, 1}, DstAddr: net.IP{192, 0, 2, 2}, NextHop: net.IP{192, 0, 2, 3},
+			Input: 1, Output: 2, Packets: 3, Bytes: 4, First: 5, Last: 6, SrcPort: 7, DstPort: 8,
+			Pad1: 9, Protocol: 10, ToS: 11, Flags: 12, Pad2: 13, Pad3: 14, Reserved: 15,
+		}, new(netflow1.FlowRecord)},
+		{"netflow5", &netflow5.FlowRecord{
+			SrcAddr: net.IP{192, 0, 2, 1}, DstAddr: net.IP{192, 0, 2, 2}, NextHop: net.IP{192, 0, 2, 3},
+			Input: 1, Output: 2, Packets: 3, Bytes: 4, First: 5, Last: 6, SrcPort: 7, DstPort: 8,
+			Pad1: 9, TCPFlags: 10, Protocol: 11, ToS: 12, SrcAS: 13, DstAS: 14, SrcMask: 15, DstMask: 16, Pad2: 17,
+		}, new(netflow5.FlowRecord)},
+		{"netflow7", &netflow7.FlowRecord{
+			SrcAddr: net.IP{192, 0, 2, 1}, DstAddr: net.IP{192, 0, 2, 2}, NextHop: net.IP{192, 0, 2, 3},
+			Input: 1, Output: 2, Packets: 3, Bytes: 4, First: 5, Last: 6, SrcPort: 7, DstPort: 8,
+			Pad1: 9, TCPFlags: 10, Protocol: 11, ToS: 12, SrcAS: 13, DstAS: 14, SrcMask: 15, DstMask: 16, Flags: 17,
+			RouterSC: net.IP{192, 0, 2, 4},
+		}, new(netflow7.FlowRecord)},
+		{"netflow8 AS", &netflow8.ASRecord{
+			Flows: 1, Packets: 2, Bytes: 3, First: 4, Last: 5, SrcAS: 6, DstAS: 7, Input: 8, Output: 9,
+		}, new(netflow8.ASRecord)},
+		{"netflow8 ProtoPort", &netflow8.ProtoPortRecord{
+			Flows: 1, Packets: 2, Bytes: 3, First: 4, Last: 5, Protocol: 6, Pad1: 7, Reserved: 8, SrcPort: 9, DstPort: 10,
+		}, new(netflow8.ProtoPortRecord)},
+	}
+
+	for _, test := range tests {
+		b := test.Record.AppendBytes(nil)
+		if len(b) != test.Record.Len() {
+			t.Errorf("%s: expected %d bytes, got %d", test.Name, test.Record.Len(), len(b))
+		}
+		if err := test.Decoded.Unmarshal(bytes.NewReader(b)); err != nil {
+			t.Fatalf("%s: %v", test.Name, err)
+		}
+		if !reflect.DeepEqual(test.Record, test.Decoded) {
+			t.Errorf("%s: expected %+v, got %+v", test.Name, test.Record, test.Decoded)
+		}
+	}
+}
+
+func TestWriteToReadFrom(t *testing.T) {
+	type record interface {
+		Len() int
+		io.WriterTo
+		io.ReaderFrom
+	}
+	var tests = []struct {
+		Name    string
+		Record  record
+		Decoded record
+	}{
+		{"netflow1", &netflow1.FlowRecord{SrcAddr: net.IP{192, 0, 2, 1}, DstAddr: net.IP{192, 0, 2, 2}, NextHop: net.IP{192, 0, 2, 3}, SrcPort: 80},
+			new(netflow1.FlowRecord)},
+		{"netflow5", &netflow5.FlowRecord{SrcAddr: net.IP{192, 0, 2, 1}, DstAddr: net.IP{192, 0, 2, 2}, NextHop: net.IP{192, 0, 2, 3}, SrcPort: 80},
+			new(netflow5.FlowRecord)},
+		{"netflow6", &netflow6.FlowRecord{SrcAddr: net.IP{192, 0, 2, 1}, DstAddr: net.IP{192, 0, 2, 2}, NextHop: net.IP{192, 0, 2, 3}, SrcPort: 80, Pad3: 4},
+			new(netflow6.FlowRecord)},
+		{"netflow7", &netflow7.FlowRecord{SrcAddr: net.IP{192, 0, 2, 1}, DstAddr: net.IP{192, 0, 2, 2}, NextHop: net.IP{192, 0, 2, 3}, SrcPort: 80, RouterSC: net.IP{192, 0, 2, 4}},
+			new(netflow7.FlowRecord)},
+	}
+
+	for _, test := range tests {
+		b := new(bytes.Buffer)
+		n, err := test.Record.WriteTo(b)
+		if err != nil {
+			t.Fatalf("%s: %v", test.Name, err)
+		}
+		if int(n) != test.Record.Len() || b.Len() != test.Record.Len() {
+			t.Errorf("%s: expected %d bytes written, got %d (%d)", test.Name, test.Record.Len(), n, b.Len())
+		}
+
+		b.WriteByte(0xff)
+		if n, err = test.Decoded.ReadFrom(b); err != nil {
+			t.Fatalf("%s: %v", test.Name, err)
+		}
+		if int(n) != test.Record.Len() || b.Len() != 1 {
+			t.Errorf("%s: expected %d bytes read, got %d", test.Name, test.Record.Len(), n)
+		}
+		if !reflect.DeepEqual(test.Record, test.Decoded) {
+			t.Errorf("%s: expected %+v, got %+v", test.Name, test.Record, test.Decoded)
+		}
+
+		// A short read reports the bytes consumed.
+		n, err = test.Decoded.ReadFrom(bytes.NewReader(make([]byte, 10)))
+		if !errors.Is(err, read.ErrShortPacket) || n != 10 {
+			t.Errorf("%s: expected short packet after 10 bytes, got %d (%v)", test.Name, n, err)
+		}
+	}
+}
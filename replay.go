@@ -0,0 +1,216 @@
+package netflow
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow8"
+	"github.com/tehmaze/netflow/netflow9"
+)
+
+// Framing is the way datagrams are stored in a file, see FileReplay.
+type Framing int
+
+// Supported framings.
+const (
+	// FrameSelfDescribing are datagrams concatenated without any framing,
+	// such as extracted pcap payloads. The length of each datagram is taken
+	// from its header.
+	FrameSelfDescribing Framing = iota
+	// FrameLengthPrefixed are datagrams each prefixed by their length, as a
+	// 32 bit big endian unsigned integer.
+	FrameLengthPrefixed
+)
+
+func (f Framing) String() string {
+	switch f {
+	case FrameSelfDescribing:
+		return "self-describing"
+	case FrameLengthPrefixed:
+		return "length-prefixed"
+	default:
+		return fmt.Sprintf("framing(%d)", int(f))
+	}
+}
+
+// FileReplay splits a stream of stored datagrams into the individual
+// datagrams, to be fed to Session.DecodePacket.
+type FileReplay struct {
+	r       *bufio.Reader
+	framing Framing
+
+	// sizes are the record sizes of the NetFlow v9 templates seen, by Source
+	// ID and template ID, used to count the records of a packet.
+	sizes map[uint64]int
+}
+
+// NewFileReplay reads datagrams stored using the framing from r.
+func NewFileReplay(r io.Reader, framing Framing) *FileReplay {
+	return &FileReplay{
+		r:       bufio.NewReaderSize(r, MaxDatagramSize+4),
+		framing: framing,
+		sizes:   make(map[uint64]int),
+	}
+}
+
+// Next returns the bytes of the next datagram. When the stream is exhausted,
+// io.EOF is returned; a stream ending halfway a datagram returns
+// io.ErrUnexpectedEOF.
+func (f *FileReplay) Next() ([]byte, error) {
+	var (
+		n   int
+		err error
+	)
+	switch f.framing {
+	case FrameLengthPrefixed:
+		var prefix [4]byte
+		if _, err = io.ReadFull(f.r, prefix[:]); err != nil {
+			return nil, err
+		}
+		if n = int(binary.BigEndian.Uint32(prefix[:])); n > MaxDatagramSize {
+			return nil, fmt.Errorf("netflow: datagram length %d exceeds %d", n, MaxDatagramSize)
+		}
+	case FrameSelfDescribing:
+		if n, err = f.datagramLen(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("netflow: unsupported framing %s", f.framing)
+	}
+
+	b := make([]byte, n)
+	if _, err = io.ReadFull(f.r, b); err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+// datagramLen returns the length of the next datagram, using the record
+// count or message length in its header.
+func (f *FileReplay) datagramLen() (int, error) {
+	h, err := f.r.Peek(4)
+	if err != nil {
+		if err == io.EOF && len(h) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	version, err := DetectVersion(h)
+	if err != nil {
+		return 0, err
+	}
+	switch version {
+	case netflow9.Version:
+		return f.netflow9Len()
+	case netflow8.Version:
+		// The record length depends on the aggregation scheme.
+		if h, err = f.r.Peek(netflow8.HeaderLen); err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+	return ExpectedLength(h)
+}
+
+// netflow9Len walks the FlowSets of a NetFlow v9 packet until all records
+// announced in the header are seen, as the header has no length. A FlowSet ID
+// in the reserved range 2-255, such as the version of the next packet, also
+// ends the packet, as does an Options Template FlowSet that is not valid,
+// which is the header of a NetFlow v1 packet. The records of Data FlowSets for
+// unknown templates can not be counted, so the walk may reach the next packet.
+func (f *FileReplay) netflow9Len() (int, error) {
+	b, err := f.r.Peek(netflow9.HeaderLen)
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	var (
+		count    = int(binary.BigEndian.Uint16(b[2:]))
+		sourceID = uint64(binary.BigEndian.Uint32(b[16:])) << 16
+		n        = netflow9.HeaderLen
+		records  int
+	)
+	for records < count {
+		if b, err = f.r.Peek(n + 4); err != nil {
+			break
+		}
+		id, length := binary.BigEndian.Uint16(b[n:]), int(binary.BigEndian.Uint16(b[n+2:]))
+		if id > 1 && id < 256 {
+			break
+		}
+		if id == netflow1.Version {
+			if b, err = f.r.Peek(n + 10); err != nil || !optionsTemplateFlowSet(b[n:]) {
+				break
+			}
+		}
+		if length < 4 {
+			return 0, fmt.Errorf("netflow: protocol error: flowset %d length %d is too short", id, length)
+		}
+		if b, err = f.r.Peek(n + length); err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		set := b[n+4 : n+length]
+		switch id {
+		case 0: // Template FlowSet
+			for len(set) >= 4 {
+				tid, fields := binary.BigEndian.Uint16(set), int(binary.BigEndian.Uint16(set[2:]))
+				if len(set) < 4+fields*4 {
+					break
+				}
+				f.sizes[sourceID|uint64(tid)] = templateSize(set[4 : 4+fields*4])
+				set = set[4+fields*4:]
+				records++
+			}
+		case 1: // Options Template FlowSet
+			for len(set) >= 6 {
+				tid := binary.BigEndian.Uint16(set)
+				fields := int(binary.BigEndian.Uint16(set[2:])+binary.BigEndian.Uint16(set[4:])) / 4
+				if len(set) < 6+fields*4 {
+					break
+				}
+				f.sizes[sourceID|uint64(tid)] = templateSize(set[6 : 6+fields*4])
+				set = set[6+fields*4:]
+				records++
+			}
+		default: // Data FlowSet
+			if size := f.sizes[sourceID|uint64(id)]; size > 0 {
+				records += len(set) / size
+			} else {
+				records++
+			}
+		}
+		n += length
+	}
+	return n, nil
+}
+
+// optionsTemplateFlowSet reports whether b starts with a plausible Options
+// Template FlowSet: the first Options Template Record has a template ID of at
+// least 256, and field specifiers that fit in the FlowSet.
+func optionsTemplateFlowSet(b []byte) bool {
+	if len(b) < 10 {
+		return false
+	}
+	var (
+		length      = int(binary.BigEndian.Uint16(b[2:]))
+		tid         = binary.BigEndian.Uint16(b[4:])
+		scopeLength = int(binary.BigEndian.Uint16(b[6:]))
+		optLength   = int(binary.BigEndian.Uint16(b[8:]))
+	)
+	return tid >= 256 && scopeLength%4 == 0 && optLength%4 == 0 && 10+scopeLength+optLength <= length
+}
+
+// templateSize returns the record size for the field specifiers, or 0 if
+// any field is of variable length.
+func templateSize(fields []byte) int {
+	var size int
+	for i := 0; i+4 <= len(fields); i += 4 {
+		length := binary.BigEndian.Uint16(fields[i+2:])
+		if length == netflow9.VariableLength {
+			return 0
+		}
+		size += int(length)
+	}
+	return size
+}
@@ -0,0 +1,141 @@
+package netflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow9"
+)
+
+func TestFileReplay(t *testing.T) {
+	var (
+		stream = new(bytes.Buffer)
+		want   [][]byte
+	)
+	for i := 0; i < 3; i++ {
+		b := testPacketV9Records(t, i+1)
+		binary.BigEndian.PutUint32(b[12:], uint32(i+1)) // SequenceNumber
+		want = append(want, b)
+		stream.Write(b)
+	}
+
+	var (
+		f   = NewFileReplay(stream, FrameSelfDescribing)
+		s   = NewSession()
+		src = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+	)
+	for i := range want {
+		b, err := f.Next()
+		if err != nil {
+			t.Fatalf("datagram %d: %v", i, err)
+		}
+		if !bytes.Equal(b, want[i]) {
+			t.Fatalf("datagram %d: expected %x, got %x", i, want[i], b)
+		}
+		p, err := s.DecodePacket(src, b)
+		if err != nil {
+			t.Fatalf("datagram %d: %v", i, err)
+		}
+		if n := recordCount(p); n != i+1 {
+			t.Errorf("datagram %d: expected %d records, got %d", i, i+1, n)
+		}
+	}
+	if _, err := f.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestFileReplayMixed(t *testing.T) {
+	var (
+		stream = new(bytes.Buffer)
+		want   = [][]byte{testPacketV5(2), testPacketV9(), testPacketV5(1)}
+	)
+	for _, b := range want {
+		stream.Write(b)
+	}
+	f := NewFileReplay(stream, FrameSelfDescribing)
+	for i := range want {
+		b, err := f.Next()
+		if err != nil {
+			t.Fatalf("datagram %d: %v", i, err)
+		}
+		if !bytes.Equal(b, want[i]) {
+			t.Fatalf("datagram %d: expected %x, got %x", i, want[i], b)
+		}
+	}
+
+	// A truncated datagram is an error.
+	f = NewFileReplay(bytes.NewReader(testPacketV5(2)[:50]), FrameSelfDescribing)
+	if _, err := f.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestFileReplayV9UnknownTemplate(t *testing.T) {
+	// The records of the Data FlowSet for an unknown template can not be
+	// counted, the walk continues to the NetFlow v1 datagram following it.
+	h := netflow9.PacketHeader{Version: netflow9.Version, Count: 4, SequenceNumber: 1, SourceID: 1}
+	v9 := append(h.AppendBytes(nil),
+		0x00, 0x01, 0x00, 0x14, // options template flowset
+		0x01, 0x01, 0x00, 0x04, // template 257, scope length 4
+		0x00, 0x04, // option length 4
+		0x00, 0x02, 0x00, 0x04, // Interface scope
+		0x00, 0x52, 0x00, 0x20, // interfaceName
+		0x00, 0x00, // padding
+		0x01, 0x2c, 0x00, 0x10, // data flowset for template 300
+		0xde, 0xad, 0xbe, 0xef,
+		0xde, 0xad, 0xbe, 0xef,
+		0xde, 0xad, 0xbe, 0xef,
+	)
+	v1 := testPacketFixed(netflow1.Version, netflow1.HeaderLen, netflow1.RecordLen, 20)
+	binary.BigEndian.PutUint32(v1[4:], 100000)     // SysUptime
+	binary.BigEndian.PutUint32(v1[8:], 1577836800) // UnixSecs
+
+	var (
+		want = [][]byte{v9, v1}
+		f    = NewFileReplay(bytes.NewReader(append(append([]byte(nil), v9...), v1...)), FrameSelfDescribing)
+	)
+	for i := range want {
+		b, err := f.Next()
+		if err != nil {
+			t.Fatalf("datagram %d: %v", i, err)
+		}
+		if !bytes.Equal(b, want[i]) {
+			t.Fatalf("datagram %d: expected %x, got %x", i, want[i], b)
+		}
+	}
+	if _, err := f.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestFileReplayLengthPrefixed(t *testing.T) {
+	var (
+		stream = new(bytes.Buffer)
+		want   = [][]byte{testPacketV5(1), testPacketV9()}
+	)
+	for _, b := range want {
+		var prefix [4]byte
+		binary.BigEndian.PutUint32(prefix[:], uint32(len(b)))
+		stream.Write(prefix[:])
+		stream.Write(b)
+	}
+	f := NewFileReplay(stream, FrameLengthPrefixed)
+	for i := range want {
+		b, err := f.Next()
+		if err != nil {
+			t.Fatalf("datagram %d: %v", i, err)
+		}
+		if !bytes.Equal(b, want[i]) {
+			t.Fatalf("datagram %d: expected %x, got %x", i, want[i], b)
+		}
+	}
+	if _, err := f.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
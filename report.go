@@ -0,0 +1,110 @@
+package netflow
+
+import (
+	"net/netip"
+	"sort"
+
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+)
+
+// SumStats sums the packet and octet counters of the records, and counts the
+// flows. The 32 bit counters of the records are summed in 64 bits, so totals
+// do not overflow. A NetFlow v8 aggregation record counts for the number of
+// flows it aggregates.
+func SumStats(records []FlowRecord) (totalPackets, totalOctets uint64, flowCount int) {
+	for _, r := range records {
+		flows, packets, octets, ok := counters(r)
+		if !ok {
+			continue
+		}
+		totalPackets += packets
+		totalOctets += octets
+		flowCount += int(flows)
+	}
+	return
+}
+
+// TopBy is the counter Top ranks talkers by.
+type TopBy uint8
+
+// Counters to rank by
+const (
+	ByPackets TopBy = iota
+	ByOctets
+)
+
+// Talker is the traffic sent by a source address.
+type Talker struct {
+	Addr    netip.Addr
+	Flows   uint64
+	Packets uint64
+	Octets  uint64
+}
+
+// Top returns the n source addresses sending the most packets or octets, in
+// descending order. Addresses sending the same amount are ordered by address.
+// Records without a source address, such as the NetFlow v8 aggregation
+// records, are ignored.
+func Top(records []FlowRecord, n int, by TopBy) []Talker {
+	var (
+		talkers []Talker
+		index   = make(map[netip.Addr]int)
+	)
+	for _, r := range records {
+		key, ok := KeyOf(r)
+		if !ok {
+			continue
+		}
+		flows, packets, octets, _ := counters(r)
+		i, found := index[key.SrcAddr]
+		if !found {
+			i = len(talkers)
+			index[key.SrcAddr] = i
+			talkers = append(talkers, Talker{Addr: key.SrcAddr})
+		}
+		talkers[i].Flows += flows
+		talkers[i].Packets += packets
+		talkers[i].Octets += octets
+	}
+
+	sort.Slice(talkers, func(i, j int) bool {
+		a, b := talkers[i], talkers[j]
+		if by == ByOctets && a.Octets != b.Octets {
+			return a.Octets > b.Octets
+		}
+		if by == ByPackets && a.Packets != b.Packets {
+			return a.Packets > b.Packets
+		}
+		return a.Addr.Less(b.Addr)
+	})
+	if n < 0 {
+		n = 0
+	}
+	if n < len(talkers) {
+		talkers = talkers[:n]
+	}
+	return talkers
+}
+
+// counters returns the flow, packet and octet counters of a record.
+func counters(r FlowRecord) (flows, packets, octets uint64, ok bool) {
+	switch r := r.(type) {
+	case *netflow1.FlowRecord:
+		return 1, uint64(r.Packets), uint64(r.Bytes), true
+	case *netflow5.FlowRecord:
+		return 1, uint64(r.Packets), uint64(r.Bytes), true
+	case *netflow6.FlowRecord:
+		return 1, uint64(r.Packets), uint64(r.Bytes), true
+	case *netflow7.FlowRecord:
+		return 1, uint64(r.Packets), uint64(r.Bytes), true
+	case *netflow8.ASRecord:
+		return uint64(r.Flows), uint64(r.Packets), uint64(r.Bytes), true
+	case *netflow8.ProtoPortRecord:
+		return uint64(r.Flows), uint64(r.Packets), uint64(r.Bytes), true
+	}
+	return 0, 0, 0, false
+}
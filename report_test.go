@@ -0,0 +1,71 @@
+package netflow
+
+import (
+	"math"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow8"
+)
+
+func TestSumStats(t *testing.T) {
+	records := []FlowRecord{
+		&netflow5.FlowRecord{Packets: math.MaxUint32, Bytes: math.MaxUint32},
+		&netflow5.FlowRecord{Packets: 10, Bytes: math.MaxUint32},
+		&netflow8.ASRecord{Flows: 3, Packets: 5, Bytes: 6000},
+	}
+	packets, octets, flows := SumStats(records)
+	if packets != math.MaxUint32+15 {
+		t.Errorf("expected %d packets, got %d", uint64(math.MaxUint32+15), packets)
+	}
+	if octets != 2*math.MaxUint32+6000 {
+		t.Errorf("expected %d octets, got %d", uint64(2*math.MaxUint32+6000), octets)
+	}
+	if flows != 5 {
+		t.Errorf("expected 5 flows, got %d", flows)
+	}
+}
+
+func TestTop(t *testing.T) {
+	record := func(src byte, packets, bytes uint32) FlowRecord {
+		return &netflow5.FlowRecord{
+			SrcAddr: net.IPv4(192, 0, 2, src),
+			DstAddr: net.IPv4(10, 0, 0, 1),
+			Packets: packets,
+			Bytes:   bytes,
+		}
+	}
+	records := []FlowRecord{
+		record(1, 100, 1000),
+		record(2, 50, 75000),
+		record(3, 10, 500),
+		record(1, 20, 200),
+		record(4, 50, 100),
+		&netflow8.ASRecord{Flows: 1, Packets: 1000, Bytes: 1000000},
+	}
+
+	top := Top(records, 3, ByPackets)
+	want := []netip.Addr{
+		netip.MustParseAddr("192.0.2.1"), // 120 packets in 2 flows
+		netip.MustParseAddr("192.0.2.2"), // 50 packets, before .4 by address
+		netip.MustParseAddr("192.0.2.4"),
+	}
+	if len(top) != len(want) {
+		t.Fatalf("expected %d talkers, got %v", len(want), top)
+	}
+	for i, talker := range top {
+		if talker.Addr != want[i] {
+			t.Errorf("%d: expected %s, got %s", i, want[i], talker.Addr)
+		}
+	}
+	if top[0].Flows != 2 || top[0].Packets != 120 || top[0].Octets != 1200 {
+		t.Errorf("expected summed counters for %s, got %+v", top[0].Addr, top[0])
+	}
+
+	top = Top(records, 10, ByOctets)
+	if len(top) != 4 || top[0].Addr != netip.MustParseAddr("192.0.2.2") || top[3].Addr != netip.MustParseAddr("192.0.2.4") {
+		t.Errorf("unexpected top talkers by octets %v", top)
+	}
+}
@@ -0,0 +1,200 @@
+package netflow
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/session"
+)
+
+// Sender sends datagrams, for example built with MarshalPacket or ReExport,
+// at a fixed rate, to load test collectors. The datagrams are sent in order,
+// starting over after the last one; the sequence numbers in their headers are
+// rewritten to keep advancing, so the collector sees no gaps.
+type Sender struct {
+	conn      net.Conn
+	datagrams [][]byte
+	rate      float64
+	jitter    float64
+	random    *rand.Rand
+
+	// increments are the sequence number increments of the datagrams, next
+	// the next sequence number by version and sent the number of datagrams
+	// sent so far.
+	increments []uint32
+	next       map[uint16]uint32
+	sent       int
+}
+
+// SenderOption configures a Sender.
+type SenderOption func(*Sender)
+
+// WithSendRate sends pps datagrams per second. By default, or if pps is zero,
+// datagrams are sent as fast as possible.
+func WithSendRate(pps float64) SenderOption {
+	return func(s *Sender) {
+		s.rate = pps
+	}
+}
+
+// WithSendJitter randomly shifts the time each datagram is sent by up to the
+// fraction of the interval between two datagrams, for example 0.5 sends each
+// datagram up to half an interval early or late. The average rate is kept.
+func WithSendJitter(fraction float64) SenderOption {
+	return func(s *Sender) {
+		s.jitter = fraction
+	}
+}
+
+// SendStats are the results of Sender.Send.
+type SendStats struct {
+	// Sent is the number of datagrams sent.
+	Sent int
+	// Errors is the number of datagrams that could not be sent, Err is the
+	// first error.
+	Errors int
+	Err    error
+	// Elapsed is the time spent sending.
+	Elapsed time.Duration
+}
+
+// Rate returns the achieved number of datagrams sent per second.
+func (st SendStats) Rate() float64 {
+	if st.Elapsed <= 0 {
+		return 0
+	}
+	return float64(st.Sent) / st.Elapsed.Seconds()
+}
+
+// NewSender sets up a sender of the datagrams to conn, typically a connected
+// UDP socket.
+func NewSender(conn net.Conn, datagrams [][]byte, opts ...SenderOption) *Sender {
+	s := &Sender{
+		conn:       conn,
+		datagrams:  datagrams,
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		increments: sequenceIncrements(datagrams),
+		next:       make(map[uint16]uint32),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Send sends n datagrams, or stops early when ctx is done, in which case the
+// error of the context is returned. Errors sending a datagram do not stop
+// sending, they are reported in the stats. A following Send continues with
+// the next datagram and sequence number.
+func (s *Sender) Send(ctx context.Context, n int) (SendStats, error) {
+	var (
+		stats    SendStats
+		start    = time.Now()
+		interval time.Duration
+	)
+	if s.rate > 0 {
+		interval = time.Duration(float64(time.Second) / s.rate)
+	}
+
+	for i := 0; i < n && len(s.datagrams) > 0; i++ {
+		if err := ctx.Err(); err != nil {
+			stats.Elapsed = time.Since(start)
+			return stats, err
+		}
+		if interval > 0 {
+			// Datagrams are scheduled from the start, so the time spent
+			// sending does not lower the rate.
+			at := start.Add(time.Duration(i) * interval)
+			if s.jitter > 0 {
+				at = at.Add(time.Duration(s.jitter * float64(interval) * (2*s.random.Float64() - 1)))
+			}
+			timer := time.NewTimer(time.Until(at))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				stats.Elapsed = time.Since(start)
+				return stats, ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		b := s.sequenced(s.sent % len(s.datagrams))
+		s.sent++
+		if _, err := s.conn.Write(b); err != nil {
+			stats.Errors++
+			if stats.Err == nil {
+				stats.Err = err
+			}
+			continue
+		}
+		stats.Sent++
+	}
+	stats.Elapsed = time.Since(start)
+	return stats, nil
+}
+
+// sequenced returns datagram k to send, with its sequence number set.
+func (s *Sender) sequenced(k int) []byte {
+	b := s.datagrams[k]
+	if len(b) < 4 {
+		return b
+	}
+	var (
+		version = binary.BigEndian.Uint16(b)
+		offset  = 16 // FlowSequence of the fixed layout versions
+	)
+	switch version {
+	case netflow1.Version:
+		return b
+	case netflow9.Version:
+		offset = 12
+	case ipfix.Version:
+		offset = 8
+	}
+	if len(b) < offset+4 {
+		return b
+	}
+
+	seq, ok := s.next[version]
+	if !ok {
+		seq = binary.BigEndian.Uint32(b[offset:])
+	}
+	s.next[version] = seq + s.increments[k]
+
+	c := append([]byte(nil), b...)
+	binary.BigEndian.PutUint32(c[offset:], seq)
+	return c
+}
+
+// sequenceIncrements returns how much each datagram advances the sequence
+// number: one for NetFlow v9, the number of (data) records otherwise. The
+// IPFIX data records are counted by decoding the datagrams in order, so the
+// templates are known.
+func sequenceIncrements(datagrams [][]byte) []uint32 {
+	var (
+		increments = make([]uint32, len(datagrams))
+		d          = NewDecoder(session.New())
+	)
+	for i, b := range datagrams {
+		if len(b) < 4 {
+			continue
+		}
+		switch binary.BigEndian.Uint16(b) {
+		case netflow9.Version:
+			increments[i] = 1
+		case ipfix.Version:
+			if p, err := d.DecodeBytes(b); err == nil {
+				increments[i] = uint32(ipfixRecordCount(p.Message.(*ipfix.Message)))
+			}
+		default:
+			increments[i] = uint32(binary.BigEndian.Uint16(b[2:]))
+		}
+	}
+	return increments
+}
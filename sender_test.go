@@ -0,0 +1,82 @@
+package netflow
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSender(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conn, err := net.DialUDP("udp", nil, l.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	received := make(chan []uint32, 1)
+	go func() {
+		var (
+			seqs []uint32
+			b    = make([]byte, MaxDatagramSize)
+		)
+		for {
+			l.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+			n, err := l.Read(b)
+			if err != nil {
+				received <- seqs
+				return
+			}
+			if n >= 20 {
+				seqs = append(seqs, binary.BigEndian.Uint32(b[16:]))
+			}
+		}
+	}()
+
+	s := NewSender(conn, [][]byte{testPacketV5(2), testPacketV5(3)}, WithSendRate(200), WithSendJitter(0.5))
+	stats, err := s.Send(context.Background(), 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sent != 50 || stats.Errors != 0 {
+		t.Fatalf("expected 50 datagrams sent without errors, got %+v", stats)
+	}
+	// 50 datagrams at 200 per second take a quarter of a second.
+	if rate := stats.Rate(); rate < 100 || rate > 300 {
+		t.Errorf("expected a rate of about 200 datagrams per second, got %.1f", rate)
+	}
+
+	seqs := <-received
+	if len(seqs) < 45 {
+		t.Fatalf("expected about 50 datagrams received, got %d", len(seqs))
+	}
+	for i := 1; i < len(seqs); i++ {
+		if d := seqs[i] - seqs[i-1]; d != 2 && d != 3 && d != 5 {
+			t.Errorf("datagram %d: sequence %d does not follow %d", i, seqs[i], seqs[i-1])
+		}
+	}
+	if seqs[0] != 42 {
+		t.Errorf("expected the first sequence number of the datagram, got %d", seqs[0])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = s.Send(ctx, 1); err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestSenderShortDatagram(t *testing.T) {
+	s := NewSender(nil, [][]byte{{}, {0x00}, {0x00, 0x05, 0x00}})
+	for k, want := range s.datagrams {
+		if b := s.sequenced(k); string(b) != string(want) {
+			t.Errorf("datagram %d: expected %x unchanged, got %x", k, want, b)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package netflow
+
+import (
+	"net"
+	"sync"
+
+	"github.com/tehmaze/netflow/netflow9"
+)
+
+// SequenceIncrement determines how the sequence number in the packet header
+// advances between two consecutive packets.
+type SequenceIncrement int
+
+const (
+	// IncrementRecords advances the sequence number by the number of records
+	// in the packet, used by NetFlow v5, v6, v7, v8 and IPFIX.
+	IncrementRecords SequenceIncrement = iota
+	// IncrementPackets advances the sequence number by one for every packet,
+	// used by NetFlow v9.
+	IncrementPackets
+)
+
+// SequenceIncrementFor returns the SequenceIncrement used by the version.
+func SequenceIncrementFor(version uint16) SequenceIncrement {
+	if version == netflow9.Version {
+		return IncrementPackets
+	}
+	return IncrementRecords
+}
+
+// SequenceTracker detects gaps in the sequence numbers of the packets sent by
+// exporters, indicating lost packets. It is safe for concurrent use.
+type SequenceTracker struct {
+	// Increment determines how the sequence number advances.
+	Increment SequenceIncrement
+
+	mutex sync.Mutex
+	next  map[sequenceKey]*sequenceState
+}
+
+// sequenceKey identifies a sequence of an exporter, every NetFlow v9 Source
+// ID and IPFIX Observation Domain has its own sequence.
+type sequenceKey struct {
+	source string
+	domain uint32
+}
+
+// maxSequenceGaps is the number of reported gaps remembered per sequence, for
+// late packets to fill.
+const maxSequenceGaps = 16
+
+// sequenceState is the next sequence number expected in a sequence, along
+// with the gaps reported earlier and not filled by late packets yet.
+type sequenceState struct {
+	next uint32
+	gaps []sequenceGap
+}
+
+// sequenceGap is a range of sequence numbers reported as missed.
+type sequenceGap struct {
+	start, length uint32
+}
+
+// fill removes the sequence numbers of a late packet from the gaps, and
+// returns how many of them were reported as missed.
+func (s *sequenceState) fill(seq, count uint32) (filled int64) {
+	gaps := s.gaps[:0]
+	for _, g := range s.gaps {
+		// Offsets relative to the start of the gap, modulo 2^32.
+		lo := int64(int32(seq - g.start))
+		hi := lo + int64(count)
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > int64(g.length) {
+			hi = int64(g.length)
+		}
+		if hi <= lo {
+			gaps = append(gaps, g)
+			continue
+		}
+		filled += hi - lo
+		if lo > 0 {
+			gaps = append(gaps, sequenceGap{g.start, uint32(lo)})
+		}
+		if hi < int64(g.length) {
+			gaps = append(gaps, sequenceGap{g.start + uint32(hi), g.length - uint32(hi)})
+		}
+	}
+	s.gaps = gaps
+	return filled
+}
+
+// NewSequenceTracker sets up a tracker for sequence numbers advancing by inc.
+func NewSequenceTracker(inc SequenceIncrement) *SequenceTracker {
+	return &SequenceTracker{
+		Increment: inc,
+		next:      make(map[sequenceKey]*sequenceState),
+	}
+}
+
+// Observe records the sequence number seq of a packet with count records
+// from source. It returns the number of records (or packets, depending on the
+// Increment) missed since the previous packet from that source, taking into
+// account wrap around of the 32 bit sequence number.
+//
+// The first packet of a source has no gap. A packet arriving out of order
+// returns minus the number of records it holds that were reported missed
+// earlier, so the sum of the gaps is the net loss. Duplicated packets, and
+// late packets older than the last 16 reported gaps, return no gap.
+func (t *SequenceTracker) Observe(source net.Addr, seq uint32, count uint32) (gap int64) {
+	return t.ObserveDomain(source, 0, seq, count)
+}
+
+// ObserveDomain is like Observe, for exporters sending multiple sequences
+// from one address. The sequence of every domain, such as the NetFlow v9
+// Source ID or the IPFIX Observation Domain ID, is tracked separately.
+func (t *SequenceTracker) ObserveDomain(source net.Addr, domain uint32, seq uint32, count uint32) (gap int64) {
+	if t.Increment == IncrementPackets {
+		count = 1
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	key := sequenceKey{source.String(), domain}
+	state, ok := t.next[key]
+	if !ok {
+		t.next[key] = &sequenceState{next: seq + count}
+		return 0
+	}
+
+	// The difference is computed modulo 2^32, so wrap around is handled.
+	gap = int64(int32(seq - state.next))
+	if gap < 0 {
+		// Late packet, keep expecting the next sequence number.
+		return -state.fill(seq, count)
+	}
+	if gap > 0 {
+		if len(state.gaps) == maxSequenceGaps {
+			state.gaps = append(state.gaps[:0], state.gaps[1:]...)
+		}
+		state.gaps = append(state.gaps, sequenceGap{state.next, uint32(gap)})
+	}
+	state.next = seq + count
+	return gap
+}
@@ -0,0 +1,74 @@
+package netflow
+
+import (
+	"net"
+	"testing"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow9"
+)
+
+func TestSequenceTracker(t *testing.T) {
+	var (
+		a = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+		b = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 2055}
+		c = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 3), Port: 2055}
+	)
+
+	var tests = []struct {
+		Name   string
+		Source net.Addr
+		Seq    uint32
+		Count  uint32
+		Gap    int64
+	}{
+		{"first", a, 100, 30, 0},
+		{"increment", a, 130, 30, 0},
+		{"other source", b, 5000, 10, 0},
+		{"gap", a, 190, 30, 30},
+		{"late", a, 160, 30, -30},
+		{"duplicate", a, 160, 30, 0},
+		{"duplicate current", a, 190, 30, 0},
+		{"older", a, 100, 30, 0},
+		{"after late", a, 220, 10, 0},
+		{"partial gap", a, 250, 10, 20},
+		{"partial late", a, 235, 10, -10},
+		{"partial late rest", a, 225, 30, -10},
+		{"before wrap", c, 0xffffffe0, 0x10, 0},
+		{"wrap", c, 0xfffffff0, 0x20, 0},
+		{"wrapped", c, 0x10, 0x10, 0},
+		{"wrapped gap", c, 0x30, 1, 0x10},
+		{"other source increment", b, 5010, 10, 0},
+	}
+
+	tr := NewSequenceTracker(IncrementRecords)
+	for _, test := range tests {
+		if gap := tr.Observe(test.Source, test.Seq, test.Count); gap != test.Gap {
+			t.Errorf("%s: expected gap %d, got %d", test.Name, test.Gap, gap)
+		}
+	}
+}
+
+func TestSequenceTrackerPackets(t *testing.T) {
+	src := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+	tr := NewSequenceTracker(IncrementPackets)
+	for i, want := range []int64{0, 0, 2} {
+		seq := []uint32{0xffffffff, 0, 3}[i]
+		if gap := tr.Observe(src, seq, 20); gap != want {
+			t.Errorf("packet %d: expected gap %d, got %d", i, want, gap)
+		}
+	}
+}
+
+func TestSequenceIncrementFor(t *testing.T) {
+	for version, want := range map[uint16]SequenceIncrement{
+		netflow5.Version: IncrementRecords,
+		netflow9.Version: IncrementPackets,
+		ipfix.Version:    IncrementRecords,
+	} {
+		if inc := SequenceIncrementFor(version); inc != want {
+			t.Errorf("version %d: expected %d, got %d", version, want, inc)
+		}
+	}
+}
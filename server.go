@@ -0,0 +1,330 @@
+package netflow
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaxDatagramSize is the maximum size of a NetFlow datagram.
+const MaxDatagramSize = 65535
+
+// ErrServerClosed is returned by the Server's ListenAndServe and Serve methods
+// after a call to Shutdown.
+var ErrServerClosed = errors.New("netflow: server closed")
+
+// Handler is called for every decoded packet received by a Server.
+type Handler func(src net.Addr, p *Packet) error
+
+// Stats are the counters kept by a Server, as returned by Server.Stats.
+type Stats struct {
+	// Packets is the number of datagrams received.
+	Packets uint64
+	// Bytes is the number of bytes received.
+	Bytes uint64
+	// Records is the number of flow records decoded, including NetFlow v9
+	// and IPFIX data records.
+	Records uint64
+	// Errors is the number of datagrams that could not be decoded, not
+	// counting the unsupported versions.
+	Errors uint64
+	// UnsupportedVersions is the number of datagrams with a version that can
+	// not be decoded.
+	UnsupportedVersions uint64
+	// Dropped is the number of datagrams dropped because all workers were
+	// busy, see WithDropWhenFull.
+	Dropped uint64
+	// RateLimited is the number of datagrams dropped because their source
+	// exceeded its rate limit, see WithPerSourceRateLimit.
+	RateLimited uint64
+}
+
+// Server is a NetFlow collector, receiving packets from UDP datagrams. Every
+// exporting source gets its own Decoder and template session, see Session.
+type Server struct {
+	// Accessed atomically, kept first for 64 bit alignment on 32 bit
+	// platforms.
+	stats Stats
+
+	// Addr is the UDP address to listen on.
+	Addr string
+	// Handler is called for every decoded packet.
+	Handler Handler
+	// ErrorHandler is called, if set, for every datagram that could not be
+	// decoded and for every error returned by the Handler.
+	ErrorHandler func(src net.Addr, err error)
+
+	mutex   sync.Mutex
+	conn    net.PacketConn
+	closed  bool
+	session *Session
+	buffers *sync.Pool
+	logger  Logger
+	workers int
+	drop    bool
+	limiter *rateLimiter
+	options []DecoderOption
+}
+
+// datagram is a received datagram queued for a worker.
+type datagram struct {
+	src      net.Addr
+	buf      []byte
+	n        int
+	received time.Time
+}
+
+// ServerOption configures a Server.
+type ServerOption func(*Server)
+
+// WithLogger sends the diagnostic messages of the Server and its decoders to
+// l. Datagrams that can not be decoded are logged at debug level, along with
+// their source and the first bytes of the datagram. By default, nothing is
+// logged.
+func WithLogger(l Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// WithWorkers decodes and handles datagrams on a pool of n goroutines, while
+// datagrams are read on the goroutine calling Serve. Up to n datagrams are
+// queued for the workers; once the queue is full, reading waits for a worker
+// unless WithDropWhenFull is used. Datagrams from the same source may be
+// handled out of order, so the Handler must be safe for concurrent use. By
+// default, datagrams are handled one at a time on the reading goroutine.
+func WithWorkers(n int) ServerOption {
+	return func(s *Server) {
+		s.workers = n
+	}
+}
+
+// WithDropWhenFull drops datagrams when the queue of the workers is full,
+// instead of waiting for a worker. Dropped datagrams are counted in
+// Stats.Dropped. It has no effect without WithWorkers.
+func WithDropWhenFull(drop bool) ServerOption {
+	return func(s *Server) {
+		s.drop = drop
+	}
+}
+
+// WithPerSourceRateLimit limits the number of datagrams handled per second for
+// every source, using a token bucket that allows bursts of up to pps
+// datagrams. Datagrams exceeding the limit are dropped before decoding and
+// counted in Stats.RateLimited, without affecting other sources. Sources are
+// told apart by address and port, as for the Session. The limits of the
+// least recently seen sources are forgotten once more than 4096 sources are
+// seen. By default, datagrams are not rate limited.
+func WithPerSourceRateLimit(pps int) ServerOption {
+	return func(s *Server) {
+		s.limiter = nil
+		if pps > 0 {
+			s.limiter = newRateLimiter(pps, maxRateLimitSources)
+		}
+	}
+}
+
+// WithDecoderOptions applies the options to the Decoder created for every
+// source, for example WithTemplateTTL. The Decoders log to the logger of the
+// Server.
+func WithDecoderOptions(opts ...DecoderOption) ServerOption {
+	return func(s *Server) {
+		s.options = append(s.options, opts...)
+	}
+}
+
+// NewServer sets up a collector for the given listen address.
+func NewServer(addr string, opts ...ServerOption) *Server {
+	s := &Server{
+		Addr: addr,
+		buffers: &sync.Pool{
+			New: func() interface{} {
+				return make([]byte, MaxDatagramSize)
+			},
+		},
+		logger: nopLogger{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.session = NewSession(append(s.options, WithDecoderLogger(s.logger))...)
+	return s
+}
+
+// ListenAndServe listens on the UDP address and handles incoming datagrams,
+// until an error occurs or the Server is shut down.
+func (s *Server) ListenAndServe() error {
+	return s.ListenAndServeContext(context.Background())
+}
+
+// ListenAndServeContext is like ListenAndServe, but also stops when the
+// context is cancelled, in which case ctx.Err() is returned.
+func (s *Server) ListenAndServeContext(ctx context.Context) error {
+	addr, err := net.ResolveUDPAddr("udp", s.Addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeContext(ctx, conn)
+}
+
+// Serve handles incoming datagrams on the provided connection, until an error
+// occurs or the Server is shut down. The connection is closed when Serve
+// returns.
+func (s *Server) Serve(conn net.PacketConn) error {
+	return s.ServeContext(context.Background(), conn)
+}
+
+// ServeContext is like Serve, but also stops when the context is cancelled,
+// in which case ctx.Err() is returned. A datagram that is being handled when
+// the context is cancelled is handled to completion first.
+func (s *Server) ServeContext(ctx context.Context, conn net.PacketConn) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		conn.Close()
+		return ErrServerClosed
+	}
+	s.conn = conn
+	s.mutex.Unlock()
+	defer conn.Close()
+
+	if done := ctx.Done(); done != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-done:
+				// Expire the read deadline to wake up the read loop.
+				conn.SetReadDeadline(time.Now())
+			case <-stop:
+			}
+		}()
+	}
+
+	var queue chan datagram
+	if s.workers > 0 {
+		var wg sync.WaitGroup
+		queue = make(chan datagram, s.workers)
+		for i := 0; i < s.workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for d := range queue {
+					s.handle(d.src, d.buf[:d.n], d.received)
+					s.buffers.Put(d.buf)
+				}
+			}()
+		}
+		defer func() {
+			close(queue)
+			wg.Wait()
+		}()
+	}
+
+	for {
+		buf := s.buffers.Get().([]byte)
+		n, src, err := conn.ReadFrom(buf)
+		if err != nil {
+			s.buffers.Put(buf)
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		received := time.Now()
+		if s.limiter != nil && !s.limiter.allow(src.String(), received) {
+			atomic.AddUint64(&s.stats.RateLimited, 1)
+			s.logger.Debugf("netflow: %s: rate limited, dropped %d bytes", src, n)
+			s.buffers.Put(buf)
+			continue
+		}
+		if queue == nil {
+			s.handle(src, buf[:n], received)
+			s.buffers.Put(buf)
+			continue
+		}
+		s.dispatch(queue, datagram{src: src, buf: buf, n: n, received: received})
+	}
+}
+
+// dispatch queues a datagram for the workers.
+func (s *Server) dispatch(queue chan<- datagram, d datagram) {
+	if !s.drop {
+		queue <- d
+		return
+	}
+	select {
+	case queue <- d:
+	default:
+		atomic.AddUint64(&s.stats.Dropped, 1)
+		s.logger.Debugf("netflow: %s: queue full, dropped %d bytes", d.src, d.n)
+		s.buffers.Put(d.buf)
+	}
+}
+
+// Shutdown stops the Server, closing its connection.
+func (s *Server) Shutdown() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
+// Stats returns a snapshot of the counters of the Server.
+func (s *Server) Stats() Stats {
+	return Stats{
+		Packets:             atomic.LoadUint64(&s.stats.Packets),
+		Bytes:               atomic.LoadUint64(&s.stats.Bytes),
+		Records:             atomic.LoadUint64(&s.stats.Records),
+		Errors:              atomic.LoadUint64(&s.stats.Errors),
+		UnsupportedVersions: atomic.LoadUint64(&s.stats.UnsupportedVersions),
+		Dropped:             atomic.LoadUint64(&s.stats.Dropped),
+		RateLimited:         atomic.LoadUint64(&s.stats.RateLimited),
+	}
+}
+
+func (s *Server) handle(src net.Addr, data []byte, received time.Time) {
+	atomic.AddUint64(&s.stats.Packets, 1)
+	atomic.AddUint64(&s.stats.Bytes, uint64(len(data)))
+
+	p, err := s.session.DecodePacket(src, data)
+	switch {
+	case errors.Is(err, ErrUnsupportedVersion):
+		atomic.AddUint64(&s.stats.UnsupportedVersions, 1)
+		s.logger.Debugf("netflow: %s: %v: %s", src, err, dump(data))
+	case err != nil:
+		atomic.AddUint64(&s.stats.Errors, 1)
+		s.logger.Debugf("netflow: %s: %v: %s", src, err, dump(data))
+	default:
+		atomic.AddUint64(&s.stats.Records, uint64(recordCount(p)))
+		p.Meta = PacketMeta{SourceAddr: src, ReceivedAt: received, DatagramLen: len(data)}
+	}
+
+	if err == nil && s.Handler != nil {
+		if err = s.Handler(src, p); err != nil {
+			s.logger.Warnf("netflow: %s: handler: %v", src, err)
+		}
+	}
+	if err != nil && s.ErrorHandler != nil {
+		s.ErrorHandler(src, err)
+	}
+}
@@ -0,0 +1,521 @@
+package netflow
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/netflow5"
+)
+
+// testPacketV5 builds a NetFlow v5 datagram with count records, each record
+// having a distinct source port.
+func testPacketV5(count int) []byte {
+	b := make([]byte, 24+count*48)
+	binary.BigEndian.PutUint16(b[0:], netflow5.Version)
+	binary.BigEndian.PutUint16(b[2:], uint16(count))
+	binary.BigEndian.PutUint32(b[4:], 100000)     // SysUptime
+	binary.BigEndian.PutUint32(b[8:], 1577836800) // UnixSecs
+	binary.BigEndian.PutUint32(b[16:], 42)        // FlowSequence
+	for i := 0; i < count; i++ {
+		r := b[24+i*48:]
+		copy(r[0:], []byte{192, 168, 1, byte(i + 1)}) // SrcAddr
+		copy(r[4:], []byte{10, 0, 0, 1})              // DstAddr
+		binary.BigEndian.PutUint32(r[16:], 10)        // Packets
+		binary.BigEndian.PutUint32(r[20:], 1500)      // Bytes
+		binary.BigEndian.PutUint16(r[32:], uint16(1024+i))
+		binary.BigEndian.PutUint16(r[34:], 80)
+		r[38] = 6 // Protocol
+	}
+	return b
+}
+
+// testServer starts a Server on a loopback socket.
+func testServer(t *testing.T, s *Server) net.Conn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(conn)
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestServer(t *testing.T) {
+	var (
+		packets = make(chan *Packet, 1)
+		errs    = make(chan error, 1)
+		s       = NewServer("127.0.0.1:0")
+	)
+	s.Handler = func(src net.Addr, p *Packet) error {
+		packets <- p
+		return nil
+	}
+	s.ErrorHandler = func(src net.Addr, err error) {
+		errs <- err
+	}
+	defer s.Shutdown()
+
+	client := testServer(t, s)
+	defer client.Close()
+
+	// A malformed datagram must not stop the read loop
+	if _, err := client.Write([]byte{0x00, 0x05, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for decode error")
+	}
+
+	if _, err := client.Write(testPacketV5(3)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-packets:
+		if len(p.Records) != 3 {
+			t.Fatalf("expected 3 records, got %d", len(p.Records))
+		}
+		for i, r := range p.Records {
+			fr := r.(*netflow5.FlowRecord)
+			if fr.SrcPort != uint16(1024+i) || !fr.SrcAddr.Equal(net.IPv4(192, 168, 1, byte(i+1))) {
+				t.Errorf("record %d: unexpected %s", i, fr)
+			}
+		}
+	case err := <-errs:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for packet")
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	s := NewServer("127.0.0.1:0")
+	done := make(chan error)
+	go func() {
+		done <- s.ListenAndServe()
+	}()
+
+	// Wait for the server to start listening
+	for i := 0; i < 100; i++ {
+		s.mutex.Lock()
+		listening := s.conn != nil
+		s.mutex.Unlock()
+		if listening {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Shutdown()
+	select {
+	case err := <-done:
+		if err != ErrServerClosed {
+			t.Fatalf("expected ErrServerClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for shutdown")
+	}
+}
+
+func TestServerContext(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		handled     = make(chan struct{})
+		done        = make(chan error)
+		s           = NewServer("")
+	)
+	defer cancel()
+	s.Handler = func(src net.Addr, p *Packet) error {
+		close(handled)
+		return nil
+	}
+	go func() {
+		done <- s.ServeContext(ctx, conn)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err = client.Write(testPacketV5(1)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for packet")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for ServeContext to return")
+	}
+}
+
+func TestServerListenAndServeContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- NewServer("127.0.0.1:0").ListenAndServeContext(ctx)
+	}()
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for ListenAndServeContext to return")
+	}
+}
+
+func TestServerStats(t *testing.T) {
+	var (
+		done = make(chan struct{}, 4)
+		s    = NewServer("127.0.0.1:0")
+	)
+	s.Handler = func(src net.Addr, p *Packet) error {
+		done <- struct{}{}
+		return nil
+	}
+	s.ErrorHandler = func(src net.Addr, err error) {
+		done <- struct{}{}
+	}
+	defer s.Shutdown()
+
+	client := testServer(t, s)
+	defer client.Close()
+
+	datagrams := [][]byte{
+		testPacketV5(3),
+		{0x00, 0x05, 0x00},       // truncated header
+		{0x00, 0x63, 0x00, 0x01}, // version 99
+		testPacketV5(2),
+	}
+	var size uint64
+	for _, b := range datagrams {
+		if _, err := client.Write(b); err != nil {
+			t.Fatal(err)
+		}
+		size += uint64(len(b))
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for datagram to be handled")
+		}
+	}
+
+	want := Stats{
+		Packets:             4,
+		Bytes:               size,
+		Records:             5,
+		Errors:              1,
+		UnsupportedVersions: 1,
+	}
+	if stats := s.Stats(); stats != want {
+		t.Fatalf("expected %+v, got %+v", want, stats)
+	}
+}
+
+// testLogger records the messages logged at each level.
+type testLogger struct {
+	debug, warn, error []string
+}
+
+func (l *testLogger) Debugf(format string, args ...interface{}) {
+	l.debug = append(l.debug, fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Warnf(format string, args ...interface{}) {
+	l.warn = append(l.warn, fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Errorf(format string, args ...interface{}) {
+	l.error = append(l.error, fmt.Sprintf(format, args...))
+}
+
+func TestServerLogger(t *testing.T) {
+	var (
+		l   = new(testLogger)
+		s   = NewServer("127.0.0.1:0", WithLogger(l))
+		src = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+	)
+
+	s.handle(src, testPacketV5(1), time.Now())
+	if len(l.debug) != 0 {
+		t.Fatalf("expected no messages for a valid packet, got %q", l.debug)
+	}
+
+	s.handle(src, []byte{0x00, 0x05, 0x00}, time.Now()) // truncated header
+	if len(l.debug) != 1 {
+		t.Fatalf("expected 1 debug message, got %q", l.debug)
+	}
+	if msg := l.debug[0]; !strings.Contains(msg, "192.0.2.1:2055") || !strings.HasSuffix(msg, ": 000500") {
+		t.Errorf("expected source address and hex dump, got %q", msg)
+	}
+
+	// Large datagrams are truncated in the log.
+	s.handle(src, append([]byte{0x00, 0x63}, make([]byte, 1000)...), time.Now())
+	if len(l.debug) != 2 {
+		t.Fatalf("expected 2 debug messages, got %q", l.debug)
+	}
+	if msg := l.debug[1]; !strings.HasSuffix(msg, "...") || len(msg) > 256 {
+		t.Errorf("expected truncated hex dump, got %q", msg)
+	}
+
+	s.Handler = func(net.Addr, *Packet) error { return errors.New("test") }
+	s.handle(src, testPacketV5(1), time.Now())
+	if len(l.warn) != 1 {
+		t.Fatalf("expected 1 warning for the handler error, got %q", l.warn)
+	}
+
+	// The default logger discards messages.
+	NewServer("127.0.0.1:0").handle(src, []byte{0x00}, time.Now())
+}
+
+func TestServerPacketMeta(t *testing.T) {
+	var (
+		packets = make(chan *Packet, 1)
+		s       = NewServer("127.0.0.1:0")
+	)
+	s.Handler = func(src net.Addr, p *Packet) error {
+		packets <- p
+		return nil
+	}
+	defer s.Shutdown()
+
+	client := testServer(t, s)
+	defer client.Close()
+
+	data := append(testPacketV5(2), 0x00, 0x00)
+	before := time.Now()
+	if _, err := client.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-packets:
+		if a := p.Meta.SourceAddr; a == nil || a.String() != client.LocalAddr().String() {
+			t.Errorf("expected source %s, got %v", client.LocalAddr(), a)
+		}
+		if at := p.Meta.ReceivedAt; at.Before(before) || at.After(time.Now()) {
+			t.Errorf("expected receive time after %s, got %s", before, at)
+		}
+		if p.Meta.DatagramLen != len(data) {
+			t.Errorf("expected datagram length %d, got %d", len(data), p.Meta.DatagramLen)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for packet")
+	}
+}
+
+func TestServerWorkers(t *testing.T) {
+	const workers = 4
+	var (
+		mutex   sync.Mutex
+		active  int
+		busy    = make(chan struct{})
+		handled = make(chan struct{}, workers)
+		s       = NewServer("127.0.0.1:0", WithWorkers(workers))
+	)
+	s.Handler = func(src net.Addr, p *Packet) error {
+		// Block until all workers are handling a packet at the same time.
+		mutex.Lock()
+		if active++; active == workers {
+			close(busy)
+		}
+		mutex.Unlock()
+		<-busy
+		handled <- struct{}{}
+		return nil
+	}
+	defer s.Shutdown()
+
+	client := testServer(t, s)
+	defer client.Close()
+
+	for i := 0; i < workers; i++ {
+		if _, err := client.Write(testPacketV5(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < workers; i++ {
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for packet %d to be handled in parallel", i)
+		}
+	}
+}
+
+func TestServerDropWhenFull(t *testing.T) {
+	var (
+		handling = make(chan struct{}, 8)
+		release  = make(chan struct{})
+		s        = NewServer("127.0.0.1:0", WithWorkers(1), WithDropWhenFull(true))
+	)
+	s.Handler = func(src net.Addr, p *Packet) error {
+		handling <- struct{}{}
+		<-release
+		return nil
+	}
+	defer s.Shutdown()
+
+	client := testServer(t, s)
+	defer client.Close()
+
+	// The first datagram occupies the only worker.
+	if _, err := client.Write(testPacketV5(1)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-handling:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for packet to be handled")
+	}
+
+	// The next datagram fills the queue, the others are dropped.
+	for i := 0; i < 4; i++ {
+		if _, err := client.Write(testPacketV5(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for s.Stats().Dropped < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 dropped datagrams, got %d", s.Stats().Dropped)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	select {
+	case <-handling:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for queued packet to be handled")
+	}
+	if stats := s.Stats(); stats.Dropped != 3 {
+		t.Errorf("expected 3 dropped datagrams, got %d", stats.Dropped)
+	}
+}
+
+func TestServerDecoderOptions(t *testing.T) {
+	var (
+		s   = NewServer("127.0.0.1:0", WithDecoderOptions(WithTemplateTTL(time.Minute)))
+		src = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+	)
+	if d := s.session.source(src).decoder; d.templateTTL != time.Minute {
+		t.Fatalf("expected template TTL of 1m0s, got %s", d.templateTTL)
+	}
+}
+
+func TestServerPerSourceRateLimit(t *testing.T) {
+	var (
+		mutex   sync.Mutex
+		handled = make(map[string]int)
+		s       = NewServer("127.0.0.1:0", WithPerSourceRateLimit(5))
+	)
+	s.Handler = func(src net.Addr, p *Packet) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		handled[src.String()]++
+		return nil
+	}
+	defer s.Shutdown()
+
+	noisy := testServer(t, s)
+	defer noisy.Close()
+	quiet, err := net.Dial("udp", noisy.RemoteAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer quiet.Close()
+
+	// A burst above the limit from one source.
+	for i := 0; i < 20; i++ {
+		if _, err := noisy.Write(testPacketV5(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := quiet.Write(testPacketV5(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats := s.Stats()
+		if stats.Packets+stats.RateLimited == 23 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 23 datagrams, got %d handled and %d rate limited", stats.Packets, stats.RateLimited)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if n := handled[quiet.LocalAddr().String()]; n != 3 {
+		t.Errorf("expected 3 datagrams from the quiet source, got %d", n)
+	}
+	// The bucket may have refilled a little during the burst.
+	if n := handled[noisy.LocalAddr().String()]; n < 5 || n > 10 {
+		t.Errorf("expected about 5 datagrams from the noisy source, got %d", n)
+	}
+	if stats := s.Stats(); stats.RateLimited != uint64(20-handled[noisy.LocalAddr().String()]) {
+		t.Errorf("expected the excess datagrams to be counted, got %d", stats.RateLimited)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	var (
+		l   = newRateLimiter(2, 2)
+		now = time.Unix(1577836800, 0)
+	)
+	for i, want := range []bool{true, true, false} {
+		if got := l.allow("a", now); got != want {
+			t.Errorf("datagram %d: expected %t, got %t", i, want, got)
+		}
+	}
+	if !l.allow("a", now.Add(500*time.Millisecond)) {
+		t.Error("expected a token after half a second")
+	}
+	if l.allow("a", now.Add(500*time.Millisecond)) {
+		t.Error("expected no second token after half a second")
+	}
+
+	// Seeing a third source evicts the least recently seen source.
+	l.allow("b", now)
+	l.allow("c", now)
+	if _, ok := l.buckets["a"]; ok || len(l.buckets) != 2 || l.lru.Len() != 2 {
+		t.Errorf("expected a to be evicted, got %d buckets", len(l.buckets))
+	}
+	if !l.allow("a", now) {
+		t.Error("expected an evicted source to start with a full bucket")
+	}
+}
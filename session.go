@@ -0,0 +1,169 @@
+package netflow
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/session"
+)
+
+// Session bundles the state kept for every exporting source: the Decoder with
+// its template session, and the sequence numbers used to detect lost packets.
+// It is safe for concurrent use; packets from different sources are decoded
+// in parallel, packets from the same source are decoded one at a time.
+//
+// The version is detected for every datagram, a source may mix versions, for
+// example while migrating from NetFlow v5 to v9. Sequence numbers are tracked
+// per version, as every version has its own sequence, and per NetFlow v9
+// Source ID or IPFIX Observation Domain.
+type Session struct {
+	mutex     sync.Mutex
+	opts      []DecoderOption
+	sources   map[string]*sourceState
+	sequences map[uint16]*SequenceTracker
+}
+
+type sourceState struct {
+	mutex      sync.Mutex
+	decoder    *Decoder
+	interfaces map[uint32]string
+
+	// Exporter status, see Session.ExporterStatus.
+	addr      net.Addr
+	version   uint16
+	lastSeen  time.Time
+	sequence  uint32
+	domains   map[uint32]bool
+	templates map[exporterTemplate]TemplateInfo
+}
+
+// NewSession sets up an empty Session, the options are applied to the Decoder
+// created for every new source.
+func NewSession(opts ...DecoderOption) *Session {
+	s := &Session{
+		opts:      opts,
+		sources:   make(map[string]*sourceState),
+		sequences: make(map[uint16]*SequenceTracker),
+	}
+	for _, version := range []uint16{netflow5.Version, netflow6.Version, netflow7.Version, netflow8.Version, netflow9.Version, ipfix.Version} {
+		s.sequences[version] = NewSequenceTracker(SequenceIncrementFor(version))
+	}
+	return s
+}
+
+func (s *Session) source(src net.Addr) *sourceState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state, found := s.sources[src.String()]
+	if !found {
+		state = &sourceState{decoder: NewDecoder(session.New(), s.opts...)}
+		s.sources[src.String()] = state
+	}
+	return state
+}
+
+// DecodePacket decodes a single datagram received from src, using the
+// templates previously announced by src. The number of records or packets
+// lost since the previous packet from src is reported in Packet.Missed.
+func (s *Session) DecodePacket(src net.Addr, b []byte) (*Packet, error) {
+	state := s.source(src)
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	p, err := state.decoder.DecodeBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	state.observe(src, p, time.Now())
+	if m, ok := p.Message.(*netflow9.Packet); ok {
+		state.learnInterfaces(m)
+	}
+
+	// The sequence number is observed while decoding is serialized, so the
+	// packets of a source are observed in the order they were decoded.
+	// NetFlow v1 has no sequence numbers.
+	if tracker, ok := s.sequences[p.Header.ProtocolVersion()]; ok {
+		p.Missed = tracker.ObserveDomain(src, headerDomain(p.Header), p.Header.Sequence(), uint32(recordCount(p)))
+	}
+	return p, nil
+}
+
+// headerDomain returns the NetFlow v9 Source ID or IPFIX Observation Domain
+// ID of the header, other versions have a single domain.
+func headerDomain(h Header) uint32 {
+	if d, ok := h.(interface{ Domain() uint32 }); ok {
+		return d.Domain()
+	}
+	return 0
+}
+
+// InterfaceName returns the name of the interface with the SNMP index on the
+// exporter at src, as announced by src in NetFlow v9 options data records
+// holding an interfaceName (82) or interfaceDescription (83) field. The name
+// is preferred over the description.
+func (s *Session) InterfaceName(src net.Addr, ifIndex uint16) (string, bool) {
+	s.mutex.Lock()
+	state, found := s.sources[src.String()]
+	s.mutex.Unlock()
+	if !found {
+		return "", false
+	}
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	name, ok := state.interfaces[uint32(ifIndex)]
+	return name, ok
+}
+
+// Field types used in the options data records announcing interface names.
+const (
+	fieldInputSNMP            uint16 = 10 // INPUT_SNMP, ingressInterface in IPFIX
+	fieldInterfaceName        uint16 = 82 // interfaceName
+	fieldInterfaceDescription uint16 = 83 // interfaceDescription
+)
+
+// learnInterfaces records the interface names of the options data records.
+// The interface is the Interface scope (2), or an ingressInterface (10) field
+// as sent by Flexible NetFlow, either as scope or as option field.
+func (state *sourceState) learnInterfaces(p *netflow9.Packet) {
+	for _, odr := range p.OptionsDataRecords() {
+		var (
+			ifIndex     uint32
+			found       bool
+			name, descr string
+		)
+		for _, f := range odr.ScopeFields {
+			if f.Type == netflow9.ScopeInterface || f.Type == fieldInputSNMP {
+				ifIndex, found = uint32(f.Uint()), true
+			}
+		}
+		for _, f := range odr.OptionFields {
+			switch f.Type {
+			case fieldInputSNMP:
+				if !found {
+					ifIndex, found = uint32(f.Uint()), true
+				}
+			case fieldInterfaceName:
+				name = strings.TrimRight(string(f.Bytes), "\x00 ")
+			case fieldInterfaceDescription:
+				descr = strings.TrimRight(string(f.Bytes), "\x00 ")
+			}
+		}
+		if name == "" {
+			name = descr
+		}
+		if !found || name == "" {
+			continue
+		}
+		if state.interfaces == nil {
+			state.interfaces = make(map[uint32]string)
+		}
+		state.interfaces[ifIndex] = name
+	}
+}
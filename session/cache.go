@@ -0,0 +1,191 @@
+package session
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+type templateKey struct {
+	source string
+	domain uint32
+	id     uint16
+}
+
+// sessionKey identifies the Session of a source and domain.
+type sessionKey struct {
+	source string
+	domain uint32
+}
+
+// TemplateCache keeps track of templates for multiple exporters. Template IDs
+// are scoped per exporter source and observation domain (the NetFlow v9
+// Source ID or IPFIX Observation Domain ID), because different devices, and
+// different exporting processes on one device, can reuse the same template ID
+// with different layouts. It is safe for concurrent use.
+//
+// Templates can expire if they are not sent again within a time to live, see
+// WithTTL. Exporters send their templates periodically, a template that is not
+// refreshed may no longer be valid, for example after the device reloaded.
+type TemplateCache struct {
+	mutex     sync.RWMutex
+	templates map[templateKey]cachedTemplate
+	sizes     map[templateKey]int
+	ttl       time.Duration
+	now       func() time.Time
+
+	// Used by the Lock and Unlock methods of the Session views, one per
+	// source and domain so different exporters are decoded concurrently
+	locks map[sessionKey]*sync.Mutex
+}
+
+type cachedTemplate struct {
+	Template
+	added time.Time
+}
+
+// NewTemplateCache sets up an empty template cache.
+func NewTemplateCache() *TemplateCache {
+	return &TemplateCache{
+		templates: make(map[templateKey]cachedTemplate),
+		sizes:     make(map[templateKey]int),
+		locks:     make(map[sessionKey]*sync.Mutex),
+		now:       time.Now,
+	}
+}
+
+// WithTTL expires the templates that are not added again within ttl after they
+// were last added. An expired template is not found, so the data referencing
+// it is treated as data for an unknown template until the exporter sends the
+// template again. Expired templates are evicted when looked up, or by Expire.
+// A ttl of zero, the default, keeps templates forever.
+func (c *TemplateCache) WithTTL(ttl time.Duration) *TemplateCache {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.ttl = ttl
+	return c
+}
+
+// Add a template for the given source and domain. If the domain already
+// defined a template with the same ID, the previous definition is replaced.
+// Adding a template refreshes its time to live.
+func (c *TemplateCache) Add(source net.Addr, domain uint32, templateID uint16, t Template) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.templates[templateKey{source.String(), domain, templateID}] = cachedTemplate{t, c.now()}
+}
+
+// Lookup a template for the given source and domain. An expired template is
+// evicted and not found.
+func (c *TemplateCache) Lookup(source net.Addr, domain uint32, templateID uint16) (t Template, found bool) {
+	k := templateKey{source.String(), domain, templateID}
+	c.mutex.RLock()
+	ct, found := c.templates[k]
+	expired := found && c.expired(ct)
+	c.mutex.RUnlock()
+	if !expired {
+		return ct.Template, found
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// The template may have been added again in the meantime.
+	if ct, found = c.templates[k]; found && !c.expired(ct) {
+		return ct.Template, true
+	}
+	c.evict(k)
+	return nil, false
+}
+
+// Expire evicts all expired templates and returns the number evicted. It can
+// be called periodically to free the templates of exporters that went away.
+func (c *TemplateCache) Expire() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var n int
+	for k, ct := range c.templates {
+		if c.expired(ct) {
+			c.evict(k)
+			n++
+		}
+	}
+	return n
+}
+
+func (c *TemplateCache) expired(ct cachedTemplate) bool {
+	return c.ttl > 0 && c.now().Sub(ct.added) >= c.ttl
+}
+
+// evict removes a template with its record size, the caller must hold the
+// write lock.
+func (c *TemplateCache) evict(k templateKey) {
+	delete(c.templates, k)
+	delete(c.sizes, k)
+}
+
+// Session returns a Session for a single source, backed by the cache. It can
+// be passed to the NetFlow version 9 and IPFIX decoders, which scope it to
+// the domain of every packet. Used as is, it holds the templates of domain 0.
+func (c *TemplateCache) Session(source net.Addr) Session {
+	return c.session(source, 0)
+}
+
+// session returns the Session of the source and domain, sharing its lock
+// with the other Sessions of the source and domain.
+func (c *TemplateCache) session(source net.Addr, domain uint32) *cacheSession {
+	k := sessionKey{source.String(), domain}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	lock, ok := c.locks[k]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.locks[k] = lock
+	}
+	return &cacheSession{cache: c, source: source, domain: domain, lock: lock}
+}
+
+type cacheSession struct {
+	cache  *TemplateCache
+	source net.Addr
+	domain uint32
+	lock   *sync.Mutex
+}
+
+func (s *cacheSession) Domain(id uint32) Session {
+	return s.cache.session(s.source, id)
+}
+
+func (s *cacheSession) Lock() {
+	s.lock.Lock()
+}
+
+func (s *cacheSession) Unlock() {
+	s.lock.Unlock()
+}
+
+func (s *cacheSession) GetRecordSize(tid uint16) (size int, found bool) {
+	s.cache.mutex.RLock()
+	defer s.cache.mutex.RUnlock()
+	size, found = s.cache.sizes[templateKey{s.source.String(), s.domain, tid}]
+	return
+}
+
+func (s *cacheSession) SetRecordSize(tid uint16, size int) {
+	s.cache.mutex.Lock()
+	defer s.cache.mutex.Unlock()
+	k := templateKey{s.source.String(), s.domain, tid}
+	if s.cache.sizes[k] < size {
+		s.cache.sizes[k] = size
+	}
+}
+
+func (s *cacheSession) AddTemplate(t Template) {
+	s.cache.Add(s.source, s.domain, t.ID(), t)
+}
+
+func (s *cacheSession) GetTemplate(id uint16) (t Template, found bool) {
+	return s.cache.Lookup(s.source, s.domain, id)
+}
+
+// Test if cacheSession is compliant
+var _ DomainSession = (*cacheSession)(nil)
@@ -0,0 +1,207 @@
+package session
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testTemplate struct {
+	id     uint16
+	fields int
+}
+
+func (t testTemplate) ID() uint16 {
+	return t.id
+}
+
+func TestTemplateCacheScope(t *testing.T) {
+	var (
+		c = NewTemplateCache()
+		a = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+		b = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 2055}
+	)
+
+	c.Add(a, 0, 256, testTemplate{256, 4})
+	c.Add(b, 0, 256, testTemplate{256, 8})
+
+	if tm, ok := c.Lookup(a, 0, 256); !ok || tm.(testTemplate).fields != 4 {
+		t.Fatalf("expected template with 4 fields for %s, got %v", a, tm)
+	}
+	if tm, ok := c.Lookup(b, 0, 256); !ok || tm.(testTemplate).fields != 8 {
+		t.Fatalf("expected template with 8 fields for %s, got %v", b, tm)
+	}
+	if _, ok := c.Lookup(a, 0, 257); ok {
+		t.Fatal("expected template 257 to be unknown")
+	}
+}
+
+func TestTemplateCacheRedefinition(t *testing.T) {
+	var (
+		c = NewTemplateCache()
+		a = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+	)
+
+	c.Add(a, 0, 256, testTemplate{256, 4})
+	c.Add(a, 0, 256, testTemplate{256, 6})
+
+	if tm, ok := c.Lookup(a, 0, 256); !ok || tm.(testTemplate).fields != 6 {
+		t.Fatalf("expected redefined template with 6 fields, got %v", tm)
+	}
+}
+
+func TestTemplateCacheTTL(t *testing.T) {
+	var (
+		c   = NewTemplateCache().WithTTL(30 * time.Minute)
+		a   = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+		now = time.Unix(1577836800, 0)
+	)
+	c.now = func() time.Time { return now }
+
+	c.Add(a, 0, 256, testTemplate{256, 4})
+	c.Add(a, 0, 257, testTemplate{257, 4})
+	c.Session(a).SetRecordSize(256, 16)
+
+	now = now.Add(20 * time.Minute)
+	if _, ok := c.Lookup(a, 0, 256); !ok {
+		t.Fatal("expected template 256 to be valid before the TTL")
+	}
+	c.Add(a, 0, 257, testTemplate{257, 6})
+
+	now = now.Add(20 * time.Minute)
+	if _, ok := c.Lookup(a, 0, 256); ok {
+		t.Fatal("expected template 256 to be expired")
+	}
+	if _, ok := c.Session(a).GetRecordSize(256); ok {
+		t.Fatal("expected record size of template 256 to be evicted")
+	}
+	if tm, ok := c.Lookup(a, 0, 257); !ok || tm.(testTemplate).fields != 6 {
+		t.Fatalf("expected refreshed template 257, got %v", tm)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if n := c.Expire(); n != 1 {
+		t.Fatalf("expected 1 template to expire, got %d", n)
+	}
+	if _, ok := c.Lookup(a, 0, 257); ok {
+		t.Fatal("expected template 257 to be expired")
+	}
+}
+
+func TestTemplateCacheConcurrent(t *testing.T) {
+	var (
+		c  = NewTemplateCache()
+		wg sync.WaitGroup
+	)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			addr := &net.UDPAddr{IP: net.IPv4(192, 0, 2, byte(i)), Port: 2055}
+			for id := uint16(256); id < 512; id++ {
+				c.Add(addr, 0, id, testTemplate{id, i})
+				if tm, ok := c.Lookup(addr, 0, id); !ok || tm.(testTemplate).fields != i {
+					t.Errorf("%s: lookup of template %d failed, got %v", addr, id, tm)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestTemplateCacheSession(t *testing.T) {
+	var (
+		c = NewTemplateCache()
+		a = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+		b = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 2055}
+		s = c.Session(a)
+	)
+
+	s.Lock()
+	s.AddTemplate(testTemplate{256, 4})
+	s.Unlock()
+
+	if _, ok := c.Lookup(a, 0, 256); !ok {
+		t.Fatal("expected template added through session to be in cache")
+	}
+	if _, ok := c.Session(b).GetTemplate(256); ok {
+		t.Fatal("expected template to be scoped to source")
+	}
+}
+
+func TestTemplateCacheSessionLock(t *testing.T) {
+	var (
+		c = NewTemplateCache()
+		a = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+		b = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 2055}
+	)
+
+	// While source a is decoding, source b can decode.
+	s := c.Session(a)
+	s.Lock()
+	defer s.Unlock()
+	done := make(chan struct{})
+	go func() {
+		o := c.Session(b)
+		o.Lock()
+		o.AddTemplate(testTemplate{256, 4})
+		o.Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected session of source b not to wait for source a")
+	}
+
+	// The Sessions of one source and domain share their lock.
+	if c.Session(a).(*cacheSession).lock.TryLock() {
+		t.Fatal("expected session of source a to be locked")
+	}
+	if d := c.Session(a).(DomainSession).Domain(1).(*cacheSession); !d.lock.TryLock() {
+		t.Fatal("expected session of domain 1 not to be locked")
+	}
+}
+
+func TestTemplateCacheDomain(t *testing.T) {
+	var (
+		c = NewTemplateCache()
+		a = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+		s = c.Session(a).(DomainSession)
+	)
+
+	s.Domain(1).AddTemplate(testTemplate{256, 4})
+	s.Domain(2).AddTemplate(testTemplate{256, 8})
+
+	if tm, ok := c.Lookup(a, 1, 256); !ok || tm.(testTemplate).fields != 4 {
+		t.Fatalf("expected template with 4 fields in domain 1, got %v", tm)
+	}
+	if tm, ok := s.Domain(2).GetTemplate(256); !ok || tm.(testTemplate).fields != 8 {
+		t.Fatalf("expected template with 8 fields in domain 2, got %v", tm)
+	}
+	if _, ok := s.GetTemplate(256); ok {
+		t.Fatal("expected template to be scoped to domain")
+	}
+}
+
+func TestSessionDomain(t *testing.T) {
+	s := New()
+	s.Domain(1).AddTemplate(testTemplate{256, 4})
+	s.Domain(2).AddTemplate(testTemplate{256, 8})
+
+	if tm, ok := s.Domain(1).GetTemplate(256); !ok || tm.(testTemplate).fields != 4 {
+		t.Fatalf("expected template with 4 fields in domain 1, got %v", tm)
+	}
+	if tm, ok := s.Domain(2).GetTemplate(256); !ok || tm.(testTemplate).fields != 8 {
+		t.Fatalf("expected template with 8 fields in domain 2, got %v", tm)
+	}
+	if _, ok := s.GetTemplate(256); ok {
+		t.Fatal("expected template to be scoped to domain")
+	}
+	if s.Domain(0) != Session(s) {
+		t.Fatal("expected domain 0 to be the session itself")
+	}
+}
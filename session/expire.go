@@ -0,0 +1,82 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// expiringSession hides the templates of a Session that are not added again
+// within the time to live, see Expiring.
+type expiringSession struct {
+	Session
+	ttl     time.Duration
+	now     func() time.Time
+	mutex   *sync.Mutex
+	added   map[uint16]time.Time
+	domains map[uint32]*expiringSession
+}
+
+// Expiring returns a Session of which the templates expire if they are not
+// added again within ttl after they were last added, as for TemplateCache.WithTTL.
+// An expired template is not found, so the data referencing it is treated as
+// data for an unknown template until the exporter sends the template again.
+// Templates added to s before it was wrapped do not expire. If s scopes
+// templates per domain, so does the returned Session.
+func Expiring(s Session, ttl time.Duration) Session {
+	return newExpiring(s, ttl, time.Now, &sync.Mutex{})
+}
+
+func newExpiring(s Session, ttl time.Duration, now func() time.Time, mutex *sync.Mutex) *expiringSession {
+	return &expiringSession{
+		Session: s,
+		ttl:     ttl,
+		now:     now,
+		mutex:   mutex,
+		added:   make(map[uint16]time.Time),
+	}
+}
+
+// Domain returns the expiring Session of the domain, if the wrapped Session
+// scopes templates per domain.
+func (s *expiringSession) Domain(id uint32) Session {
+	ds, ok := s.Session.(DomainSession)
+	if !ok {
+		return s
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	d, ok := s.domains[id]
+	if !ok {
+		if s.domains == nil {
+			s.domains = make(map[uint32]*expiringSession)
+		}
+		d = newExpiring(ds.Domain(id), s.ttl, s.now, s.mutex)
+		s.domains[id] = d
+	}
+	return d
+}
+
+// AddTemplate adds the template and refreshes its time to live.
+func (s *expiringSession) AddTemplate(t Template) {
+	s.Session.AddTemplate(t)
+	s.mutex.Lock()
+	s.added[t.ID()] = s.now()
+	s.mutex.Unlock()
+}
+
+// GetTemplate returns the template, unless it expired.
+func (s *expiringSession) GetTemplate(id uint16) (t Template, found bool) {
+	if t, found = s.Session.GetTemplate(id); !found || s.ttl <= 0 {
+		return
+	}
+	s.mutex.Lock()
+	added, ok := s.added[id]
+	s.mutex.Unlock()
+	if ok && s.now().Sub(added) >= s.ttl {
+		return nil, false
+	}
+	return t, true
+}
+
+// Test if expiringSession is compliant
+var _ DomainSession = (*expiringSession)(nil)
@@ -0,0 +1,34 @@
+package session
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExpiring(t *testing.T) {
+	now := time.Unix(1577836800, 0)
+	s := newExpiring(New(), 30*time.Minute, func() time.Time { return now }, &sync.Mutex{})
+
+	s.AddTemplate(testTemplate{256, 4})
+	s.Domain(1).AddTemplate(testTemplate{256, 6})
+
+	now = now.Add(20 * time.Minute)
+	if _, ok := s.GetTemplate(256); !ok {
+		t.Fatal("expected template 256 to be valid before the TTL")
+	}
+	s.Domain(1).AddTemplate(testTemplate{256, 6})
+
+	now = now.Add(20 * time.Minute)
+	if _, ok := s.GetTemplate(256); ok {
+		t.Fatal("expected template 256 to be expired")
+	}
+	if tm, ok := s.Domain(1).GetTemplate(256); !ok || tm.(testTemplate).fields != 6 {
+		t.Fatalf("expected refreshed template 256 in domain 1, got %v", tm)
+	}
+
+	s.AddTemplate(testTemplate{256, 4})
+	if _, ok := s.GetTemplate(256); !ok {
+		t.Fatal("expected template 256 to be valid once added again")
+	}
+}
@@ -21,10 +21,21 @@ type Session interface {
 	GetTemplate(uint16) (t Template, found bool)
 }
 
+// DomainSession is implemented by sessions that scope templates per
+// observation domain, the NetFlow v9 Source ID or IPFIX Observation Domain ID.
+// The decoders use the Session of the domain announced in the packet header.
+type DomainSession interface {
+	Session
+
+	// Domain returns the Session holding the templates of the domain.
+	Domain(id uint32) Session
+}
+
 type basicSession struct {
 	mutex     *sync.Mutex
 	templates map[uint16]Template
 	sizes     map[uint16]int
+	domains   map[uint32]*basicSession
 }
 
 func New() *basicSession {
@@ -35,6 +46,29 @@ func New() *basicSession {
 	}
 }
 
+// Domain returns the session for the domain, domain 0 being the session
+// itself. The domain sessions share the lock of the session.
+func (s *basicSession) Domain(id uint32) Session {
+	if id == 0 {
+		return s
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	d, ok := s.domains[id]
+	if !ok {
+		if s.domains == nil {
+			s.domains = make(map[uint32]*basicSession)
+		}
+		d = &basicSession{
+			mutex:     s.mutex,
+			templates: make(map[uint16]Template),
+			sizes:     make(map[uint16]int),
+		}
+		s.domains[id] = d
+	}
+	return d
+}
+
 func (s *basicSession) Lock() {
 	s.mutex.Lock()
 }
@@ -64,4 +98,4 @@ func (s *basicSession) GetTemplate(id uint16) (t Template, found bool) {
 }
 
 // Test if basicSession is compliant
-var _ Session = (*basicSession)(nil)
+var _ DomainSession = (*basicSession)(nil)
@@ -0,0 +1,270 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow9"
+)
+
+func TestSessionDecodePacket(t *testing.T) {
+	s := NewSession()
+	sources := []net.Addr{
+		&net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055},
+		&net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 2055},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(sources))
+	for _, src := range sources {
+		wg.Add(1)
+		go func(src net.Addr) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				p, err := s.DecodePacket(src, testPacketV5(2))
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, ok := p.Message.(*netflow5.Packet); !ok || len(p.Records) != 2 {
+					t.Errorf("%s: unexpected packet %s", src, p)
+					return
+				}
+			}
+		}(src)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionDecodePacketMissed(t *testing.T) {
+	s := NewSession()
+	src := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+
+	for _, test := range []struct {
+		seq  uint32
+		want int64
+	}{
+		{42, 0},
+		{45, 0},
+		{50, 2},
+		{46, -1}, // only 48 of 46-48 was reported missed
+	} {
+		b := testPacketV5(3)
+		binary.BigEndian.PutUint32(b[16:], test.seq)
+		p, err := s.DecodePacket(src, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Missed != test.want {
+			t.Errorf("sequence %d: expected %d missed, got %d", test.seq, test.want, p.Missed)
+		}
+	}
+}
+
+func TestSessionDecodePacketConcurrent(t *testing.T) {
+	var (
+		mutex   sync.Mutex
+		decoded []uint32 // sequence numbers in the order they were decoded
+		missed  = make(map[uint32]int64)
+		src     = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+	)
+	// The filter is called while decoding, the source port of the record
+	// is the sequence number of the packet.
+	s := NewSession(WithRecordFilter(func(r FlowRecord) bool {
+		key, _ := KeyOf(r)
+		mutex.Lock()
+		decoded = append(decoded, uint32(key.SrcPort))
+		mutex.Unlock()
+		return true
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(first uint32) {
+			defer wg.Done()
+			for seq := first; seq < first+64; seq++ {
+				b := testPacketV5(1)
+				binary.BigEndian.PutUint32(b[16:], seq)
+				binary.BigEndian.PutUint16(b[24+32:], uint16(seq))
+				p, err := s.DecodePacket(src, b)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mutex.Lock()
+				missed[seq] = p.Missed
+				mutex.Unlock()
+			}
+		}(uint32(i * 64))
+	}
+	wg.Wait()
+
+	// The sequence numbers must be observed in the order of decoding.
+	tr := NewSequenceTracker(IncrementRecords)
+	for _, seq := range decoded {
+		if want := tr.Observe(src, seq, 1); missed[seq] != want {
+			t.Errorf("sequence %d: expected %d missed, got %d", seq, want, missed[seq])
+		}
+	}
+}
+
+func TestSessionDecodePacketDomains(t *testing.T) {
+	var (
+		s   = NewSession()
+		src = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+	)
+
+	// Two Source IDs sending interleaved from one address, each with its own
+	// sequence. Only the packet with sequence 13 of Source ID 2 is lost.
+	for i, test := range []struct {
+		sourceID uint32
+		seq      uint32
+		want     int64
+	}{
+		{1, 100, 0},
+		{2, 10, 0},
+		{1, 101, 0},
+		{2, 11, 0},
+		{1, 102, 0},
+		{2, 12, 0},
+		{2, 14, 1},
+		{1, 103, 0},
+	} {
+		h := netflow9.PacketHeader{Version: netflow9.Version, SequenceNumber: test.seq, SourceID: test.sourceID}
+		p, err := s.DecodePacket(src, h.AppendBytes(nil))
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if p.Missed != test.want {
+			t.Errorf("packet %d: source ID %d sequence %d: expected %d missed, got %d",
+				i, test.sourceID, test.seq, test.want, p.Missed)
+		}
+	}
+}
+
+func TestSessionTemplateTTL(t *testing.T) {
+	var (
+		s   = NewSession(WithTemplateTTL(50*time.Millisecond), WithSkipUnknownTemplates(true))
+		src = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+	)
+	if _, err := s.DecodePacket(src, testPacketV9()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Data FlowSet for template 256, without the template.
+	data := func(seq uint32) []byte {
+		h := netflow9.PacketHeader{Version: netflow9.Version, Count: 1, SequenceNumber: seq, SourceID: 1}
+		return append(h.AppendBytes(nil), testPacketV9()[44:]...)
+	}
+	p, err := s.DecodePacket(src, data(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Skipped != 0 || len(p.Message.(*netflow9.Packet).DataRecords()) != 1 {
+		t.Fatalf("expected 1 data record before the TTL, got %d skipped", p.Skipped)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if p, err = s.DecodePacket(src, data(3)); err != nil {
+		t.Fatal(err)
+	}
+	if p.Skipped != 1 {
+		t.Fatalf("expected data flowset for expired template to be skipped, got %d skipped", p.Skipped)
+	}
+}
+
+func TestSessionDecodePacketMixedVersions(t *testing.T) {
+	var (
+		s   = NewSession()
+		src = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+	)
+
+	// The template is learned from the first NetFlow v9 packet, the second
+	// packet only carries data.
+	tr := netflow9.NewTemplateBuilder(256).AddField(8, 4).AddField(7, 2).Build()
+	dfs := netflow9.NewDataFlowSetBuilder(tr)
+	if err := dfs.AddRecord(net.IPv4(192, 0, 2, 1), 443); err != nil {
+		t.Fatal(err)
+	}
+	h := netflow9.PacketHeader{Version: netflow9.Version, Count: 2, SequenceNumber: 7}
+	v9 := dfs.AppendBytes(netflow9.AppendTemplateFlowSet(h.AppendBytes(nil), tr))
+	h.Count, h.SequenceNumber = 1, 8
+	v9data := dfs.AppendBytes(h.AppendBytes(nil))
+
+	v5 := testPacketV5(3)
+	v5next := testPacketV5(3)
+	binary.BigEndian.PutUint32(v5next[16:], 45)
+
+	for i, b := range [][]byte{v5, v9, v5next, v9data} {
+		p, err := s.DecodePacket(src, b)
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if p.Missed != 0 {
+			t.Errorf("packet %d: expected no missed records, got %d", i, p.Missed)
+		}
+		switch m := p.Message.(type) {
+		case *netflow5.Packet:
+			if len(p.Records) != 3 {
+				t.Errorf("packet %d: expected 3 records, got %d", i, len(p.Records))
+			}
+		case *netflow9.Packet:
+			drs := m.DataRecords()
+			if len(drs) != 1 || len(drs[0].Fields) != 2 || drs[0].Fields[1].Uint() != 443 {
+				t.Errorf("packet %d: expected 1 data record with port 443, got %v", i, drs)
+			}
+		default:
+			t.Errorf("packet %d: unexpected %T", i, m)
+		}
+	}
+}
+
+func TestSessionInterfaceName(t *testing.T) {
+	data := []byte{
+		0x00, 0x09, 0x00, 0x02, // version 9, count 2
+		0x00, 0x01, 0x86, 0xa0, // SysUpTime
+		0x5e, 0x0b, 0xe1, 0x00, // UnixSecs
+		0x00, 0x00, 0x00, 0x01, // SequenceNumber
+		0x00, 0x00, 0x00, 0x01, // SourceID
+		0x00, 0x01, 0x00, 0x14, // options template flowset
+		0x01, 0x01, 0x00, 0x04, // template 257, scope length 4
+		0x00, 0x04, // option length 4
+		0x00, 0x02, 0x00, 0x04, // Interface scope
+		0x00, 0x52, 0x00, 0x20, // interfaceName
+		0x00, 0x00, // padding
+		0x01, 0x01, 0x00, 0x28, // data flowset for template 257
+		0x00, 0x00, 0x00, 0x02, // ifIndex 2
+	}
+	name := make([]byte, 32)
+	copy(name, "GigabitEthernet0/1")
+	data = append(data, name...)
+
+	var (
+		s   = NewSession()
+		src = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+	)
+	if _, ok := s.InterfaceName(src, 2); ok {
+		t.Fatal("expected no interface name for an unknown source")
+	}
+	if _, err := s.DecodePacket(src, data); err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := s.InterfaceName(src, 2); !ok || name != "GigabitEthernet0/1" {
+		t.Errorf("expected GigabitEthernet0/1, got %q", name)
+	}
+	if _, ok := s.InterfaceName(src, 3); ok {
+		t.Error("expected no interface name for index 3")
+	}
+	if _, ok := s.InterfaceName(&net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 2055}, 2); ok {
+		t.Error("expected interface names to be kept per source")
+	}
+}
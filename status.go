@@ -0,0 +1,149 @@
+package netflow
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow9"
+)
+
+// ExporterInfo is the state a Session keeps for an exporting source, see
+// Session.ExporterStatus.
+type ExporterInfo struct {
+	// Addr is the address the exporter sends from.
+	Addr net.Addr
+	// Version is the version of the last packet.
+	Version uint16
+	// LastSeen is the time the last packet was decoded.
+	LastSeen time.Time
+	// Sequence is the sequence number of the last packet, zero if the
+	// version has none.
+	Sequence uint32
+	// Domains are the NetFlow v9 Source IDs and IPFIX Observation Domain IDs
+	// seen, in ascending order.
+	Domains []uint32
+	// Templates are the templates announced, ordered by domain and template
+	// ID.
+	Templates []TemplateInfo
+}
+
+// TemplateInfo describes a NetFlow v9 or IPFIX (options) template announced by
+// an exporter.
+type TemplateInfo struct {
+	Domain     uint32
+	TemplateID uint16
+	// Fields is the number of fields, including scope fields.
+	Fields int
+	// Options is set for options templates.
+	Options bool
+}
+
+// exporterTemplate identifies a template of an exporter.
+type exporterTemplate struct {
+	domain     uint32
+	templateID uint16
+}
+
+// ExporterStatus returns the state of every source a packet was decoded from,
+// ordered by address.
+func (s *Session) ExporterStatus() []ExporterInfo {
+	s.mutex.Lock()
+	states := make([]*sourceState, 0, len(s.sources))
+	for _, state := range s.sources {
+		states = append(states, state)
+	}
+	s.mutex.Unlock()
+
+	infos := make([]ExporterInfo, 0, len(states))
+	for _, state := range states {
+		state.mutex.Lock()
+		if state.addr != nil {
+			infos = append(infos, state.info())
+		}
+		state.mutex.Unlock()
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Addr.String() < infos[j].Addr.String()
+	})
+	return infos
+}
+
+// observe updates the exporter state with a decoded packet.
+func (state *sourceState) observe(src net.Addr, p *Packet, now time.Time) {
+	state.addr = src
+	state.version = p.Header.ProtocolVersion()
+	state.lastSeen = now
+	state.sequence = p.Header.Sequence()
+
+	switch m := p.Message.(type) {
+	case *netflow9.Packet:
+		domain := m.Header.SourceID
+		state.addDomain(domain)
+		for _, tfs := range m.TemplateFlowSets {
+			for _, tr := range tfs.Records {
+				state.addTemplate(TemplateInfo{Domain: domain, TemplateID: tr.TemplateID, Fields: len(tr.Fields)})
+			}
+		}
+		for _, ofs := range m.OptionsTemplateFlowSets {
+			for _, otr := range ofs.Records {
+				state.addTemplate(TemplateInfo{Domain: domain, TemplateID: otr.TemplateID, Fields: len(otr.ScopeFields) + len(otr.Fields), Options: true})
+			}
+		}
+
+	case *ipfix.Message:
+		domain := m.Header.ObservationDomainID
+		state.addDomain(domain)
+		for _, ts := range m.TemplateSets {
+			for _, tr := range ts.Records {
+				state.addTemplate(TemplateInfo{Domain: domain, TemplateID: tr.TemplateID, Fields: len(tr.Fields)})
+			}
+		}
+		for _, ots := range m.OptionsTemplateSets {
+			for _, otr := range ots.Records {
+				state.addTemplate(TemplateInfo{Domain: domain, TemplateID: otr.TemplateID, Fields: len(otr.ScopeFields) + len(otr.Fields), Options: true})
+			}
+		}
+	}
+}
+
+func (state *sourceState) addDomain(domain uint32) {
+	if state.domains == nil {
+		state.domains = make(map[uint32]bool)
+	}
+	state.domains[domain] = true
+}
+
+func (state *sourceState) addTemplate(t TemplateInfo) {
+	if state.templates == nil {
+		state.templates = make(map[exporterTemplate]TemplateInfo)
+	}
+	state.templates[exporterTemplate{t.Domain, t.TemplateID}] = t
+}
+
+func (state *sourceState) info() ExporterInfo {
+	info := ExporterInfo{
+		Addr:     state.addr,
+		Version:  state.version,
+		LastSeen: state.lastSeen,
+		Sequence: state.sequence,
+	}
+	for domain := range state.domains {
+		info.Domains = append(info.Domains, domain)
+	}
+	sort.Slice(info.Domains, func(i, j int) bool {
+		return info.Domains[i] < info.Domains[j]
+	})
+	for _, t := range state.templates {
+		info.Templates = append(info.Templates, t)
+	}
+	sort.Slice(info.Templates, func(i, j int) bool {
+		a, b := info.Templates[i], info.Templates[j]
+		if a.Domain != b.Domain {
+			return a.Domain < b.Domain
+		}
+		return a.TemplateID < b.TemplateID
+	})
+	return info
+}
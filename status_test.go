@@ -0,0 +1,46 @@
+package netflow
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/netflow5"
+)
+
+func TestSessionExporterStatus(t *testing.T) {
+	var (
+		s      = NewSession()
+		v5     = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 2055}
+		v9     = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2055}
+		before = time.Now()
+	)
+	if infos := s.ExporterStatus(); len(infos) != 0 {
+		t.Fatalf("expected no exporters, got %v", infos)
+	}
+	p, err := s.DecodePacket(v5, testPacketV5(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.DecodePacket(v9, testPacketV9()); err != nil {
+		t.Fatal(err)
+	}
+
+	infos := s.ExporterStatus()
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 exporters, got %v", infos)
+	}
+	if info := infos[0]; info.Addr != v9 || info.Version != 9 || info.Sequence != 1 || info.LastSeen.Before(before) {
+		t.Errorf("unexpected v9 exporter %+v", info)
+	}
+	if want := []uint32{1}; !reflect.DeepEqual(infos[0].Domains, want) {
+		t.Errorf("expected domains %v, got %v", want, infos[0].Domains)
+	}
+	if want := []TemplateInfo{{Domain: 1, TemplateID: 256, Fields: 2}}; !reflect.DeepEqual(infos[0].Templates, want) {
+		t.Errorf("expected templates %v, got %v", want, infos[0].Templates)
+	}
+	if info := infos[1]; info.Addr != v5 || info.Version != netflow5.Version || info.Sequence != p.Header.Sequence() || info.Domains != nil || info.Templates != nil {
+		t.Errorf("unexpected v5 exporter %+v", info)
+	}
+}
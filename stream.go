@@ -0,0 +1,44 @@
+package netflow
+
+import (
+	"io"
+	"sync"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// StreamDecoder decodes the packets of a stream of concatenated datagrams,
+// such as NetFlow exported over a TCP connection. Each datagram is framed by
+// the length announced in its header, see ExpectedLength; NetFlow v9 packets
+// are framed by walking their FlowSets. Reads returning only part of a
+// datagram are buffered until the datagram is complete. All datagrams are
+// decoded by one Decoder, so templates are kept across datagrams. It is safe
+// for concurrent use, packets are decoded one at a time.
+type StreamDecoder struct {
+	mutex   sync.Mutex
+	replay  *FileReplay
+	decoder *Decoder
+}
+
+// NewStreamDecoder sets up a decoder for the stream of datagrams read from r,
+// the options are applied to its Decoder.
+func NewStreamDecoder(r io.Reader, opts ...DecoderOption) *StreamDecoder {
+	return &StreamDecoder{
+		replay:  NewFileReplay(r, FrameSelfDescribing),
+		decoder: NewDecoder(session.New(), opts...),
+	}
+}
+
+// Next decodes the next datagram of the stream. When the stream is exhausted,
+// io.EOF is returned; a stream ending halfway a datagram returns
+// io.ErrUnexpectedEOF. After a framing error, the position of the next
+// datagram is unknown and the stream can not be decoded any further.
+func (d *StreamDecoder) Next() (*Packet, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	b, err := d.replay.Next()
+	if err != nil {
+		return nil, err
+	}
+	return d.decoder.DecodeBytes(b)
+}
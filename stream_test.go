@@ -0,0 +1,54 @@
+package netflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow9"
+)
+
+func TestStreamDecoder(t *testing.T) {
+	// The second v9 packet only holds data, for the template of the first.
+	v9 := testPacketV9Records(t, 2)
+	tfs := 20 + int(binary.BigEndian.Uint16(v9[22:]))
+	data := append(append([]byte(nil), v9[:20]...), v9[tfs:]...)
+	binary.BigEndian.PutUint16(data[2:], 2)
+
+	var stream bytes.Buffer
+	for _, b := range [][]byte{testPacketV5(2), v9, data, testPacketV5(1)} {
+		stream.Write(b)
+	}
+
+	d := NewStreamDecoder(iotest.HalfReader(iotest.OneByteReader(&stream)))
+	for i, want := range []int{2, 2, 2, 1} {
+		p, err := d.Next()
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if n := recordCount(p); n != want {
+			t.Errorf("packet %d: expected %d records, got %d", i, want, n)
+		}
+		switch i {
+		case 0, 3:
+			if _, ok := p.Message.(*netflow5.Packet); !ok {
+				t.Errorf("packet %d: expected a v5 packet, got %T", i, p.Message)
+			}
+		case 2:
+			if drs := p.Message.(*netflow9.Packet).DataRecords(); len(drs) != 2 {
+				t.Errorf("packet %d: expected the template to be known, got %d records", i, len(drs))
+			}
+		}
+	}
+	if _, err := d.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+
+	d = NewStreamDecoder(bytes.NewReader(testPacketV5(2)[:50]))
+	if _, err := d.Next(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
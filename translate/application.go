@@ -0,0 +1,49 @@
+package translate
+
+import "fmt"
+
+// Classification engine IDs of an ApplicationID, as per RFC 6759 section 4.1.
+const (
+	EngineIANAL3    uint8 = 1  // IANA Layer 3 protocol numbers
+	EnginePANAL3    uint8 = 2  // Proprietary Layer 3 definitions
+	EngineIANAL4    uint8 = 3  // IANA Layer 4 well-known port numbers
+	EnginePANAL4    uint8 = 4  // Proprietary Layer 4 definitions
+	EngineUser      uint8 = 6  // User defined applications
+	EnginePANAL2    uint8 = 12 // Proprietary Layer 2 definitions
+	EnginePANAL7    uint8 = 13 // Proprietary Layer 7 definitions, such as NBAR
+	EngineEtherType uint8 = 18 // Ethernet types
+	EngineLLC       uint8 = 19 // IEEE 802.2 LLC
+	EnginePANAL7PEN uint8 = 20 // Proprietary Layer 7 definitions with enterprise number
+)
+
+// ApplicationID is the applicationId (95) information element, as exported by
+// Cisco NBAR and AVC. It consists of the classification engine that
+// identified the application and the selector of the application within that
+// engine.
+type ApplicationID struct {
+	EngineID uint8
+	Selector uint64
+}
+
+func (a ApplicationID) String() string {
+	return fmt.Sprintf("%d:%d", a.EngineID, a.Selector)
+}
+
+func init() {
+	registerDecodeFunc(Key{FieldID: 95}, DecodeApplicationID)
+}
+
+// DecodeApplicationID decodes an applicationId into an ApplicationID, the
+// first byte is the engine ID and the remaining bytes are the selector. Values
+// with no selector, or a selector longer than 8 bytes, are returned
+// uninterpreted.
+func DecodeApplicationID(bs []byte) interface{} {
+	if len(bs) < 2 || len(bs) > 9 {
+		return bs
+	}
+	a := ApplicationID{EngineID: bs[0]}
+	for _, b := range bs[1:] {
+		a.Selector = a.Selector<<8 | uint64(b)
+	}
+	return a
+}
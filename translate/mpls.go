@@ -0,0 +1,42 @@
+package translate
+
+import "fmt"
+
+// MPLSLabel is an entry of an MPLS label stack, as exported in the
+// mplsTopLabelStackSection (70) and mplsLabelStackSection2 to 10 (71-79)
+// information elements.
+type MPLSLabel struct {
+	// Label is the 20 bit label value.
+	Label uint32
+	// TC is the 3 bit Traffic Class, formerly known as EXP.
+	TC uint8
+	// BottomOfStack is the S bit, set on the last entry of the stack.
+	BottomOfStack bool
+}
+
+func (l MPLSLabel) String() string {
+	var s uint8
+	if l.BottomOfStack {
+		s = 1
+	}
+	return fmt.Sprintf("%d/%d/%d", l.Label, l.TC, s)
+}
+
+func init() {
+	for id := uint16(70); id <= 79; id++ {
+		registerDecodeFunc(Key{FieldID: id}, DecodeMPLSLabel)
+	}
+}
+
+// DecodeMPLSLabel decodes a 3 byte label stack section into an MPLSLabel.
+// Sections of another length are returned uninterpreted.
+func DecodeMPLSLabel(bs []byte) interface{} {
+	if len(bs) != 3 {
+		return bs
+	}
+	return MPLSLabel{
+		Label:         uint32(bs[0])<<12 | uint32(bs[1])<<4 | uint32(bs[2])>>4,
+		TC:            bs[2] >> 1 & 0x07,
+		BottomOfStack: bs[2]&0x01 == 0x01,
+	}
+}
@@ -3,31 +3,99 @@ package translate
 //go:generate go run cmd/translate-rfc5102/main.go -output rfc5102.go
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"math"
 	"net"
+	"sync"
 	"time"
 
+	"github.com/tehmaze/netflow/read"
 	"github.com/tehmaze/netflow/session"
 )
 
 // Builtin dictionary of information elements
-var builtin = make(informationElements)
+var (
+	builtin      = make(informationElements)
+	builtinMutex sync.RWMutex
+)
+
+// decodeFuncs are the DecodeFuncs of builtin Information Elements, registered
+// using registerDecodeFunc by the init functions next to the DecodeFuncs.
+var decodeFuncs = make(map[Key]DecodeFunc)
+
+// registerDecodeFunc sets the DecodeFunc of the builtin Information Element
+// with the key, and of its reverse element, if any. The elements of RFC 5102
+// are generated, so their DecodeFuncs can not be set in the generated file.
+func registerDecodeFunc(k Key, f DecodeFunc) {
+	decodeFuncs[k] = f
+}
+
+func init() {
+	// The init functions of the files in a package run in the order of their
+	// file names, so this runs after the generated init of rfc5102.go.
+	for k, f := range decodeFuncs {
+		for _, k := range []Key{k, {EnterpriseID: reversePEN, FieldID: k.FieldID}} {
+			if e, ok := builtin[k]; ok {
+				e.DecodeFunc = f
+				builtin[k] = e
+			}
+		}
+	}
+}
+
+// RegisterElement adds or replaces an Information Element in the builtin
+// dictionary, it is keyed on the id and the EnterpriseID of the entry.
+// Elements registered are available to all translators.
+func RegisterElement(id uint16, e InformationElementEntry) {
+	e.FieldID = id
+	builtinMutex.Lock()
+	defer builtinMutex.Unlock()
+	builtin[Key{EnterpriseID: e.EnterpriseID, FieldID: id}] = e
+}
 
 // Translate knows how to translate the raw bytes from a DataRecord into their actual values.
 type Translate struct {
 	session.Session
 	elements informationElements
+	filter   map[Key]bool
 }
 
 // NewTranslate creates a new session bound translator.
 func NewTranslate(s session.Session) *Translate {
-	return &Translate{s, builtin}
+	return &Translate{Session: s, elements: builtin}
+}
+
+// WithSession returns a copy of the translator bound to s.
+func (t *Translate) WithSession(s session.Session) *Translate {
+	return &Translate{Session: s, elements: t.elements, filter: t.filter}
+}
+
+// WithFieldFilter returns a copy of the translator that only wants the fields
+// with the given keys, see Wants. Without keys, all fields are wanted.
+func (t *Translate) WithFieldFilter(keys ...Key) *Translate {
+	c := &Translate{Session: t.Session, elements: t.elements}
+	if len(keys) > 0 {
+		c.filter = make(map[Key]bool, len(keys))
+		for _, k := range keys {
+			c.filter[k] = true
+		}
+	}
+	return c
+}
+
+// Wants reports whether the field with the given key passes the field filter
+// of the translator. Data Record decoders skip the fields that are not
+// wanted, without decoding their value.
+func (t *Translate) Wants(k Key) bool {
+	return t.filter == nil || t.filter[k]
 }
 
 // Key retrieves the Information Element entry for the given Key.
 func (t *Translate) Key(k Key) (InformationElementEntry, bool) {
+	builtinMutex.RLock()
+	defer builtinMutex.RUnlock()
 	i, ok := t.elements[k]
 	return i, ok
 }
@@ -118,12 +186,26 @@ func (f *FieldType) UnmarshalText(bs []byte) error {
 	return nil
 }
 
+// DecodeFunc decodes the raw bytes of a field into a Go native type.
+type DecodeFunc func(bs []byte) interface{}
+
 // InformationElementEntry is an entry in the Information Element map.
 type InformationElementEntry struct {
 	Name         string
 	FieldID      uint16
 	EnterpriseID uint32
 	Type         FieldType
+	// DecodeFunc is used to decode the field if set, otherwise the field is
+	// decoded based on its Type.
+	DecodeFunc DecodeFunc
+}
+
+// Value decodes the raw bytes of a field into a Go native type.
+func (e InformationElementEntry) Value(bs []byte) interface{} {
+	if e.DecodeFunc != nil {
+		return e.DecodeFunc(bs)
+	}
+	return Bytes(bs, e.Type)
 }
 
 // Key is the key of the Information Element map.
@@ -137,34 +219,44 @@ type informationElements map[Key]InformationElementEntry
 var reducedSizeErr error = errors.New("Unable to read reduced size encoding: size not implemented")
 var tooManyBitsErr error = errors.New("Unable to read reduced size encoding: too many bits")
 
-// Helper method to read an unsigned reduced size field
+// Helper method to read an unsigned reduced size field, the value is zero
+// extended to 64 bits
 func reducedSizeReadUnsigned(bs []byte, maxBits int) (uint64, error) {
 	// Exit if `bs` has more bits than we can store
 	if len(bs)*8 > maxBits {
 		return 0, tooManyBitsErr
 	}
+	if len(bs) == 0 || len(bs) > 8 {
+		return 0, reducedSizeErr
+	}
 
 	switch len(bs) {
 	case 1:
 		return uint64(bs[0]), nil
 	case 2:
 		return uint64(binary.BigEndian.Uint16(bs)), nil
-	case 3:
-		return uint64(uint32(bs[0])<<16 + uint32(bs[1])<<8 + uint32(bs[2])), nil
 	case 4:
 		return uint64(binary.BigEndian.Uint32(bs)), nil
 	case 8:
 		return binary.BigEndian.Uint64(bs), nil
 	}
-	return 0, reducedSizeErr
+	var value uint64
+	for _, b := range bs {
+		value = value<<8 | uint64(b)
+	}
+	return value, nil
 }
 
-// Helper method to read a signed reduced size field
+// Helper method to read a signed reduced size field, the value is sign
+// extended to 64 bits
 func reducedSizeReadSigned(bs []byte, maxBits int) (int64, error) {
 	// Exit if `bs` has more bits than we can store
 	if len(bs)*8 > maxBits {
 		return 0, tooManyBitsErr
 	}
+	if len(bs) == 0 || len(bs) > 8 {
+		return 0, reducedSizeErr
+	}
 
 	switch len(bs) {
 	case 1:
@@ -179,7 +271,9 @@ func reducedSizeReadSigned(bs []byte, maxBits int) (int64, error) {
 	case 8:
 		return int64(binary.BigEndian.Uint64(bs)), nil
 	}
-	return 0, reducedSizeErr
+	unsigned, _ := reducedSizeReadUnsigned(bs, maxBits)
+	shift := 64 - uint(len(bs))*8
+	return int64(unsigned<<shift) >> shift, nil
 }
 
 // Read a reduced size field into its full size
@@ -273,8 +367,13 @@ func Bytes(bs []byte, t FieldType) interface{} {
 		return string(bs)
 	case MacAddress:
 		return net.HardwareAddr(bs)
-	case Ipv4Address, Ipv6Address:
+	case Ipv4Address:
 		return net.IP(bs)
+	case Ipv6Address:
+		var ip read.LongIPv6
+		if err := read.IPv6(&ip, bytes.NewReader(bs)); err == nil {
+			return ip.IP()
+		}
 	case DateTimeSeconds:
 		return time.Unix(int64(binary.BigEndian.Uint32(bs)), 0)
 	case DateTimeMilliseconds:
@@ -289,3 +388,17 @@ func Bytes(bs []byte, t FieldType) interface{} {
 	}
 	return bs
 }
+
+// CloneValue returns a copy of a value returned by Bytes that does not share
+// memory with the translated byte string.
+func CloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return append([]byte(nil), v...)
+	case net.IP:
+		return append(net.IP(nil), v...)
+	case net.HardwareAddr:
+		return append(net.HardwareAddr(nil), v...)
+	}
+	return v
+}
@@ -2,8 +2,10 @@ package translate
 
 import (
 	"math"
+	"net"
 	"reflect"
 	"testing"
+	"time"
 )
 
 // If nicer test failure output like line numbers is desired, one can stub in
@@ -168,6 +170,29 @@ func TestThreeByteUnsigned32(t *testing.T) {
 	assertMatch(t, Uint32, buf, uint32(0x010203))
 }
 
+func TestThreeByteSigned32(t *testing.T) {
+	buf := []byte{0, 0, 1}
+	assertMatch(t, Int32, buf, int32(1))
+
+	buf = []byte{0xff, 0xff, 0xfe}
+	assertMatch(t, Int32, buf, int32(-2))
+}
+
+func TestFiveByteUnsigned64(t *testing.T) {
+	buf := []byte{0x80, 0, 0, 0, 1}
+	assertMatch(t, Uint64, buf, uint64(0x8000000001))
+}
+
+func TestSevenByteUnsigned64(t *testing.T) {
+	buf := []byte{1, 2, 3, 4, 5, 6, 7}
+	assertMatch(t, Uint64, buf, uint64(0x01020304050607))
+}
+
+func TestSixByteSigned64(t *testing.T) {
+	buf := []byte{0x80, 0, 0, 0, 0, 0}
+	assertMatch(t, Int64, buf, int64(-1<<47))
+}
+
 func TestFourByteSigned32(t *testing.T) {
 	buf := []byte{0, 0, 0, 1}
 	assertMatch(t, Int32, buf, int32(1))
@@ -250,3 +275,122 @@ func TestReducedSizeReadUnsigned(t *testing.T) {
 		t.Fatal("Expected reducedSizeReadUnsigned() to fail with large byte slice")
 	}
 }
+
+func TestFieldTypeIpv6Address(t *testing.T) {
+	buf := []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01}
+	assertMatch(t, Ipv6Address, buf, net.ParseIP("2001:db8::1"))
+
+	i, ok := NewTranslate(nil).Key(Key{0, 27})
+	if !ok || i.Type != Ipv6Address {
+		t.Fatalf("expected sourceIPv6Address to be an ipv6Address, got %+v", i)
+	}
+}
+
+func TestInformationElementValue(t *testing.T) {
+	var tests = []struct {
+		Key   Key
+		Bytes []byte
+		Want  interface{}
+	}{
+		{Key{0, 1}, []byte{0, 0, 0, 0, 0, 0, 0x05, 0xdc}, uint64(1500)},
+		{Key{0, 1}, []byte{0xff, 0xff, 0xff, 0xfe}, uint64(0xfffffffe)}, // reduced size
+		{Key{0, 2}, []byte{0x01, 0, 0, 0, 0x2a}, uint64(0x010000002a)},  // reduced size
+		{Key{0, 4}, []byte{6}, uint8(6)},
+		{Key{0, 7}, []byte{0x01, 0xbb}, uint16(443)},
+		{Key{0, 8}, []byte{192, 0, 2, 1}, net.IP{192, 0, 2, 1}},
+		{Key{0, 82}, []byte("eth0"), "eth0"},
+		{Key{0, 152}, []byte{0, 0, 0x01, 0x6f, 0x5e, 0x66, 0xe8, 0x00}, time.Unix(1577836800, 0)},
+		{Key{0, 153}, []byte{0, 0, 0x01, 0x6f, 0x5e, 0x66, 0xec, 0xe8}, time.Unix(1577836801, 256e6)},
+	}
+
+	tr := NewTranslate(nil)
+	for _, test := range tests {
+		e, ok := tr.Key(test.Key)
+		if !ok {
+			t.Fatalf("%v: no information element", test.Key)
+		}
+		v := e.Value(test.Bytes)
+		if tm, ok := v.(time.Time); ok {
+			if !tm.Equal(test.Want.(time.Time)) {
+				t.Errorf("%s: expected %v, got %v", e.Name, test.Want, tm)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(v, test.Want) {
+			t.Errorf("%s: expected %v (%T), got %v (%T)", e.Name, test.Want, test.Want, v, v)
+		}
+	}
+}
+
+func TestRegisterElement(t *testing.T) {
+	RegisterElement(1000, InformationElementEntry{
+		Name:         "testFlags",
+		EnterpriseID: 65535,
+		Type:         Uint8,
+		DecodeFunc: func(bs []byte) interface{} {
+			return bs[0]&0x01 == 0x01
+		},
+	})
+
+	e, ok := NewTranslate(nil).Key(Key{65535, 1000})
+	if !ok || e.Name != "testFlags" || e.FieldID != 1000 {
+		t.Fatalf("expected registered element, got %+v", e)
+	}
+	if v := e.Value([]byte{0x03}); v != true {
+		t.Errorf("expected custom decoder to return true, got %v", v)
+	}
+	if _, ok := NewTranslate(nil).Key(Key{0, 1000}); ok {
+		t.Error("expected enterprise element not to collide with IANA element")
+	}
+}
+
+func TestDecodeMPLSLabel(t *testing.T) {
+	tests := []struct {
+		Bytes []byte
+		Want  interface{}
+	}{
+		{[]byte{0x01, 0x00, 0x0a}, MPLSLabel{Label: 4096, TC: 5}},
+		{[]byte{0xff, 0xff, 0xff}, MPLSLabel{Label: 0xfffff, TC: 7, BottomOfStack: true}},
+		{[]byte{0x00, 0x01}, []byte{0x00, 0x01}},
+	}
+	e, ok := NewTranslate(nil).Key(Key{0, 70})
+	if !ok {
+		t.Fatal("expected mplsTopLabelStackSection to be registered")
+	}
+	for _, test := range tests {
+		if v := e.Value(test.Bytes); !reflect.DeepEqual(v, test.Want) {
+			t.Errorf("%x: expected %v, got %v", test.Bytes, test.Want, v)
+		}
+	}
+	for _, k := range []Key{{0, 79}, {reversePEN, 70}} {
+		if e, ok := NewTranslate(nil).Key(k); !ok || e.DecodeFunc == nil {
+			t.Errorf("%+v: expected label stack section to decode MPLS labels", k)
+		}
+	}
+	if s := (MPLSLabel{Label: 16, TC: 1, BottomOfStack: true}).String(); s != "16/1/1" {
+		t.Errorf("expected 16/1/1, got %q", s)
+	}
+}
+
+func TestDecodeApplicationID(t *testing.T) {
+	tests := []struct {
+		Bytes []byte
+		Want  interface{}
+	}{
+		{[]byte{0x0d, 0x00, 0x00, 0x50}, ApplicationID{EngineID: EnginePANAL7, Selector: 80}},
+		{[]byte{0x03, 0x01, 0xbb}, ApplicationID{EngineID: EngineIANAL4, Selector: 443}},
+		{[]byte{0x0d}, []byte{0x0d}},
+	}
+	e, ok := NewTranslate(nil).Key(Key{0, 95})
+	if !ok {
+		t.Fatal("expected applicationId to be registered")
+	}
+	for _, test := range tests {
+		if v := e.Value(test.Bytes); !reflect.DeepEqual(v, test.Want) {
+			t.Errorf("%x: expected %v, got %v", test.Bytes, test.Want, v)
+		}
+	}
+	if s := (ApplicationID{EngineID: 13, Selector: 80}).String(); s != "13:80" {
+		t.Errorf("expected 13:80, got %q", s)
+	}
+}
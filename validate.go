@@ -0,0 +1,133 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+	"github.com/tehmaze/netflow/netflow9"
+)
+
+// Anomaly is a suspicious value found in a packet by ValidatePacket.
+type Anomaly struct {
+	// Field is the name of the field holding the value.
+	Field string
+	// Description explains what is suspicious about the value.
+	Description string
+}
+
+func (a Anomaly) String() string {
+	return a.Field + ": " + a.Description
+}
+
+// Bounds used by ValidatePacket for the export time of a packet.
+const (
+	MaxExportTimeAhead  = 24 * time.Hour
+	MaxExportTimeBehind = 365 * 24 * time.Hour
+)
+
+// layout describes a fixed layout version for ValidatePacket.
+type layout struct {
+	headerLen, recordLen, maxCount int
+}
+
+// Maximum number of records per packet as documented by Cisco.
+var layouts = map[uint16]layout{
+	netflow1.Version: {netflow1.HeaderLen, netflow1.RecordLen, 24},
+	netflow5.Version: {netflow5.HeaderLen, netflow5.RecordLen, 30},
+	netflow6.Version: {netflow6.HeaderLen, netflow6.RecordLen, 27},
+	netflow7.Version: {netflow7.HeaderLen, netflow7.RecordLen, 27},
+	netflow8.Version: {netflow8.HeaderLen, netflow8.ASRecordLen, netflow8.ASRecordMax},
+}
+
+// ValidatePacket checks the header and records of a raw packet for values
+// that are valid on the wire, but unlikely to be sent by a working exporter,
+// such as a byte swapped count or timestamp. It is meant as a diagnostic
+// tool, it does not decode the packet and reports every anomaly found.
+func ValidatePacket(b []byte) []Anomaly {
+	var as []Anomaly
+	add := func(field, format string, v ...interface{}) {
+		as = append(as, Anomaly{Field: field, Description: fmt.Sprintf(format, v...)})
+	}
+
+	if len(b) < 4 {
+		add("header", "packet of %d bytes is too short", len(b))
+		return as
+	}
+	version, err := DetectVersion(b)
+	if err != nil {
+		add("version", "version %d is not a known NetFlow version", version)
+		return as
+	}
+
+	// All versions have a 32 bit unix seconds timestamp; for NetFlow it is
+	// preceded by the uptime in milliseconds.
+	offset := 8
+	if version == ipfix.Version {
+		offset = 4
+	}
+	if len(b) < offset+4 {
+		add("header", "packet of %d bytes is too short", len(b))
+		return as
+	}
+	now := time.Now()
+	export := time.Unix(int64(binary.BigEndian.Uint32(b[offset:])), 0)
+	if export.After(now.Add(MaxExportTimeAhead)) {
+		add("unix_secs", "export time %s is in the future", export.UTC())
+	} else if export.Before(now.Add(-MaxExportTimeBehind)) {
+		add("unix_secs", "export time %s is in the past", export.UTC())
+	}
+
+	count := int(binary.BigEndian.Uint16(b[2:]))
+	switch version {
+	case ipfix.Version:
+		if count != len(b) {
+			add("length", "message length %d does not match the %d bytes received", count, len(b))
+		}
+		return as
+	case netflow9.Version:
+		if count == 0 {
+			add("count", "no records announced")
+		}
+		return as
+	}
+
+	l := layouts[version]
+	if count == 0 {
+		add("count", "no records announced")
+	} else if count > l.maxCount {
+		add("count", "%d records exceed the maximum of %d", count, l.maxCount)
+	}
+	if n := (len(b) - l.headerLen) / l.recordLen; count > n {
+		add("count", "%d records announced, but the packet holds %d", count, n)
+		count = n
+	}
+
+	// The records start with the same fields in all versions, except v8
+	// records that start with the flow count, packets and bytes.
+	at := 24
+	if version == netflow8.Version {
+		at = 12
+	}
+	uptime := binary.BigEndian.Uint32(b[4:])
+	for i := 0; i < count; i++ {
+		r := b[l.headerLen+i*l.recordLen:]
+		first, last := binary.BigEndian.Uint32(r[at:]), binary.BigEndian.Uint32(r[at+4:])
+		if first > uptime {
+			add(fmt.Sprintf("record %d first", i), "%d is after the uptime %d", first, uptime)
+		}
+		if last > uptime {
+			add(fmt.Sprintf("record %d last", i), "%d is after the uptime %d", last, uptime)
+		}
+		if first > last {
+			add(fmt.Sprintf("record %d first", i), "%d is after last %d", first, last)
+		}
+	}
+	return as
+}
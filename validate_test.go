@@ -0,0 +1,48 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidatePacket(t *testing.T) {
+	b := testPacketV5(2)
+	binary.BigEndian.PutUint32(b[8:], uint32(time.Now().Unix()))
+	if as := ValidatePacket(b); len(as) != 0 {
+		t.Fatalf("expected no anomalies, got %v", as)
+	}
+
+	// A byte swapped unix_secs ends up far in the future.
+	binary.BigEndian.PutUint32(b[8:], uint32(time.Now().Add(10*365*24*time.Hour).Unix()))
+	as := ValidatePacket(b)
+	if len(as) != 1 || as[0].Field != "unix_secs" || !strings.Contains(as[0].Description, "future") {
+		t.Fatalf("expected future unix_secs anomaly, got %v", as)
+	}
+}
+
+func TestValidatePacketRecords(t *testing.T) {
+	b := testPacketV5(2)
+	binary.BigEndian.PutUint32(b[8:], uint32(time.Now().Unix()))
+	binary.BigEndian.PutUint16(b[2:], 40)          // count
+	binary.BigEndian.PutUint32(b[4:], 1000)        // sys_uptime
+	binary.BigEndian.PutUint32(b[24+48+24:], 2000) // record 1 first
+	binary.BigEndian.PutUint32(b[24+48+28:], 1500) // record 1 last
+
+	var fields []string
+	for _, a := range ValidatePacket(b) {
+		fields = append(fields, a.Field)
+	}
+	want := []string{"count", "count", "record 1 first", "record 1 last", "record 1 first"}
+	if strings.Join(fields, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected anomalies for %v, got %v", want, fields)
+	}
+}
+
+func TestValidatePacketVersion(t *testing.T) {
+	as := ValidatePacket([]byte{0x00, 0x63, 0x00, 0x01})
+	if len(as) != 1 || as[0].Field != "version" {
+		t.Fatalf("expected version anomaly, got %v", as)
+	}
+}
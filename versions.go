@@ -0,0 +1,52 @@
+package netflow
+
+import (
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow1"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow6"
+	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
+	"github.com/tehmaze/netflow/netflow9"
+)
+
+// VersionInfo describes a protocol version the Decoder supports.
+type VersionInfo struct {
+	// Version is the version number in the packet header.
+	Version uint16
+	// Name is the human readable name, such as "NetFlow v5".
+	Name string
+	// Templates is set if records are described by templates sent by the
+	// exporter, in stead of having a fixed layout.
+	Templates bool
+	// Options is set if the exporter can send options records, such as the
+	// sampling rate or interface names.
+	Options bool
+	// IPv6 is set if records can hold IPv6 addresses.
+	IPv6 bool
+	// Sampling is set if the exporter can report the sampling interval.
+	Sampling bool
+	// Aggregation is set if records are aggregated by the exporter, in
+	// stead of describing a single flow.
+	Aggregation bool
+	// VariableLength is set if fields can be of variable length.
+	VariableLength bool
+	// Enterprise is set if fields can be enterprise specific.
+	Enterprise bool
+}
+
+var versions = []VersionInfo{
+	{Version: netflow1.Version, Name: "NetFlow v1"},
+	{Version: netflow5.Version, Name: "NetFlow v5", Sampling: true},
+	{Version: netflow6.Version, Name: "NetFlow v6", Sampling: true},
+	{Version: netflow7.Version, Name: "NetFlow v7"},
+	{Version: netflow8.Version, Name: "NetFlow v8", Aggregation: true},
+	{Version: netflow9.Version, Name: "NetFlow v9", Templates: true, Options: true, IPv6: true, Sampling: true, VariableLength: true},
+	{Version: ipfix.Version, Name: "IPFIX", Templates: true, Options: true, IPv6: true, Sampling: true, VariableLength: true, Enterprise: true},
+}
+
+// SupportedVersions returns the protocol versions the Decoder supports, in
+// order of their version number.
+func SupportedVersions() []VersionInfo {
+	return append([]VersionInfo(nil), versions...)
+}
@@ -0,0 +1,36 @@
+package netflow
+
+import "testing"
+
+func TestSupportedVersions(t *testing.T) {
+	vs := SupportedVersions()
+	byVersion := make(map[uint16]VersionInfo, len(vs))
+	for i, v := range vs {
+		if i > 0 && v.Version <= vs[i-1].Version {
+			t.Errorf("expected versions in order, got %d after %d", v.Version, vs[i-1].Version)
+		}
+		if _, err := DetectVersion([]byte{byte(v.Version >> 8), byte(v.Version)}); err != nil {
+			t.Errorf("%s: %v", v.Name, err)
+		}
+		byVersion[v.Version] = v
+	}
+
+	tests := []VersionInfo{
+		{Version: 5, Name: "NetFlow v5", Sampling: true},
+		{Version: 7, Name: "NetFlow v7"},
+		{Version: 9, Name: "NetFlow v9", Templates: true, Options: true, IPv6: true, Sampling: true, VariableLength: true},
+		{Version: 10, Name: "IPFIX", Templates: true, Options: true, IPv6: true, Sampling: true, VariableLength: true, Enterprise: true},
+	}
+	for _, want := range tests {
+		if got, ok := byVersion[want.Version]; !ok {
+			t.Errorf("version %d not listed", want.Version)
+		} else if got != want {
+			t.Errorf("version %d: expected %+v, got %+v", want.Version, want, got)
+		}
+	}
+
+	vs[0].Name = "changed"
+	if SupportedVersions()[0].Name == "changed" {
+		t.Error("expected a copy of the versions")
+	}
+}
@@ -0,0 +1,58 @@
+// Package write provides convenience write functions to serialize values to a writer.
+package write
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+)
+
+// Uint8 writes a single byte
+func Uint8(v uint8, w io.Writer) error {
+	_, err := w.Write([]byte{v})
+	return err
+}
+
+// Uint16 writes an unsigned word
+func Uint16(v uint16, w io.Writer) error {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	_, err := w.Write(b[:])
+	return err
+}
+
+// Uint32 writes an unsigned dword
+func Uint32(v uint32, w io.Writer) error {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	_, err := w.Write(b[:])
+	return err
+}
+
+// Uint64 writes an unsigned quad word
+func Uint64(v uint64, w io.Writer) error {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	_, err := w.Write(b[:])
+	return err
+}
+
+// IPv4 writes an IP address as 4 bytes, an unset address is written as 0.0.0.0
+func IPv4(v net.IP, w io.Writer) error {
+	var b [4]byte
+	if ip := v.To4(); ip != nil {
+		copy(b[:], ip)
+	}
+	_, err := w.Write(b[:])
+	return err
+}
+
+// PutIPv4 puts an IP address as 4 bytes in p, an unset address is put as
+// 0.0.0.0
+func PutIPv4(p []byte, v net.IP) {
+	if ip := v.To4(); ip != nil {
+		copy(p[:4], ip)
+	} else {
+		copy(p[:4], []byte{0, 0, 0, 0})
+	}
+}